@@ -0,0 +1,134 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/anz-bank/sysl-go-demo/src/cassette"
+	"github.com/anz-bank/sysl-go-demo/src/contract"
+)
+
+// contractCommand runs Petdemo as a contract checker instead of a server:
+//
+//	Petdemo contract [-service petstore] [-timeout 10s] <spec> [<serviceURL> | <cassette.yaml>]
+//
+// Without a cassette, every GET operation of the spec without parameters is
+// called on serviceURL, by default the first server of the spec. With a
+// cassette, the recorded responses of the service are checked instead.
+const contractCommand = "contract"
+
+// runContract runs the contract command and returns the exit code: 0 when
+// every response matches the spec, 1 on violations and 2 on usage errors.
+func runContract(args []string, out io.Writer) int {
+	fs := flag.NewFlagSet(contractCommand, flag.ContinueOnError)
+	fs.SetOutput(out)
+	service := fs.String("service", "petstore", "downstream whose cassette interactions are checked")
+	timeout := fs.Duration("timeout", 10*time.Second, "timeout of each request to the service")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: Petdemo %s [flags] <spec> [<serviceURL> | <cassette.yaml>]\n", contractCommand)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil || fs.NArg() < 1 || fs.NArg() > 2 {
+		if err == nil {
+			fs.Usage()
+		}
+		return 2
+	}
+
+	spec, err := contract.Load(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return 2
+	}
+	target := fs.Arg(1)
+	if target == "" {
+		target = spec.ServerURL()
+	}
+
+	var failed bool
+	if strings.HasSuffix(target, ".yaml") || strings.HasSuffix(target, ".yml") {
+		failed, err = checkCassette(out, spec, target, *service)
+	} else {
+		failed, err = checkService(out, spec, target, *timeout)
+	}
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return 2
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+func checkCassette(out io.Writer, spec *contract.Spec, path, service string) (bool, error) {
+	c, err := cassette.Load(path)
+	if err != nil {
+		return false, err
+	}
+	failed := false
+	for i, interaction := range c.Interactions {
+		if interaction.Service != service {
+			continue
+		}
+		body, err := interaction.Response.Body.Bytes()
+		if err != nil {
+			return false, fmt.Errorf("%s: interaction %d: %w", path, i, err)
+		}
+		req := interaction.Request
+		violations := spec.VerifyResponse(req.Method, req.Path, interaction.Response.Status, http.Header(interaction.Response.Headers), body)
+		failed = printResult(out, fmt.Sprintf("interaction %d: %s %s", i, req.Method, req.Path), interaction.Response.Status, violations) || failed
+	}
+	return failed, nil
+}
+
+func checkService(out io.Writer, spec *contract.Spec, serviceURL string, timeout time.Duration) (bool, error) {
+	base, err := url.Parse(serviceURL)
+	if err != nil || base.Scheme == "" {
+		return false, fmt.Errorf("invalid service URL %q", serviceURL)
+	}
+	client := &http.Client{Timeout: timeout}
+	failed := false
+	for _, op := range spec.Operations() {
+		if op.Method != http.MethodGet || op.Parameters {
+			fmt.Fprintf(out, "SKIP %s %s: only GET operations without parameters are called\n", op.Method, op.Path)
+			continue
+		}
+		u := *base
+		u.Path = strings.TrimSuffix(base.Path, "/") + op.Path
+		resp, err := client.Get(u.String())
+		if err != nil {
+			fmt.Fprintf(out, "FAIL %s %s: %v\n", op.Method, op.Path, err)
+			failed = true
+			continue
+		}
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			fmt.Fprintf(out, "FAIL %s %s: reading body: %v\n", op.Method, op.Path, err)
+			failed = true
+			continue
+		}
+		violations := spec.VerifyResponse(op.Method, op.Path, resp.StatusCode, resp.Header, body)
+		failed = printResult(out, op.Method+" "+op.Path, resp.StatusCode, violations) || failed
+	}
+	return failed, nil
+}
+
+// printResult prints the outcome of one check and reports whether it failed.
+func printResult(out io.Writer, name string, status int, violations []contract.Violation) bool {
+	if len(violations) == 0 {
+		fmt.Fprintf(out, "PASS %s (%d)\n", name, status)
+		return false
+	}
+	fmt.Fprintf(out, "FAIL %s (%d)\n", name, status)
+	for _, v := range violations {
+		fmt.Fprintf(out, "  %s\n", v)
+	}
+	return true
+}
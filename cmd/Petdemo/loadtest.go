@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	stdlog "log"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/signal"
+
+	"github.com/anz-bank/sysl-go/core"
+	"github.com/anz-bank/sysl-go/log"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+
+	"github.com/anz-bank/sysl-go-demo/internal/gen/pkg/servers/Petdemo"
+	"github.com/anz-bank/sysl-go-demo/src/configext"
+	"github.com/anz-bank/sysl-go-demo/src/contract"
+	"github.com/anz-bank/sysl-go-demo/src/loadtest"
+	"github.com/anz-bank/sysl-go-demo/src/specmock"
+	"github.com/anz-bank/sysl-go-demo/src/testhooks"
+)
+
+// loadtestCommand runs Petdemo as a load generator instead of a server:
+//
+//	Petdemo loadtest -target http://localhost:6060 [-admin http://localhost:6061] <profile.yaml>
+//	Petdemo loadtest -config config/config.yaml [-mock specs/petstore.yaml] <profile.yaml>
+//
+// The first form drives a running instance, counting downstream requests
+// from its admin metrics. The second starts Petdemo in process, with the
+// petstore downstream served in process from its spec if -mock is set.
+const loadtestCommand = "loadtest"
+
+// runLoadtest runs the loadtest command and returns the exit code: 0 on
+// success, 1 if the run could not complete and 2 on usage errors.
+func runLoadtest(args []string, stderr io.Writer) int {
+	fs := flag.NewFlagSet(loadtestCommand, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	target := fs.String("target", "", "public URL of a running Petdemo")
+	adminURL := fs.String("admin", "", "admin URL of the running Petdemo, to count downstream requests")
+	configPath := fs.String("config", "", "config of a Petdemo started in process, instead of -target")
+	mockSpec := fs.String("mock", "", "with -config, OpenAPI spec of the petstore downstream to serve in process")
+	rps := fs.Float64("rps", 0, "target requests per second, overrides the profile")
+	concurrency := fs.Int("concurrency", 0, "concurrent workers, overrides the profile")
+	duration := fs.Duration("duration", 0, "duration of the run, overrides the profile")
+	out := fs.String("out", "-", "file to write the JSON report to, - for stdout")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: Petdemo %s [flags] <profile.yaml>\n", loadtestCommand)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 || (*target == "") == (*configPath == "") {
+		if err == nil {
+			fmt.Fprintln(fs.Output(), "exactly one profile and one of -target or -config are required")
+			fs.Usage()
+		}
+		return 2
+	}
+
+	profile, err := loadtest.LoadProfile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if *rps > 0 || *concurrency > 0 {
+		profile.RPS, profile.Concurrency = *rps, *concurrency
+	}
+	if *duration > 0 {
+		profile.Duration = *duration
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var counter loadtest.DownstreamCounter
+	if *adminURL != "" {
+		counter = loadtest.ScrapeDownstream(*adminURL)
+	}
+	if *configPath != "" {
+		var in loadtest.Counter
+		baseURL, closeServer, err := startInProcess(ctx, *configPath, *mockSpec, &in)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		defer closeServer()
+		*target, counter = baseURL, in.Count
+	}
+
+	report, err := loadtest.Run(ctx, *target, *profile, counter)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	report.WriteSummary(stderr)
+	if err := report.WriteJSON(*out); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// startInProcess starts Petdemo with the config at configPath, like a
+// generated NewIntegrationTestServer, and returns its public URL. The
+// custom server builder and the admin server are not used in process.
+func startInProcess(ctx context.Context, configPath, mockSpec string, counter *loadtest.Counter) (string, func(), error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return "", nil, err
+	}
+	data, ext, err := configext.Split(data, configExtensions...)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", configPath, err)
+	}
+
+	var cfg map[interface{}]interface{}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return "", nil, fmt.Errorf("%s: %w", configPath, err)
+	}
+	delete(cfg, "admin")
+	// Request logs would drown the report, see quietLogger.
+	setYAML(cfg, "error", "library", "log", "level")
+	closers := []func(){}
+	closeAll := func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i]()
+		}
+	}
+	if mockSpec != "" {
+		spec, err := contract.Load(mockSpec)
+		if err != nil {
+			return "", nil, err
+		}
+		basePath := ""
+		if u, err := url.Parse(spec.ServerURL()); err == nil {
+			basePath = u.Path
+		}
+		mock, err := specmock.New(spec, specmock.Config{BasePath: basePath})
+		if err != nil {
+			return "", nil, err
+		}
+		mockServer := httptest.NewServer(mock)
+		closers = append(closers, mockServer.Close)
+		setYAML(cfg, mockServer.URL+basePath, "genCode", "downstream", "petstore", "serviceURL")
+	}
+	if data, err = yaml.Marshal(cfg); err != nil {
+		closeAll()
+		return "", nil, err
+	}
+
+	create := func(ctx context.Context, config AppConfig) (*petdemo.ServiceInterface, *core.Hooks, error) {
+		return createService(ctx, config, ext)
+	}
+	t := &cliT{}
+	srv, err := t.run(func() *petdemo.TestServer {
+		createService := testhooks.Patch(testhooks.WrapDownstreams(create, counter.Wrap), quietLogger)
+		return petdemo.NewIntegrationTestServer(t, ctx, createService, data)
+	})
+	if err != nil {
+		closeAll()
+		return "", nil, err
+	}
+	closers = append(closers, srv.Close)
+	basePath := core.SelectBasePath("", srv.GetE2eTester().CfgBasePath())
+	if basePath == "/" {
+		basePath = ""
+	}
+	return srv.GetE2eTester().EndpointURL(basePath), closeAll, nil
+}
+
+// quietLogger replaces the default logger of sysl-go, which logs requests
+// whatever the configured level.
+func quietLogger(hooks *core.Hooks) {
+	hooks.Logger = func() log.Logger {
+		return log.NewLogrusLogger(logrus.New())
+	}
+}
+
+// setYAML sets the value at path in a decoded YAML document, creating the
+// maps on the way.
+func setYAML(doc map[interface{}]interface{}, value interface{}, path ...string) {
+	for _, key := range path[:len(path)-1] {
+		next, ok := doc[key].(map[interface{}]interface{})
+		if !ok {
+			next = map[interface{}]interface{}{}
+			doc[key] = next
+		}
+		doc = next
+	}
+	doc[path[len(path)-1]] = value
+}
+
+// errFailNow stops a cliT after a fatal failure.
+var errFailNow = errors.New("failed")
+
+// cliT implements syslgo.TestingT outside of tests, so that the generated
+// test servers can be started by a command.
+type cliT struct {
+	errors []string
+}
+
+func (t *cliT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func (t *cliT) FailNow() {
+	panic(errFailNow)
+}
+
+func (t *cliT) Fatal(args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprint(args...))
+	t.FailNow()
+}
+
+func (t *cliT) Log(args ...interface{}) {
+	stdlog.Print(args...)
+}
+
+// run calls start, turning failures reported to t into an error.
+func (t *cliT) run(start func() *petdemo.TestServer) (srv *petdemo.TestServer, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if r != errFailNow {
+				panic(r)
+			}
+			err = fmt.Errorf("starting Petdemo in process: %v", t.errors)
+		}
+	}()
+	srv = start()
+	if len(t.errors) > 0 {
+		return nil, fmt.Errorf("starting Petdemo in process: %v", t.errors)
+	}
+	return srv, nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	petdemo "github.com/anz-bank/sysl-go-demo/internal/gen/pkg/servers/Petdemo"
+	"github.com/anz-bank/sysl-go-demo/src/loadtest"
+)
+
+const testProfile = `
+concurrency: 1
+duration: 100ms
+endpoints:
+  - path: /pet
+`
+
+func TestRunLoadtest(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	profile := filepath.Join(dir, "profile.yaml")
+	require.NoError(t, os.WriteFile(profile, []byte(testProfile), 0o600))
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer srv.Close()
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+		want string
+	}{
+		{"no profile", []string{"-target", srv.URL}, 2, "exactly one profile and one of -target or -config are required"},
+		{"no target", []string{profile}, 2, "exactly one profile"},
+		{"target and config", []string{"-target", srv.URL, "-config", "config.yaml", profile}, 2, "exactly one profile"},
+		{"unknown flag", []string{"-rate", "1", profile}, 2, "flag provided but not defined: -rate"},
+		{"missing profile", []string{"-target", srv.URL, filepath.Join(dir, "missing.yaml")}, 2, "missing.yaml"},
+		{"invalid profile", []string{"-target", srv.URL, "-rps", "1", "-concurrency", "1", profile}, 1, "set either rps or concurrency, not both"},
+		{"missing config", []string{"-config", filepath.Join(dir, "missing.yaml"), profile}, 1, "missing.yaml"},
+	}
+	for _, tt := range tests {
+		var stderr bytes.Buffer
+		require.Equal(t, tt.code, runLoadtest(tt.args, &stderr), tt.name)
+		require.Contains(t, stderr.String(), tt.want, tt.name)
+	}
+
+	out := filepath.Join(dir, "report.json")
+	var stderr bytes.Buffer
+	require.Equal(t, 0, runLoadtest([]string{"-target", srv.URL, "-out", out, "-duration", "50ms", profile}, &stderr), stderr.String())
+	require.Contains(t, stderr.String(), srv.URL)
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	var report loadtest.Report
+	require.NoError(t, json.Unmarshal(data, &report))
+	require.Positive(t, report.Requests)
+	require.Zero(t, report.Errors)
+	require.Equal(t, 1, report.Concurrency)
+}
+
+func TestSetYAML(t *testing.T) {
+	t.Parallel()
+
+	doc := map[interface{}]interface{}{"genCode": map[interface{}]interface{}{"upstream": 1}}
+	setYAML(doc, "http://mock", "genCode", "downstream", "petstore", "serviceURL")
+	setYAML(doc, "error", "level")
+	require.Equal(t, map[interface{}]interface{}{
+		"genCode": map[interface{}]interface{}{
+			"upstream":   1,
+			"downstream": map[interface{}]interface{}{"petstore": map[interface{}]interface{}{"serviceURL": "http://mock"}},
+		},
+		"level": "error",
+	}, doc)
+}
+
+func TestCliT(t *testing.T) {
+	t.Parallel()
+
+	ct := &cliT{}
+	_, err := ct.run(func() *petdemo.TestServer {
+		ct.Fatal("no port")
+		return nil
+	})
+	require.EqualError(t, err, "starting Petdemo in process: [no port]")
+
+	ct = &cliT{}
+	_, err = ct.run(func() *petdemo.TestServer {
+		ct.Errorf("bad %s", "config")
+		return nil
+	})
+	require.EqualError(t, err, "starting Petdemo in process: [bad config]")
+
+	require.PanicsWithValue(t, "other", func() {
+		_, _ = (&cliT{}).run(func() *petdemo.TestServer { panic("other") })
+	})
+}
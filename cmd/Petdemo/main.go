@@ -2,25 +2,228 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"reflect"
+
+	"github.com/anz-bank/sysl-go-demo/src/admin"
+	"github.com/anz-bank/sysl-go-demo/src/apikey"
+	"github.com/anz-bank/sysl-go-demo/src/auth"
+	"github.com/anz-bank/sysl-go-demo/src/configext"
+	"github.com/anz-bank/sysl-go-demo/src/cors"
+	"github.com/anz-bank/sysl-go-demo/src/diagnostic"
+	"github.com/anz-bank/sysl-go-demo/src/downstream"
+	"github.com/anz-bank/sysl-go-demo/src/featureflag"
 	"github.com/anz-bank/sysl-go-demo/src/handlers"
+	"github.com/anz-bank/sysl-go-demo/src/metrics"
+	"github.com/anz-bank/sysl-go-demo/src/mtls"
+	"github.com/anz-bank/sysl-go-demo/src/petgrpc"
+	"github.com/anz-bank/sysl-go-demo/src/server"
+	"github.com/anz-bank/sysl-go-demo/src/signing"
+	"github.com/anz-bank/sysl-go-demo/src/strictconfig"
+	"github.com/anz-bank/sysl-go-demo/src/tlsreload"
 
+	sysl "github.com/anz-bank/sysl-go/config"
 	"github.com/anz-bank/sysl-go/core"
+	"github.com/anz-bank/sysl-go/handlerinitialiser"
+	"github.com/go-chi/chi"
 
 	"github.com/anz-bank/sysl-go-demo/internal/gen/pkg/servers/Petdemo"
 )
 
+// configExtensions lists the config extensions (see configext) read by Petdemo.
+var configExtensions = append([]string{
+	cors.Extension, server.HardeningExtension, server.GRPCKeepaliveExtension, petgrpc.StreamsExtension, diagnostic.Extension,
+}, downstream.Extensions...)
+
 type AppConfig struct {
 	// Define app-level config fields here.
+	FeatureFlags   featureflag.Config     `yaml:"featureFlags" mapstructure:"featureFlags"`
+	Auth           auth.Config            `yaml:"auth" mapstructure:"auth"`
+	RequestSigning signing.VerifierConfig `yaml:"requestSigning" mapstructure:"requestSigning"`
+	TLSReload      tlsreload.Config       `yaml:"tlsReload" mapstructure:"tlsReload"`
 }
 
 func main() {
-	petdemo.Serve(context.Background(),
-		func(ctx context.Context, config AppConfig) (*petdemo.ServiceInterface, *core.Hooks, error) {
-			// Perform one-time setup based on config here.
-			return &petdemo.ServiceInterface{
-				// Add handlers here.
-				GetPetList: handlers.GetRandomPetPicListRead,
-			}, nil, nil
+	if len(os.Args) > 1 && os.Args[1] == contractCommand {
+		os.Exit(runContract(os.Args[2:], os.Stdout))
+	}
+	if len(os.Args) > 1 && os.Args[1] == loadtestCommand {
+		os.Exit(runLoadtest(os.Args[2:], os.Stderr))
+	}
+
+	ctx, ext, err := loadConfig(context.Background(), os.Args)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	petdemo.Serve(ctx, func(ctx context.Context, config AppConfig) (*petdemo.ServiceInterface, *core.Hooks, error) {
+		return createService(ctx, config, ext)
+	})
+}
+
+// createService performs one-time setup based on config and returns the
+// handlers and hooks of the Petdemo service.
+func createService(ctx context.Context, config AppConfig, ext *configext.Extensions) (*petdemo.ServiceInterface, *core.Hooks, error) {
+	flags, err := featureflag.New(config.FeatureFlags)
+	if err != nil {
+		return nil, nil, err
+	}
+	flags.Start(ctx)
+
+	apiKeys, err := apikey.New(config.Auth.APIKeys)
+	if err != nil {
+		return nil, nil, err
+	}
+	apiKeys.Start(ctx)
+
+	// The admin server keeps the certificates loaded by sysl-go at startup.
+	defaultConfig := sysl.GetDefaultConfig(ctx)
+	certs := tlsreload.NewManager(config.TLSReload)
+	upstreamCerts, err := certs.Add("upstream", defaultConfig.GenCode.Upstream.HTTP.Common.TLS)
+	if err != nil {
+		return nil, nil, err
+	}
+	grpcCerts, err := certs.Add("grpc", defaultConfig.GenCode.Upstream.GRPC.TLS)
+	if err != nil {
+		return nil, nil, err
+	}
+	httpClientBuilder, err := certs.HTTPClientBuilder(ctx, tlsreload.Downstreams(defaultConfig.GenCode.Downstream))
+	if err != nil {
+		return nil, nil, err
+	}
+	certs.Start(ctx)
+
+	downstreamMetrics := metrics.NewDownstream()
+	grpcMetrics := metrics.NewGRPC()
+	registry := metrics.NewRegistry()
+	registry.MustRegister(certs, downstreamMetrics, grpcMetrics)
+
+	adminRoutes := admin.NewRoutes()
+	adminRoutes.Route("/-/featureflags", flags.WireAdminRoutes)
+	if apiKeys != nil {
+		adminRoutes.Route("/-/apikeys", apiKeys.WireAdminRoutes)
+	}
+	adminRoutes.Route("/-/tls", certs.WireAdminRoutes)
+
+	transports, err := downstream.NewTransports(ctx, ext)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	corsHandler, err := cors.New(ext)
+	if err != nil {
+		return nil, nil, err
+	}
+	hardening, err := server.LoadHardening(ext)
+	if err != nil {
+		return nil, nil, err
+	}
+	grpcKeepalive, err := server.LoadGRPCKeepalive(ext)
+	if err != nil {
+		return nil, nil, err
+	}
+	streams, err := petgrpc.LoadStreams(ext)
+	if err != nil {
+		return nil, nil, err
+	}
+	diagnostics, err := diagnostic.New(ext)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Applies the request limits and answers CORS preflight requests before
+	// the timeout and auth middleware.
+	builder := server.NewBuilder(hardening, corsHandler.Handler)
+	builder.ConfigureTLS = upstreamCerts.ServerTLS
+
+	hooks := &core.Hooks{
+		AddAdminHTTPMiddleware: func(ctx context.Context, r chi.Router) {
+			adminRoutes.Middleware(ctx, r)
+			registry.Middleware(ctx, r)
+		},
+		HTTPClientBuilder: httpClientBuilder,
+		// Counts the requests as sent on the wire, including those retried by transports.
+		DownstreamRoundTripper: func(serviceName, serviceURL string, original http.RoundTripper) http.RoundTripper {
+			return transports.RoundTripper(serviceName, serviceURL, downstreamMetrics.RoundTripper(serviceName, serviceURL, original))
 		},
-	)
+		StoppableServerBuilder: builder.Build,
+		// Measures the calls to gRPC downstreams built by core.BuildDownstreamGRPCClient.
+		AdditionalGrpcDialOptions: grpcMetrics.DialOptions(),
+	}
+
+	authorizer, err := auth.NewAuthorizer(ctx, hooks, config.Auth, apiKeys)
+	if err != nil {
+		return nil, nil, err
+	}
+	// The gRPC methods serving REST endpoints are authorized by their rules.
+	grpcAuth, err := authorizer.GRPCServerOptions(petgrpc.Endpoints)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Measures the calls before authorization, so that rejected calls are counted.
+	hooks.AdditionalGrpcServerOptions = append(grpcMetrics.ServerOptions(), grpcAuth...)
+	verifier, err := signing.NewVerifier(config.RequestSigning)
+	if err != nil {
+		return nil, nil, err
+	}
+	hooks.AddHTTPMiddleware = func(ctx context.Context, r chi.Router) {
+		mtls.Middleware(ctx, r)
+		verifier.Middleware(ctx, r)
+		authorizer.Middleware(ctx, r)
+	}
+
+	pet := &handlers.Pet{Flags: flags}
+	service := &petdemo.ServiceInterface{
+		GetPetList: pet.GetRandomPetPicListRead,
+	}
+	// Serves the same handlers over gRPC if genCode.upstream.grpc sets a port.
+	grpcHandlers := []server.GRPCHandler{
+		func(ctx context.Context, hooks *core.Hooks) (handlerinitialiser.GrpcHandlerInitialiser, error) {
+			return petgrpc.New(ctx, hooks, service, streams)
+		},
+	}
+	if diagnostics != nil {
+		grpcHandlers = append(grpcHandlers, diagnostics.Handler)
+	}
+	builder.GRPC = server.NewGRPCBuilder(hooks, grpcHandlers...)
+	builder.GRPC.Keepalive = grpcKeepalive
+	builder.GRPC.ConfigureTLS = grpcCerts.ServerTLS
+	return service, hooks, nil
+}
+
+// loadConfig reads the config file named by args and removes the config
+// extensions (see configext) from it, returning a context from which
+// core.NewServer reads the remaining config.
+//
+// sysl-go rejects unknown keys too, but stops at the first one and without
+// its full path. Every unknown key is reported here instead, with the closest
+// known key, using the same config structure that core.NewServer decodes into.
+func loadConfig(ctx context.Context, args []string) (context.Context, *configext.Extensions, error) {
+	if len(args) != 2 {
+		// Let core.NewServer report usage errors and handle -h and -v.
+		return ctx, nil, nil
+	}
+	switch args[1] {
+	case "--help", "-h", "--version", "-v":
+		return ctx, nil, nil
+	}
+	data, err := os.ReadFile(args[1])
+	if err != nil {
+		return nil, nil, err
+	}
+	data, ext, err := configext.Split(data, configExtensions...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", args[1], err)
+	}
+	configType := reflect.TypeOf(core.NewZeroCustomConfig(
+		reflect.TypeOf(&petdemo.DownstreamConfig{}),
+		reflect.TypeOf(AppConfig{}),
+	))
+	if err := strictconfig.Check(data, configType, "envPrefix"); err != nil {
+		return nil, nil, err
+	}
+	return core.WithConfigFile(ctx, data), ext, nil
 }
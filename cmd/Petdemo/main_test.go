@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/anz-bank/sysl-go/core"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	petdemo "github.com/anz-bank/sysl-go-demo/internal/gen/pkg/servers/Petdemo"
+	"github.com/anz-bank/sysl-go-demo/src/golden"
+	"github.com/anz-bank/sysl-go-demo/src/scenario"
+)
+
+func init() {
+	golden.RegisterUpdateFlag()
+}
+
+const testSecret = "petdemo-test-secret-of-32-bytes!"
+
+// testConfig runs Petdemo against mocked downstreams, accepting the tokens
+// of testToken.
+const testConfig = `
+library:
+  log:
+    format: text
+    level: error
+genCode:
+  upstream:
+    contextTimeout: 5s
+    http:
+      basePath: /
+      readTimeout: 5s
+      writeTimeout: 5s
+      common:
+        hostName: ""
+        port: 0
+  downstream:
+    contextTimeout: 1s
+    petstore:
+      serviceURL: http://petstore
+      clientTimeout: 1s
+app:
+  auth:
+    issuers:
+      - name: petdemo-test
+        sharedSecret: ` + testSecret + `
+        audience: [petdemo]
+    endpoints:
+      - method: GET
+        path: /pet
+        rule: jwtHasScope("pets.read")
+`
+
+// newTestServer starts Petdemo with testConfig and mocked downstreams.
+func newTestServer(t *testing.T) *petdemo.TestServer {
+	t.Helper()
+	create := func(ctx context.Context, config AppConfig) (*petdemo.ServiceInterface, *core.Hooks, error) {
+		return createService(ctx, config, nil)
+	}
+	return petdemo.NewTestServer(t, context.Background(), create, []byte(testConfig))
+}
+
+// testToken returns a token of the test issuer granted scope.
+func testToken(t *testing.T, scope string) string {
+	t.Helper()
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: []byte(testSecret)}, (&jose.SignerOptions{}).WithType("JWT"))
+	require.NoError(t, err)
+	token, err := jwt.Signed(signer).Claims(map[string]interface{}{
+		"iss":   "petdemo-test",
+		"sub":   "tester",
+		"aud":   []string{"petdemo"},
+		"scope": scope,
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}).CompactSerialize()
+	require.NoError(t, err)
+	return token
+}
+
+func TestGetPetGolden(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	srv.Mocks.Petstore.GetPetList.MockResponse(200, map[string]string{"Content-Type": "application/json"}, "husky")
+	srv.GetPetList().
+		WithHeaders(map[string]string{"Authorization": "Bearer " + testToken(t, "pets.read")}).
+		ExpectResponseCode(200).
+		TestResponseBody(golden.ResponseBody("getpet")).
+		Send()
+}
+
+func TestScenarios(t *testing.T) {
+	scenario.Run(t, "testdata/scenarios/*.yaml", func(t *testing.T) scenario.TestServer {
+		return newTestServer(t)
+	}, map[string]string{"TOKEN": testToken(t, "pets.read")})
+}
@@ -0,0 +1,85 @@
+// Command petstore-mock serves the operations of specs/petstore.yaml locally,
+// so that Petdemo can run without the Petstore cloud function:
+//
+//	go run ./cmd/petstore-mock
+//
+// and set genCode.downstream.petstore.serviceURL to the URL it prints.
+// Requests select a response with the X-Mock-Scenario header, or -scenario
+// for those without it (see specmock).
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/anz-bank/sysl-go-demo/src/contract"
+	"github.com/anz-bank/sysl-go-demo/src/specmock"
+)
+
+func main() {
+	specPath := flag.String("spec", "specs/petstore.yaml", "OpenAPI spec to serve")
+	addr := flag.String("addr", "localhost:8081", "address to listen on")
+	basePath := flag.String("base-path", "", "path prefix of the operations (default: the path of the first server of the spec)")
+	scenario := flag.String("scenario", "", "scenario of requests without an "+specmock.ScenarioHeader+" header")
+	latency := flag.Duration("latency", 0, "delay of every response")
+	jitter := flag.Duration("jitter", 0, "maximum random delay added to latency")
+	errorRate := flag.Float64("error-rate", 0, "probability of an error response, between 0 and 1")
+	seed := flag.Int64("seed", 1, "seed of the generated data, latencies and errors")
+	flag.Parse()
+
+	spec, err := contract.Load(*specPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *basePath == "" {
+		if u, err := url.Parse(spec.ServerURL()); err == nil {
+			*basePath = u.Path
+		}
+	}
+	mock, err := specmock.New(spec, specmock.Config{
+		BasePath:  *basePath,
+		Scenario:  *scenario,
+		Latency:   *latency,
+		Jitter:    *jitter,
+		ErrorRate: *errorRate,
+		Seed:      *seed,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("serving %s, set genCode.downstream.petstore.serviceURL to http://%s%s", *specPath, listener.Addr(), *basePath)
+	server := &http.Server{
+		Handler:           logRequests(mock),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log.Fatal(server.Serve(listener))
+}
+
+// logRequests logs every request with the status of its response.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+		log.Printf("%s %s scenario=%q status=%d took=%s", r.Method, r.URL.Path, r.Header.Get(specmock.ScenarioHeader), sw.status, time.Since(start))
+	})
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/anz-bank/sysl-go-demo/src/contract"
+	"github.com/anz-bank/sysl-go-demo/src/specmock"
+)
+
+func TestLogRequests(t *testing.T) {
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	defer log.SetOutput(os.Stderr)
+
+	spec, err := contract.Load("../../specs/petstore.yaml")
+	require.NoError(t, err)
+	mock, err := specmock.New(spec, specmock.Config{BasePath: "/pet-demo"})
+	require.NoError(t, err)
+	handler := logRequests(mock)
+
+	tests := []struct {
+		path     string
+		scenario string
+		want     string
+	}{
+		{"/pet-demo/pet", "", `GET /pet-demo/pet scenario="" status=200`},
+		{"/pet-demo/pet", "error", `GET /pet-demo/pet scenario="error" status=500`},
+		{"/pet-demo/unknown", "", `GET /pet-demo/unknown scenario="" status=404`},
+	}
+	for _, tt := range tests {
+		out.Reset()
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.scenario != "" {
+			req.Header.Set(specmock.ScenarioHeader, tt.scenario)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		require.Contains(t, out.String(), tt.want)
+	}
+}
@@ -0,0 +1,187 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package grpc_testing
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// FooClient is the client API for Foo service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type FooClient interface {
+	ThisEndpoint(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
+}
+
+type fooClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewFooClient(cc grpc.ClientConnInterface) FooClient {
+	return &fooClient{cc}
+}
+
+func (c *fooClient) ThisEndpoint(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(ctx, "/grpc.testing.Foo/thisEndpoint", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// FooServer is the server API for Foo service.
+// All implementations must embed UnimplementedFooServer
+// for forward compatibility
+type FooServer interface {
+	ThisEndpoint(context.Context, *Request) (*Response, error)
+	mustEmbedUnimplementedFooServer()
+}
+
+// UnimplementedFooServer must be embedded to have forward compatible implementations.
+type UnimplementedFooServer struct {
+}
+
+func (UnimplementedFooServer) ThisEndpoint(context.Context, *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ThisEndpoint not implemented")
+}
+func (UnimplementedFooServer) mustEmbedUnimplementedFooServer() {}
+
+// UnsafeFooServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to FooServer will
+// result in compilation errors.
+type UnsafeFooServer interface {
+	mustEmbedUnimplementedFooServer()
+}
+
+func RegisterFooServer(s grpc.ServiceRegistrar, srv FooServer) {
+	s.RegisterService(&Foo_ServiceDesc, srv)
+}
+
+func _Foo_ThisEndpoint_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FooServer).ThisEndpoint(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/grpc.testing.Foo/thisEndpoint",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FooServer).ThisEndpoint(ctx, req.(*Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Foo_ServiceDesc is the grpc.ServiceDesc for Foo service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Foo_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "grpc.testing.Foo",
+	HandlerType: (*FooServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "thisEndpoint",
+			Handler:    _Foo_ThisEndpoint_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "test.proto",
+}
+
+// BarClient is the client API for Bar service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type BarClient interface {
+	AnotherEndpoint(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
+}
+
+type barClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewBarClient(cc grpc.ClientConnInterface) BarClient {
+	return &barClient{cc}
+}
+
+func (c *barClient) AnotherEndpoint(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(ctx, "/grpc.testing.Bar/AnotherEndpoint", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// BarServer is the server API for Bar service.
+// All implementations must embed UnimplementedBarServer
+// for forward compatibility
+type BarServer interface {
+	AnotherEndpoint(context.Context, *Request) (*Response, error)
+	mustEmbedUnimplementedBarServer()
+}
+
+// UnimplementedBarServer must be embedded to have forward compatible implementations.
+type UnimplementedBarServer struct {
+}
+
+func (UnimplementedBarServer) AnotherEndpoint(context.Context, *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AnotherEndpoint not implemented")
+}
+func (UnimplementedBarServer) mustEmbedUnimplementedBarServer() {}
+
+// UnsafeBarServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to BarServer will
+// result in compilation errors.
+type UnsafeBarServer interface {
+	mustEmbedUnimplementedBarServer()
+}
+
+func RegisterBarServer(s grpc.ServiceRegistrar, srv BarServer) {
+	s.RegisterService(&Bar_ServiceDesc, srv)
+}
+
+func _Bar_AnotherEndpoint_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BarServer).AnotherEndpoint(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/grpc.testing.Bar/AnotherEndpoint",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BarServer).AnotherEndpoint(ctx, req.(*Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Bar_ServiceDesc is the grpc.ServiceDesc for Bar service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Bar_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "grpc.testing.Bar",
+	HandlerType: (*BarServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "AnotherEndpoint",
+			Handler:    _Bar_AnotherEndpoint_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "test.proto",
+}
@@ -0,0 +1,280 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.28.1
+// 	protoc        (unknown)
+// source: petdemo.proto
+
+package petdemopb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type GetPetRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *GetPetRequest) Reset() {
+	*x = GetPetRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_petdemo_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetPetRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetPetRequest) ProtoMessage() {}
+
+func (x *GetPetRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_petdemo_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetPetRequest.ProtoReflect.Descriptor instead.
+func (*GetPetRequest) Descriptor() ([]byte, []int) {
+	return file_petdemo_proto_rawDescGZIP(), []int{0}
+}
+
+type WatchPetsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Interval between pets, at least the minimum interval of the server.
+	// Defaults to the minimum interval.
+	Interval *durationpb.Duration `protobuf:"bytes,1,opt,name=interval,proto3" json:"interval,omitempty"`
+}
+
+func (x *WatchPetsRequest) Reset() {
+	*x = WatchPetsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_petdemo_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *WatchPetsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchPetsRequest) ProtoMessage() {}
+
+func (x *WatchPetsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_petdemo_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchPetsRequest.ProtoReflect.Descriptor instead.
+func (*WatchPetsRequest) Descriptor() ([]byte, []int) {
+	return file_petdemo_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *WatchPetsRequest) GetInterval() *durationpb.Duration {
+	if x != nil {
+		return x.Interval
+	}
+	return nil
+}
+
+type Pet struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Breed string `protobuf:"bytes,1,opt,name=breed,proto3" json:"breed,omitempty"`
+}
+
+func (x *Pet) Reset() {
+	*x = Pet{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_petdemo_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Pet) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Pet) ProtoMessage() {}
+
+func (x *Pet) ProtoReflect() protoreflect.Message {
+	mi := &file_petdemo_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Pet.ProtoReflect.Descriptor instead.
+func (*Pet) Descriptor() ([]byte, []int) {
+	return file_petdemo_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Pet) GetBreed() string {
+	if x != nil {
+		return x.Breed
+	}
+	return ""
+}
+
+var File_petdemo_proto protoreflect.FileDescriptor
+
+var file_petdemo_proto_rawDesc = []byte{
+	0x0a, 0x0d, 0x70, 0x65, 0x74, 0x64, 0x65, 0x6d, 0x6f, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12,
+	0x07, 0x70, 0x65, 0x74, 0x64, 0x65, 0x6d, 0x6f, 0x1a, 0x1e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65,
+	0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x64, 0x75, 0x72, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x0f, 0x0a, 0x0d, 0x47, 0x65, 0x74, 0x50,
+	0x65, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0x49, 0x0a, 0x10, 0x57, 0x61, 0x74,
+	0x63, 0x68, 0x50, 0x65, 0x74, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x35, 0x0a,
+	0x08, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x76, 0x61, 0x6c, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x19, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75,
+	0x66, 0x2e, 0x44, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x08, 0x69, 0x6e, 0x74, 0x65,
+	0x72, 0x76, 0x61, 0x6c, 0x22, 0x1b, 0x0a, 0x03, 0x50, 0x65, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x62,
+	0x72, 0x65, 0x65, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x62, 0x72, 0x65, 0x65,
+	0x64, 0x32, 0x78, 0x0a, 0x0a, 0x50, 0x65, 0x74, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12,
+	0x30, 0x0a, 0x06, 0x47, 0x65, 0x74, 0x50, 0x65, 0x74, 0x12, 0x16, 0x2e, 0x70, 0x65, 0x74, 0x64,
+	0x65, 0x6d, 0x6f, 0x2e, 0x47, 0x65, 0x74, 0x50, 0x65, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x0c, 0x2e, 0x70, 0x65, 0x74, 0x64, 0x65, 0x6d, 0x6f, 0x2e, 0x50, 0x65, 0x74, 0x22,
+	0x00, 0x12, 0x38, 0x0a, 0x09, 0x57, 0x61, 0x74, 0x63, 0x68, 0x50, 0x65, 0x74, 0x73, 0x12, 0x19,
+	0x2e, 0x70, 0x65, 0x74, 0x64, 0x65, 0x6d, 0x6f, 0x2e, 0x57, 0x61, 0x74, 0x63, 0x68, 0x50, 0x65,
+	0x74, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x0c, 0x2e, 0x70, 0x65, 0x74, 0x64,
+	0x65, 0x6d, 0x6f, 0x2e, 0x50, 0x65, 0x74, 0x22, 0x00, 0x30, 0x01, 0x42, 0x44, 0x5a, 0x42, 0x67,
+	0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x61, 0x6e, 0x7a, 0x2d, 0x62, 0x61,
+	0x6e, 0x6b, 0x2f, 0x73, 0x79, 0x73, 0x6c, 0x2d, 0x67, 0x6f, 0x2d, 0x64, 0x65, 0x6d, 0x6f, 0x2f,
+	0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f, 0x67, 0x65, 0x6e, 0x2f, 0x70, 0x62, 0x2f,
+	0x70, 0x65, 0x74, 0x64, 0x65, 0x6d, 0x6f, 0x3b, 0x70, 0x65, 0x74, 0x64, 0x65, 0x6d, 0x6f, 0x70,
+	0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_petdemo_proto_rawDescOnce sync.Once
+	file_petdemo_proto_rawDescData = file_petdemo_proto_rawDesc
+)
+
+func file_petdemo_proto_rawDescGZIP() []byte {
+	file_petdemo_proto_rawDescOnce.Do(func() {
+		file_petdemo_proto_rawDescData = protoimpl.X.CompressGZIP(file_petdemo_proto_rawDescData)
+	})
+	return file_petdemo_proto_rawDescData
+}
+
+var file_petdemo_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_petdemo_proto_goTypes = []interface{}{
+	(*GetPetRequest)(nil),       // 0: petdemo.GetPetRequest
+	(*WatchPetsRequest)(nil),    // 1: petdemo.WatchPetsRequest
+	(*Pet)(nil),                 // 2: petdemo.Pet
+	(*durationpb.Duration)(nil), // 3: google.protobuf.Duration
+}
+var file_petdemo_proto_depIdxs = []int32{
+	3, // 0: petdemo.WatchPetsRequest.interval:type_name -> google.protobuf.Duration
+	0, // 1: petdemo.PetService.GetPet:input_type -> petdemo.GetPetRequest
+	1, // 2: petdemo.PetService.WatchPets:input_type -> petdemo.WatchPetsRequest
+	2, // 3: petdemo.PetService.GetPet:output_type -> petdemo.Pet
+	2, // 4: petdemo.PetService.WatchPets:output_type -> petdemo.Pet
+	3, // [3:5] is the sub-list for method output_type
+	1, // [1:3] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_petdemo_proto_init() }
+func file_petdemo_proto_init() {
+	if File_petdemo_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_petdemo_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetPetRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_petdemo_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*WatchPetsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_petdemo_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Pet); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_petdemo_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   3,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_petdemo_proto_goTypes,
+		DependencyIndexes: file_petdemo_proto_depIdxs,
+		MessageInfos:      file_petdemo_proto_msgTypes,
+	}.Build()
+	File_petdemo_proto = out.File
+	file_petdemo_proto_rawDesc = nil
+	file_petdemo_proto_goTypes = nil
+	file_petdemo_proto_depIdxs = nil
+}
@@ -0,0 +1,175 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package petdemopb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// PetServiceClient is the client API for PetService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PetServiceClient interface {
+	// GetPet returns a random pet, as GET /pet does.
+	GetPet(ctx context.Context, in *GetPetRequest, opts ...grpc.CallOption) (*Pet, error)
+	// WatchPets streams a random pet at the requested interval until the call
+	// is cancelled. The stream ends with UNAVAILABLE when the server shuts down
+	// and with OK after the maximum duration of streams, after which clients
+	// should call again.
+	WatchPets(ctx context.Context, in *WatchPetsRequest, opts ...grpc.CallOption) (PetService_WatchPetsClient, error)
+}
+
+type petServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPetServiceClient(cc grpc.ClientConnInterface) PetServiceClient {
+	return &petServiceClient{cc}
+}
+
+func (c *petServiceClient) GetPet(ctx context.Context, in *GetPetRequest, opts ...grpc.CallOption) (*Pet, error) {
+	out := new(Pet)
+	err := c.cc.Invoke(ctx, "/petdemo.PetService/GetPet", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *petServiceClient) WatchPets(ctx context.Context, in *WatchPetsRequest, opts ...grpc.CallOption) (PetService_WatchPetsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &PetService_ServiceDesc.Streams[0], "/petdemo.PetService/WatchPets", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &petServiceWatchPetsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type PetService_WatchPetsClient interface {
+	Recv() (*Pet, error)
+	grpc.ClientStream
+}
+
+type petServiceWatchPetsClient struct {
+	grpc.ClientStream
+}
+
+func (x *petServiceWatchPetsClient) Recv() (*Pet, error) {
+	m := new(Pet)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// PetServiceServer is the server API for PetService service.
+// All implementations must embed UnimplementedPetServiceServer
+// for forward compatibility
+type PetServiceServer interface {
+	// GetPet returns a random pet, as GET /pet does.
+	GetPet(context.Context, *GetPetRequest) (*Pet, error)
+	// WatchPets streams a random pet at the requested interval until the call
+	// is cancelled. The stream ends with UNAVAILABLE when the server shuts down
+	// and with OK after the maximum duration of streams, after which clients
+	// should call again.
+	WatchPets(*WatchPetsRequest, PetService_WatchPetsServer) error
+	mustEmbedUnimplementedPetServiceServer()
+}
+
+// UnimplementedPetServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedPetServiceServer struct {
+}
+
+func (UnimplementedPetServiceServer) GetPet(context.Context, *GetPetRequest) (*Pet, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPet not implemented")
+}
+func (UnimplementedPetServiceServer) WatchPets(*WatchPetsRequest, PetService_WatchPetsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchPets not implemented")
+}
+func (UnimplementedPetServiceServer) mustEmbedUnimplementedPetServiceServer() {}
+
+// UnsafePetServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PetServiceServer will
+// result in compilation errors.
+type UnsafePetServiceServer interface {
+	mustEmbedUnimplementedPetServiceServer()
+}
+
+func RegisterPetServiceServer(s grpc.ServiceRegistrar, srv PetServiceServer) {
+	s.RegisterService(&PetService_ServiceDesc, srv)
+}
+
+func _PetService_GetPet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PetServiceServer).GetPet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/petdemo.PetService/GetPet",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PetServiceServer).GetPet(ctx, req.(*GetPetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PetService_WatchPets_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchPetsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PetServiceServer).WatchPets(m, &petServiceWatchPetsServer{stream})
+}
+
+type PetService_WatchPetsServer interface {
+	Send(*Pet) error
+	grpc.ServerStream
+}
+
+type petServiceWatchPetsServer struct {
+	grpc.ServerStream
+}
+
+func (x *petServiceWatchPetsServer) Send(m *Pet) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// PetService_ServiceDesc is the grpc.ServiceDesc for PetService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var PetService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "petdemo.PetService",
+	HandlerType: (*PetServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetPet",
+			Handler:    _PetService_GetPet_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchPets",
+			Handler:       _PetService_WatchPets_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "petdemo.proto",
+}
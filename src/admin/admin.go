@@ -0,0 +1,57 @@
+// Package admin serves application-specific endpoints on the sysl-go admin server.
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi"
+)
+
+// Routes collects the endpoints to be served alongside the sysl-go admin
+// endpoints (/-/status, /-/metrics, ...).
+type Routes struct {
+	mux *chi.Mux
+}
+
+// NewRoutes creates an empty set of admin routes.
+func NewRoutes() *Routes {
+	return &Routes{mux: chi.NewRouter()}
+}
+
+// Route mounts a sub-router at pattern, relative to the admin base path.
+func (a *Routes) Route(pattern string, fn func(r chi.Router)) {
+	a.mux.Route(pattern, fn)
+}
+
+// Middleware installs the routes on the admin router. It has the signature of
+// core.Hooks.AddAdminHTTPMiddleware.
+//
+// sysl-go does not let applications add admin routes directly, so requests
+// matching one of the routes are intercepted before they reach the admin
+// router and every other request is passed through untouched.
+func (a *Routes) Middleware(_ context.Context, r chi.Router) {
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			path := req.URL.Path
+			if rctx := chi.RouteContext(req.Context()); rctx != nil && rctx.RoutePath != "" {
+				path = rctx.RoutePath
+			}
+			if !a.mux.Match(chi.NewRouteContext(), req.Method, path) {
+				next.ServeHTTP(w, req)
+				return
+			}
+			rctx := chi.NewRouteContext()
+			rctx.RoutePath = path
+			a.mux.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx)))
+		})
+	})
+}
+
+// WriteJSON writes v as a JSON response with the given status code.
+func WriteJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,25 @@
+package apikey
+
+import (
+	"net/http"
+
+	"github.com/anz-bank/sysl-go-demo/src/admin"
+	"github.com/go-chi/chi"
+)
+
+// WireAdminRoutes adds the key endpoints to an admin router:
+//
+//	GET    /       lists every key with its owner, scopes, validity and status
+//	DELETE /{id}   revokes a key
+func (s *Store) WireAdminRoutes(r chi.Router) {
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		admin.WriteJSON(w, http.StatusOK, s.States())
+	})
+	r.Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if err := s.Revoke(chi.URLParam(r, "id")); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
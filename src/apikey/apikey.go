@@ -0,0 +1,202 @@
+// Package apikey authenticates callers that present an API key instead of a
+// JWT, for callers that cannot obtain tokens.
+package apikey
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anz-bank/sysl-go/jwtauth"
+	"github.com/anz-bank/sysl-go/log"
+	"google.golang.org/grpc/metadata"
+)
+
+// Issuer is the iss claim of the claims of an API key.
+const Issuer = "apikey"
+
+// Store holds the API keys read from the keys file. A nil *Store accepts no keys.
+type Store struct {
+	cfg Config
+
+	mu      sync.RWMutex
+	keys    map[string]Key // by hex digest
+	revoked map[string]bool
+	modTime time.Time
+}
+
+// New creates a Store from config, reading the keys file.
+func New(cfg Config) (*Store, error) {
+	if cfg.File == "" {
+		return nil, nil
+	}
+	cfg.setDefaults()
+	s := &Store{
+		cfg:     cfg,
+		revoked: make(map[string]bool),
+	}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Start polls the keys file for changes until ctx is done.
+func (s *Store) Start(ctx context.Context) {
+	if s == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(s.cfg.ReloadInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.reload(); err != nil {
+					log.Error(ctx, err, "api keys: reload failed, keeping previous keys")
+				}
+			}
+		}
+	}()
+}
+
+// reload re-reads the keys file if it changed since it was last read.
+func (s *Store) reload() error {
+	info, err := os.Stat(s.cfg.File)
+	if err != nil {
+		return err
+	}
+	s.mu.RLock()
+	unchanged := info.ModTime().Equal(s.modTime)
+	s.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+	fileKeys, err := readKeysFile(s.cfg.File)
+	if err != nil {
+		return err
+	}
+	keys := make(map[string]Key, len(fileKeys))
+	for _, k := range fileKeys {
+		keys[strings.ToLower(strings.TrimPrefix(k.Hash, hashPrefix))] = k
+	}
+	s.mu.Lock()
+	s.keys = keys
+	s.modTime = info.ModTime()
+	s.mu.Unlock()
+	return nil
+}
+
+// FromRequest returns the API key carried by req, if any.
+func (s *Store) FromRequest(req *http.Request) string {
+	if s == nil {
+		return ""
+	}
+	return req.Header.Get(s.cfg.Header)
+}
+
+// FromMetadata returns the API key carried by the metadata of a gRPC call, if
+// any, under the same header as requests.
+func (s *Store) FromMetadata(md metadata.MD) string {
+	if s == nil {
+		return ""
+	}
+	if values := md.Get(s.cfg.Header); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Authenticate returns the claims of the given key: sub is the owner of the
+// key, scope its space-separated scopes and apikey_id its id, so that
+// authorization expressions apply to keys as they do to JWTs. Errors are
+// *jwtauth.AuthError values, as returned for JWTs.
+func (s *Store) Authenticate(_ context.Context, rawKey string) (jwtauth.Claims, error) {
+	if s == nil {
+		return nil, &jwtauth.AuthError{Code: jwtauth.AuthErrCodeUntrustedSource, Cause: errors.New("api keys are not enabled")}
+	}
+	digest := strings.TrimPrefix(Hash(rawKey), hashPrefix)
+	s.mu.RLock()
+	k, known := s.keys[digest]
+	revoked := s.revoked[k.ID]
+	s.mu.RUnlock()
+
+	now := time.Now()
+	switch {
+	case !known:
+		return nil, &jwtauth.AuthError{Code: jwtauth.AuthErrCodeInvalidJWT, Cause: errors.New("unknown api key")}
+	case k.Revoked || revoked:
+		return nil, &jwtauth.AuthError{Code: jwtauth.AuthErrCodeInvalidJWT, Cause: fmt.Errorf("api key %s is revoked", k.ID)}
+	case k.NotBefore != nil && now.Before(*k.NotBefore):
+		return nil, &jwtauth.AuthError{Code: jwtauth.AuthErrCodeInvalidJWT, Cause: fmt.Errorf("api key %s is not valid yet", k.ID)}
+	case k.Expires != nil && !now.Before(*k.Expires):
+		return nil, &jwtauth.AuthError{Code: jwtauth.AuthErrCodeInvalidJWT, Cause: fmt.Errorf("api key %s has expired", k.ID)}
+	}
+
+	claims := jwtauth.Claims{
+		"iss":       Issuer,
+		"sub":       k.Owner,
+		"scope":     strings.Join(k.Scopes, " "),
+		"apikey_id": k.ID,
+	}
+	if k.Expires != nil {
+		claims["exp"] = float64(k.Expires.Unix())
+	}
+	return claims, nil
+}
+
+// Revoke rejects the key with the given id from now on, until the service is
+// restarted. Remove the key from the keys file, or mark it revoked there, to
+// revoke it permanently.
+func (s *Store) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, k := range s.keys {
+		if k.ID == id {
+			s.revoked[id] = true
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown api key: %s", id)
+}
+
+// State describes a key as reported by the admin server.
+type State struct {
+	Key
+	Status string `json:"status"`
+}
+
+// States returns every key, without its hash, sorted by owner and id.
+func (s *Store) States() []State {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	now := time.Now()
+	states := make([]State, 0, len(s.keys))
+	for _, k := range s.keys {
+		state := State{Key: k, Status: "active"}
+		switch {
+		case k.Revoked || s.revoked[k.ID]:
+			state.Revoked, state.Status = true, "revoked"
+		case k.NotBefore != nil && now.Before(*k.NotBefore):
+			state.Status = "pending"
+		case k.Expires != nil && !now.Before(*k.Expires):
+			state.Status = "expired"
+		}
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool {
+		if states[i].Owner != states[j].Owner {
+			return states[i].Owner < states[j].Owner
+		}
+		return states[i].ID < states[j].ID
+	})
+	return states
+}
@@ -0,0 +1,208 @@
+package apikey
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anz-bank/sysl-go/jwtauth"
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+)
+
+// writeKeys writes a keys file and moves its modification time forward, so
+// that the next reload reads it even within the timestamp resolution.
+func writeKeys(t *testing.T, path, keys string) {
+	t.Helper()
+	modTime := time.Now()
+	if info, err := os.Stat(path); err == nil {
+		modTime = info.ModTime().Add(time.Second)
+	}
+	require.NoError(t, os.WriteFile(path, []byte("keys:\n"+keys), 0o600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func keyEntry(id, key, extra string) string {
+	return fmt.Sprintf("  - id: %s\n    owner: owner-%s\n    hash: %s\n    scopes: [pets.read, pets.write]\n%s", id, id, Hash(key), extra)
+}
+
+func newStore(t *testing.T, keys string) (*Store, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	writeKeys(t, path, keys)
+	s, err := New(Config{File: path})
+	require.NoError(t, err)
+	return s, path
+}
+
+func requireRejected(t *testing.T, s *Store, key, cause string) {
+	t.Helper()
+	_, err := s.Authenticate(context.Background(), key)
+	var authErr *jwtauth.AuthError
+	require.ErrorAs(t, err, &authErr)
+	require.Equal(t, jwtauth.AuthErrCodeInvalidJWT, authErr.Code)
+	require.ErrorContains(t, err, cause)
+}
+
+func TestAuthenticate(t *testing.T) {
+	t.Parallel()
+
+	past, future := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339), time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	// Hex digits are matched case-insensitively.
+	upper := "  - id: upper\n    owner: owner-upper\n    hash: " + hashPrefix + strings.ToUpper(Hash("upper-key")[len(hashPrefix):]) + "\n"
+	s, _ := newStore(t, keyEntry("active", "active-key", "")+
+		keyEntry("expiring", "expiring-key", "    expires: "+future+"\n")+
+		keyEntry("expired", "expired-key", "    expires: "+past+"\n")+
+		keyEntry("pending", "pending-key", "    notBefore: "+future+"\n")+
+		keyEntry("started", "started-key", "    notBefore: "+past+"\n")+
+		keyEntry("revoked", "revoked-key", "    revoked: true\n")+
+		upper)
+
+	claims, err := s.Authenticate(context.Background(), "active-key")
+	require.NoError(t, err)
+	require.Equal(t, jwtauth.Claims{
+		"iss":       Issuer,
+		"sub":       "owner-active",
+		"scope":     "pets.read pets.write",
+		"apikey_id": "active",
+	}, claims)
+
+	claims, err = s.Authenticate(context.Background(), "expiring-key")
+	require.NoError(t, err)
+	require.Contains(t, claims, "exp")
+	_, err = s.Authenticate(context.Background(), "started-key")
+	require.NoError(t, err)
+	claims, err = s.Authenticate(context.Background(), "upper-key")
+	require.NoError(t, err)
+	require.Equal(t, "upper", claims["apikey_id"])
+
+	requireRejected(t, s, "unknown-key", "unknown api key")
+	requireRejected(t, s, "Active-key", "unknown api key")
+	requireRejected(t, s, "active-key ", "unknown api key")
+	requireRejected(t, s, Hash("active-key"), "unknown api key")
+	requireRejected(t, s, "expired-key", "api key expired has expired")
+	requireRejected(t, s, "pending-key", "api key pending is not valid yet")
+	requireRejected(t, s, "revoked-key", "api key revoked is revoked")
+}
+
+func TestReload(t *testing.T) {
+	t.Parallel()
+
+	s, path := newStore(t, keyEntry("a", "key-a", "")+keyEntry("b", "key-b", "")+keyEntry("c", "key-c", ""))
+	for _, key := range []string{"key-a", "key-b", "key-c"} {
+		_, err := s.Authenticate(context.Background(), key)
+		require.NoError(t, err)
+	}
+
+	// a is revoked in the file, b removed from it, c rotated to a new key
+	// and d added.
+	writeKeys(t, path, keyEntry("a", "key-a", "    revoked: true\n")+keyEntry("c", "key-c2", "")+keyEntry("d", "key-d", ""))
+	require.NoError(t, s.reload())
+	requireRejected(t, s, "key-a", "api key a is revoked")
+	requireRejected(t, s, "key-b", "unknown api key")
+	requireRejected(t, s, "key-c", "unknown api key")
+	for _, key := range []string{"key-c2", "key-d"} {
+		_, err := s.Authenticate(context.Background(), key)
+		require.NoError(t, err)
+	}
+
+	// An invalid file keeps the previous keys.
+	writeKeys(t, path, keyEntry("d", "key-d", "")+keyEntry("d", "key-e", ""))
+	require.ErrorContains(t, s.reload(), "duplicate id")
+	_, err := s.Authenticate(context.Background(), "key-d")
+	require.NoError(t, err)
+	requireRejected(t, s, "key-e", "unknown api key")
+}
+
+func TestRevoke(t *testing.T) {
+	t.Parallel()
+
+	s, path := newStore(t, keyEntry("a", "key-a", "")+keyEntry("b", "key-b", ""))
+	require.NoError(t, s.Revoke("a"))
+	require.ErrorContains(t, s.Revoke("x"), "unknown api key: x")
+	requireRejected(t, s, "key-a", "api key a is revoked")
+	_, err := s.Authenticate(context.Background(), "key-b")
+	require.NoError(t, err)
+
+	// Revocations outlive reloads, even if the key is still in the file.
+	writeKeys(t, path, keyEntry("a", "key-a", "")+keyEntry("b", "key-b", ""))
+	require.NoError(t, s.reload())
+	requireRejected(t, s, "key-a", "api key a is revoked")
+
+	states := s.States()
+	require.Len(t, states, 2)
+	require.Equal(t, "a", states[0].ID)
+	require.Equal(t, "revoked", states[0].Status)
+	require.Equal(t, "active", states[1].Status)
+}
+
+func TestAdminRevoke(t *testing.T) {
+	t.Parallel()
+
+	s, _ := newStore(t, keyEntry("a", "key-a", ""))
+	for _, tt := range []struct {
+		id   string
+		code int
+	}{{"a", 204}, {"x", 404}} {
+		w := httptest.NewRecorder()
+		adminRouter(s).ServeHTTP(w, httptest.NewRequest("DELETE", "/"+tt.id, nil))
+		require.Equal(t, tt.code, w.Code, tt.id)
+	}
+	requireRejected(t, s, "key-a", "revoked")
+}
+
+func TestValidateKeys(t *testing.T) {
+	t.Parallel()
+
+	hash := Hash("k")
+	tests := []struct {
+		keys string
+		err  string
+	}{
+		{"  - {owner: o, hash: " + hash + "}\n", "id must be set"},
+		{"  - {id: a, hash: " + hash + "}\n", "owner must be set"},
+		{"  - {id: a, owner: o, hash: " + strings.TrimPrefix(hash, hashPrefix) + "}\n", "hash must be sha256:"},
+		{"  - {id: a, owner: o, hash: sha256:abc}\n", "hash must be sha256:"},
+		{"  - {id: a, owner: o, hash: " + hash + "}\n  - {id: b, owner: o, hash: sha256:" + strings.ToUpper(hash[len(hashPrefix):]) + "}\n", "duplicate hash"},
+		{"  - {id: a, owner: o, hash: " + hash + ", notBefore: 2026-02-01T00:00:00Z, expires: 2026-01-01T00:00:00Z}\n", "expires must be after notBefore"},
+		{"  - {id: a, owner: o, hash: " + hash + ", scope: [x]}\n", "field scope not found"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "keys.yaml")
+		writeKeys(t, path, tt.keys)
+		_, err := New(Config{File: path})
+		require.ErrorContains(t, err, tt.err, tt.keys)
+	}
+}
+
+func TestFromRequestAndMetadata(t *testing.T) {
+	t.Parallel()
+
+	s, _ := newStore(t, keyEntry("a", "key-a", ""))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("x-api-key", "key-a")
+	require.Equal(t, "key-a", s.FromRequest(req))
+	require.Equal(t, "key-a", s.FromMetadata(metadata.Pairs("X-Api-Key", "key-a")))
+	require.Equal(t, "", s.FromMetadata(nil))
+
+	var disabled *Store
+	require.Equal(t, "", disabled.FromRequest(req))
+	require.Equal(t, "", disabled.FromMetadata(metadata.Pairs("x-api-key", "key-a")))
+	_, err := disabled.Authenticate(context.Background(), "key-a")
+	var authErr *jwtauth.AuthError
+	require.ErrorAs(t, err, &authErr)
+	require.Equal(t, jwtauth.AuthErrCodeUntrustedSource, authErr.Code)
+}
+
+func adminRouter(s *Store) http.Handler {
+	r := chi.NewRouter()
+	s.WireAdminRoutes(r)
+	return r
+}
@@ -0,0 +1,110 @@
+package apikey
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	defaultReloadInterval = 10 * time.Second
+	defaultHeader         = "X-API-Key"
+	hashPrefix            = "sha256:"
+)
+
+// Config configures API key authentication, found under app.auth.apiKeys.
+type Config struct {
+	// File names a YAML file holding the keys under a top-level keys key.
+	// The file is polled and reloaded live.
+	File           string        `yaml:"file" mapstructure:"file"`
+	ReloadInterval time.Duration `yaml:"reloadInterval" mapstructure:"reloadInterval"`
+
+	// Header is the request header carrying the key, X-API-Key by default.
+	Header string `yaml:"header" mapstructure:"header"`
+}
+
+// Key describes a single API key. Only the hash of the key is stored.
+//
+// To rotate a key without downtime, add the new key with the same owner and
+// set Expires on the old one: both are accepted until the old one expires.
+type Key struct {
+	// ID identifies the key on the admin server and in logs.
+	ID    string `yaml:"id" json:"id"`
+	Owner string `yaml:"owner" json:"owner"`
+	// Hash is "sha256:" followed by the hex encoded SHA-256 of the key.
+	Hash   string   `yaml:"hash" json:"-"`
+	Scopes []string `yaml:"scopes" json:"scopes"`
+
+	// NotBefore and Expires optionally bound when the key is accepted.
+	NotBefore *time.Time `yaml:"notBefore" json:"notBefore,omitempty"`
+	Expires   *time.Time `yaml:"expires" json:"expires,omitempty"`
+	// Revoked keys are never accepted.
+	Revoked bool `yaml:"revoked" json:"revoked"`
+}
+
+func (c *Config) setDefaults() {
+	if c.ReloadInterval == 0 {
+		c.ReloadInterval = defaultReloadInterval
+	}
+	if c.Header == "" {
+		c.Header = defaultHeader
+	}
+}
+
+// Hash returns the value to store in Key.Hash for the given key.
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hashPrefix + hex.EncodeToString(sum[:])
+}
+
+func validateKeys(keys []Key) error {
+	ids := make(map[string]bool, len(keys))
+	hashes := make(map[string]bool, len(keys))
+	for i, k := range keys {
+		if k.ID == "" {
+			return fmt.Errorf("key %d: id must be set", i)
+		}
+		if ids[k.ID] {
+			return fmt.Errorf("key %s: duplicate id", k.ID)
+		}
+		ids[k.ID] = true
+		if k.Owner == "" {
+			return fmt.Errorf("key %s: owner must be set", k.ID)
+		}
+		digest := strings.TrimPrefix(k.Hash, hashPrefix)
+		if b, err := hex.DecodeString(digest); err != nil || len(b) != sha256.Size || !strings.HasPrefix(k.Hash, hashPrefix) {
+			return fmt.Errorf("key %s: hash must be %s followed by 64 hex digits", k.ID, hashPrefix)
+		}
+		if hashes[strings.ToLower(digest)] {
+			return fmt.Errorf("key %s: duplicate hash", k.ID)
+		}
+		hashes[strings.ToLower(digest)] = true
+		if k.NotBefore != nil && k.Expires != nil && !k.Expires.After(*k.NotBefore) {
+			return fmt.Errorf("key %s: expires must be after notBefore", k.ID)
+		}
+	}
+	return nil
+}
+
+// readKeysFile reads the keys held in the given file.
+func readKeysFile(path string) ([]Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Keys []Key `yaml:"keys"`
+	}
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, fmt.Errorf("api key file %s: %w", path, err)
+	}
+	if err := validateKeys(file.Keys); err != nil {
+		return nil, fmt.Errorf("api key file %s: %w", path, err)
+	}
+	return file.Keys, nil
+}
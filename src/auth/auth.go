@@ -0,0 +1,293 @@
+// Package auth enforces per-endpoint authorization expressions on the
+// Petdemo REST endpoints, and on the gRPC methods serving them (see
+// Authorizer.GRPCServerOptions).
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/anz-bank/sysl-go-demo/src/apikey"
+	"github.com/anz-bank/sysl-go-demo/src/mtls"
+	"github.com/anz-bank/sysl-go/common"
+	"github.com/anz-bank/sysl-go/config"
+	"github.com/anz-bank/sysl-go/core"
+	"github.com/anz-bank/sysl-go/core/authrules"
+	"github.com/anz-bank/sysl-go/jwtauth"
+	"github.com/anz-bank/sysl-go/jwtauth/jwtgrpc"
+	"github.com/anz-bank/sysl-go/log"
+	"github.com/go-chi/chi"
+)
+
+const defaultRealm = "Petdemo"
+
+// Config declares the authorization rules of the service, found under app.auth.
+type Config struct {
+	// Realm is reported in the WWW-Authenticate header of rejected requests.
+	Realm     string     `yaml:"realm" mapstructure:"realm"`
+	Endpoints []Endpoint `yaml:"endpoints" mapstructure:"endpoints"`
+	// Issuers whose keys are configured locally rather than fetched from a
+	// JWKS endpoint. Tokens from other issuers are verified with the config
+	// under library.authentication.jwtauth.
+	Issuers []IssuerConfig `yaml:"issuers" mapstructure:"issuers"`
+	// APIKeys lets callers present an API key instead of a JWT.
+	APIKeys apikey.Config `yaml:"apiKeys" mapstructure:"apiKeys"`
+	// GRPCMethods protects the gRPC methods that serve no REST endpoint.
+	// Those serving REST endpoints are protected by the endpoint rules.
+	GRPCMethods []GRPCMethod `yaml:"grpcMethods" mapstructure:"grpcMethods"`
+}
+
+// Endpoint attaches an authorization expression (see
+// MakeJWTClaimsBasedAuthorizationRule) to an endpoint. Path is a route pattern
+// relative to the base path, as written in the sysl spec, e.g. /pet or /pet/{id}.
+type Endpoint struct {
+	Method string `yaml:"method" mapstructure:"method"`
+	Path   string `yaml:"path" mapstructure:"path"`
+	Rule   string `yaml:"rule" mapstructure:"rule"`
+}
+
+// Authorizer holds the compiled rule of every protected endpoint and gRPC
+// method. Endpoints and methods without a rule are not protected.
+type Authorizer struct {
+	realm       string
+	routes      *chi.Mux
+	rules       map[string]endpointRule
+	grpcMethods map[string]endpointRule
+	apiKeys     *apikey.Store
+}
+
+// endpointRule authorizes requests to an endpoint, whether they carry a JWT,
+// an API key or only a client certificate.
+type endpointRule struct {
+	jwt     authrules.Rule
+	grpcJWT authrules.Rule
+	claims  authrules.JWTClaimsBasedAuthorizationRule
+	// peer authorizes requests without credentials, see makePeerRule. It is
+	// nil for rules compiled by a hook, which cannot be evaluated without
+	// claims safely.
+	peer func(ctx context.Context) bool
+}
+
+// NewAuthorizer compiles the configured rules, authenticating bearer tokens
+// with the local issuers in cfg and the issuers configured under
+// library.authentication.jwtauth, and API keys with apiKeys, which may be nil.
+//
+// Expressions are compiled with hooks.OverrideMakeJWTClaimsBasedAuthorizationRule
+// if set, and MakeJWTClaimsBasedAuthorizationRule otherwise.
+func NewAuthorizer(ctx context.Context, hooks *core.Hooks, cfg Config, apiKeys *apikey.Store) (*Authorizer, error) {
+	a := &Authorizer{
+		realm:       cfg.Realm,
+		routes:      chi.NewRouter(),
+		rules:       make(map[string]endpointRule),
+		grpcMethods: make(map[string]endpointRule),
+		apiKeys:     apiKeys,
+	}
+	if a.realm == "" {
+		a.realm = defaultRealm
+	}
+	if len(cfg.Endpoints) == 0 && len(cfg.GRPCMethods) == 0 {
+		return a, nil
+	}
+
+	defaultConfig := config.GetDefaultConfig(ctx)
+	if defaultConfig != nil && defaultConfig.Development != nil && defaultConfig.Development.DisableAllAuthorizationRules {
+		log.Info(ctx, "warning: development.disableAllAuthorizationRules is set, all authorization rules are disabled, this is insecure and should not be used in production.")
+		return a, nil
+	}
+	var jwtAuthConfig *jwtauth.Config
+	if defaultConfig != nil && defaultConfig.Library.Authentication != nil {
+		jwtAuthConfig = defaultConfig.Library.Authentication.JWTAuth
+	}
+	if jwtAuthConfig == nil && len(cfg.Issuers) == 0 && apiKeys == nil {
+		return nil, fmt.Errorf("app.auth declares authorization rules, but there is no config for app.auth.issuers, app.auth.apiKeys or library.authentication.jwtauth")
+	}
+
+	authenticator, err := newAuthenticator(ctx, cfg.Issuers, jwtAuthConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	makeClaimsRule := MakeJWTClaimsBasedAuthorizationRule
+	makePeer := makePeerRule
+	if hooks != nil && hooks.OverrideMakeJWTClaimsBasedAuthorizationRule != nil {
+		makeClaimsRule = hooks.OverrideMakeJWTClaimsBasedAuthorizationRule
+		makePeer = nil
+	}
+
+	for _, e := range cfg.Endpoints {
+		method := strings.ToUpper(e.Method)
+		key := method + " " + e.Path
+		if _, exists := a.rules[key]; exists {
+			return nil, fmt.Errorf("app.auth: duplicate rule for endpoint %s", key)
+		}
+		rule, err := compileRule(makeClaimsRule, makePeer, e.Rule, authenticator)
+		if err != nil {
+			return nil, fmt.Errorf("app.auth: endpoint %s: %w", key, err)
+		}
+		a.rules[key] = rule
+		a.routes.MethodFunc(method, e.Path, http.NotFound)
+	}
+	for _, m := range cfg.GRPCMethods {
+		if _, exists := a.grpcMethods[m.Method]; exists {
+			return nil, fmt.Errorf("app.auth: duplicate rule for gRPC method %s", m.Method)
+		}
+		rule, err := compileRule(makeClaimsRule, makePeer, m.Rule, authenticator)
+		if err != nil {
+			return nil, fmt.Errorf("app.auth: gRPC method %s: %w", m.Method, err)
+		}
+		a.grpcMethods[m.Method] = rule
+	}
+	return a, nil
+}
+
+// compileRule compiles an expression into the rules of a REST endpoint and a
+// gRPC method. Without makePeer, requests are never authorized on their
+// client certificate alone.
+func compileRule(
+	makeClaimsRule func(string) (authrules.JWTClaimsBasedAuthorizationRule, error),
+	makePeer func(string) (func(context.Context) bool, error),
+	expression string,
+	authenticator jwtauth.Authenticator,
+) (endpointRule, error) {
+	claimsRule, err := makeClaimsRule(expression)
+	if err != nil {
+		return endpointRule{}, err
+	}
+	var peerRule func(context.Context) bool
+	if makePeer != nil {
+		if peerRule, err = makePeer(expression); err != nil {
+			return endpointRule{}, err
+		}
+	}
+	rule, err := authrules.MakeRESTJWTAuthorizationRule(claimsRule, authenticator)
+	if err != nil {
+		return endpointRule{}, err
+	}
+	grpcRule, err := authrules.MakeGRPCJWTAuthorizationRule(claimsRule, authenticator)
+	if err != nil {
+		return endpointRule{}, err
+	}
+	return endpointRule{jwt: rule, grpcJWT: grpcRule, claims: claimsRule, peer: peerRule}, nil
+}
+
+// newAuthenticator creates a single authenticator shared by every endpoint.
+func newAuthenticator(ctx context.Context, issuers []IssuerConfig, cfg *jwtauth.Config) (jwtauth.Authenticator, error) {
+	a := &authenticator{local: make(map[string]*localIssuer, len(issuers))}
+	for _, c := range issuers {
+		issuer, err := newLocalIssuer(c)
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := a.local[issuer.name]; exists {
+			return nil, fmt.Errorf("app.auth.issuers: duplicate issuer %s", issuer.name)
+		}
+		a.local[issuer.name] = issuer
+	}
+	if cfg != nil {
+		httpClient, err := config.DefaultHTTPClient(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		a.remote, err = jwtauth.AuthFromConfig(ctx, cfg, func(string) *http.Client { return httpClient })
+		if err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// Middleware rejects requests to protected endpoints that do not satisfy the
+// endpoint's rule, and otherwise adds the caller's claims to the request
+// context. Requests without a token or API key are authorized by their client
+// certificate, if mtls.Middleware is installed before it. It has the signature
+// of core.Hooks.AddHTTPMiddleware.
+func (a *Authorizer) Middleware(_ context.Context, r chi.Router) {
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			rule, ok := a.ruleFor(req)
+			if !ok {
+				next.ServeHTTP(w, req)
+				return
+			}
+			ctx := common.RequestHeaderToContext(req.Context(), req.Header)
+			authCtx, err := a.authorize(ctx, rule, rule.jwt, a.apiKeys.FromRequest(req), req.Header.Get("Authorization"))
+			if err != nil {
+				log.Debugf(ctx, "auth: %s %s rejected: %v", req.Method, req.URL.Path, err)
+				newAuthError(a.realm, err).WriteError(ctx, w)
+				return
+			}
+			next.ServeHTTP(w, req.WithContext(authCtx))
+		})
+	})
+}
+
+// authorize authorizes a request by its API key if it has one, then by the
+// client certificate if it has no Authorization header, and otherwise by its
+// JWT with jwtRule, the REST or gRPC rule of the endpoint. authorization is
+// the value of the Authorization header of the request.
+func (a *Authorizer) authorize(ctx context.Context, rule endpointRule, jwtRule authrules.Rule, apiKey, authorization string) (context.Context, error) {
+	switch {
+	case apiKey != "":
+		return a.authorizeAPIKey(ctx, apiKey, rule.claims)
+	case authorization == "" && authorizePeer(ctx, rule.peer):
+		return ctx, nil
+	case !hasBearerToken(authorization):
+		return ctx, errNoBearerToken
+	default:
+		return jwtRule(ctx)
+	}
+}
+
+// hasBearerToken reports whether the value of an Authorization header holds a
+// bearer token, which authrules reads case-insensitively.
+func hasBearerToken(authorization string) bool {
+	const scheme = "bearer "
+	return len(authorization) > len(scheme) && strings.EqualFold(authorization[:len(scheme)], scheme)
+}
+
+// authorizeAPIKey evaluates the claims of an API key against an endpoint's rule.
+func (a *Authorizer) authorizeAPIKey(ctx context.Context, key string, rule authrules.JWTClaimsBasedAuthorizationRule) (context.Context, error) {
+	claims, err := a.apiKeys.Authenticate(ctx, key)
+	if err != nil {
+		return ctx, err
+	}
+	authorized, err := rule(ctx, claims)
+	if err != nil {
+		return ctx, err
+	}
+	if !authorized {
+		return ctx, jwtgrpc.ErrClaimsValidationFailed
+	}
+	return jwtauth.AddClaimsToContext(ctx, claims), nil
+}
+
+// authorizePeer reports whether the verified client certificate of a request
+// without credentials satisfies an endpoint's peer rule on its own.
+func authorizePeer(ctx context.Context, rule func(context.Context) bool) bool {
+	if rule == nil {
+		return false
+	}
+	if _, ok := mtls.FromContext(ctx); !ok {
+		return false
+	}
+	return rule(ctx)
+}
+
+// ruleFor returns the rule protecting the endpoint req is routed to, if any.
+func (a *Authorizer) ruleFor(req *http.Request) (endpointRule, bool) {
+	if len(a.rules) == 0 {
+		return endpointRule{}, false
+	}
+	path := req.URL.Path
+	if rctx := chi.RouteContext(req.Context()); rctx != nil && rctx.RoutePath != "" {
+		path = rctx.RoutePath
+	}
+	rctx := chi.NewRouteContext()
+	if !a.routes.Match(rctx, req.Method, path) {
+		return endpointRule{}, false
+	}
+	rule, ok := a.rules[req.Method+" "+rctx.RoutePattern()]
+	return rule, ok
+}
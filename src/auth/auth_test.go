@@ -0,0 +1,271 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/anz-bank/sysl-go/config"
+	"github.com/anz-bank/sysl-go/core"
+	"github.com/anz-bank/sysl-go/core/authrules"
+	"github.com/anz-bank/sysl-go/jwtauth"
+	"github.com/anz-bank/sysl-go/log"
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"gopkg.in/square/go-jose.v2"
+
+	"github.com/anz-bank/sysl-go-demo/src/mtls"
+)
+
+const getPetMethod = "/petdemo.PetService/GetPet"
+
+// authCase is a request to GET /pet, authorized alike over REST and gRPC.
+type authCase struct {
+	name string
+	rule string
+	// override compiles the rule with
+	// hooks.OverrideMakeJWTClaimsBasedAuthorizationRule.
+	override bool
+	// peerCN is the common name of the verified client certificate, if any.
+	peerCN string
+	// token holds the claims of the bearer token, if any.
+	token map[string]interface{}
+
+	status int
+	code   codes.Code
+}
+
+func authCases() []authCase {
+	token := func(sub, scope string) map[string]interface{} {
+		return with(with(claimsAt(time.Hour, 0), "sub", sub), "scope", scope)
+	}
+	const (
+		ok           = http.StatusOK
+		unauthorized = http.StatusUnauthorized
+		forbidden    = http.StatusForbidden
+	)
+	return []authCase{
+		{name: "peer rule", rule: `mtls_peer_equals("cn", "batch")`, peerCN: "batch", status: ok},
+		{name: "peer rule, other peer", rule: `mtls_peer_equals("cn", "batch")`, peerCN: "other", status: unauthorized, code: codes.Unauthenticated},
+		{name: "peer rule, no peer", rule: `mtls_peer_equals("cn", "batch")`, status: unauthorized, code: codes.Unauthenticated},
+		{name: "negated peer rule", rule: `not(mtls_peer_equals("cn", "other"))`, peerCN: "batch", status: ok},
+		{name: "negated peer rule, no peer", rule: `not(mtls_peer_equals("cn", "other"))`, status: unauthorized, code: codes.Unauthenticated},
+
+		// Rules on claims are not granted by a client certificate, even when
+		// negated.
+		{name: "scope rule, peer", rule: `jwtHasScope("pets.read")`, peerCN: "batch", status: unauthorized, code: codes.Unauthenticated},
+		{name: "negated scope, peer", rule: `not(jwtHasScope("pets.write"))`, peerCN: "batch", status: unauthorized, code: codes.Unauthenticated},
+		{name: "negated claim, peer", rule: `not(jwt_claim_equals("sub", "banned"))`, peerCN: "batch", status: unauthorized, code: codes.Unauthenticated},
+		{name: "double negation, peer", rule: `not(not(jwtHasScope("pets.read")))`, peerCN: "batch", status: unauthorized, code: codes.Unauthenticated},
+		{name: "negated any, peer", rule: `not(any(jwt_claim_equals("sub", "banned"), mtls_peer_equals("cn", "other")))`, peerCN: "batch", status: unauthorized, code: codes.Unauthenticated},
+		{name: "negated claim, token", rule: `not(jwt_claim_equals("sub", "banned"))`, token: token("jo", ""), status: ok},
+		{name: "negated claim, banned token", rule: `not(jwt_claim_equals("sub", "banned"))`, token: token("banned", ""), status: forbidden, code: codes.PermissionDenied},
+
+		// Mixed rules.
+		{name: "any, peer", rule: `any(jwtHasScope("pets.read"), mtls_peer_equals("cn", "batch"))`, peerCN: "batch", status: ok},
+		{name: "any, other peer", rule: `any(jwtHasScope("pets.read"), mtls_peer_equals("cn", "batch"))`, peerCN: "other", status: unauthorized, code: codes.Unauthenticated},
+		{name: "any, token", rule: `any(jwtHasScope("pets.read"), mtls_peer_equals("cn", "batch"))`, token: token("jo", "pets.read"), status: ok},
+		{name: "any with negated claim, peer", rule: `any(not(jwtHasScope("x")), mtls_peer_equals("cn", "batch"))`, peerCN: "batch", status: ok},
+		{name: "any with negated claim, other peer", rule: `any(not(jwtHasScope("x")), mtls_peer_equals("cn", "batch"))`, peerCN: "other", status: unauthorized, code: codes.Unauthenticated},
+		{name: "all, peer", rule: `all(mtls_peer_equals("cn", "batch"), not(jwt_claim_equals("sub", "banned")))`, peerCN: "batch", status: unauthorized, code: codes.Unauthenticated},
+		{name: "all, peer and token", rule: `all(mtls_peer_equals("cn", "batch"), not(jwt_claim_equals("sub", "banned")))`, peerCN: "batch", token: token("jo", ""), status: ok},
+		{name: "all, peer and banned token", rule: `all(mtls_peer_equals("cn", "batch"), not(jwt_claim_equals("sub", "banned")))`, peerCN: "batch", token: token("banned", ""), status: forbidden, code: codes.PermissionDenied},
+		{name: "all, other peer and token", rule: `all(mtls_peer_equals("cn", "batch"), not(jwt_claim_equals("sub", "banned")))`, peerCN: "other", token: token("jo", ""), status: forbidden, code: codes.PermissionDenied},
+		{name: "all on peer only", rule: `all(mtls_peer_prefix("cn", "ba"), not(mtls_peer_equals("cn", "bad")))`, peerCN: "batch", status: ok},
+
+		// Rules compiled by a hook cannot be evaluated without claims.
+		{name: "hook rule, peer", rule: "anything", override: true, peerCN: "batch", status: unauthorized, code: codes.Unauthenticated},
+		{name: "hook rule, token", rule: "anything", override: true, token: token("jo", ""), status: ok},
+	}
+}
+
+// newTestAuthorizer protects GET /pet, and the gRPC method serving it, with rule.
+func newTestAuthorizer(t *testing.T, tc authCase) *Authorizer {
+	t.Helper()
+	hooks := &core.Hooks{}
+	if tc.override {
+		hooks.OverrideMakeJWTClaimsBasedAuthorizationRule = func(string) (authrules.JWTClaimsBasedAuthorizationRule, error) {
+			return func(context.Context, jwtauth.Claims) (bool, error) { return true, nil }, nil
+		}
+	}
+	a, err := NewAuthorizer(context.Background(), hooks, Config{
+		Issuers:   []IssuerConfig{{Name: "petdemo-test", SharedSecret: config.NewSensitiveString(testSecret)}},
+		Endpoints: []Endpoint{{Method: "GET", Path: "/pet", Rule: tc.rule}},
+	}, nil)
+	require.NoError(t, err)
+	return a
+}
+
+func peerState(cn string) *tls.ConnectionState {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+	return &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+}
+
+func withLogger(ctx context.Context) context.Context {
+	return log.PutLogger(ctx, log.NewDefaultLogger())
+}
+
+func TestMiddlewarePeerRules(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range authCases() {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			a := newTestAuthorizer(t, tc)
+			r := chi.NewRouter()
+			r.Use(func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+					next.ServeHTTP(w, req.WithContext(withLogger(req.Context())))
+				})
+			})
+			mtls.Middleware(context.Background(), r)
+			a.Middleware(context.Background(), r)
+			var claims jwtauth.Claims
+			r.Get("/pet", func(w http.ResponseWriter, req *http.Request) {
+				claims, _ = jwtauth.GetClaimsFromContext(req.Context())
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/pet", nil)
+			if tc.peerCN != "" {
+				req.TLS = peerState(tc.peerCN)
+			}
+			if tc.token != nil {
+				req.Header.Set("Authorization", "Bearer "+sign(t, jose.HS256, []byte(testSecret), "", tc.token))
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			require.Equal(t, tc.status, w.Code, w.Body.String())
+			if tc.status == http.StatusOK && tc.token != nil {
+				require.Equal(t, tc.token["sub"], claims["sub"])
+			}
+		})
+	}
+}
+
+func TestGRPCPeerRules(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range authCases() {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			a := newTestAuthorizer(t, tc)
+			rules, err := a.grpcRules(map[string]string{getPetMethod: "GET /pet"})
+			require.NoError(t, err)
+
+			ctx := withLogger(context.Background())
+			if tc.peerCN != "" {
+				ctx = peer.NewContext(ctx, &peer.Peer{AuthInfo: credentials.TLSInfo{State: *peerState(tc.peerCN)}})
+			}
+			if tc.token != nil {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer "+sign(t, jose.HS256, []byte(testSecret), "", tc.token)))
+			}
+			info := &grpc.UnaryServerInfo{FullMethod: getPetMethod}
+			var claims jwtauth.Claims
+			_, err = a.unaryInterceptor(rules)(ctx, nil, info, func(ctx context.Context, _ interface{}) (interface{}, error) {
+				claims, _ = jwtauth.GetClaimsFromContext(ctx)
+				return nil, nil
+			})
+			require.Equal(t, tc.code, status.Code(err), "%v", err)
+
+			streamInfo := &grpc.StreamServerInfo{FullMethod: getPetMethod, IsServerStream: true}
+			err = a.streamInterceptor(rules)(nil, &testStream{ctx: ctx}, streamInfo, func(interface{}, grpc.ServerStream) error { return nil })
+			require.Equal(t, tc.code, status.Code(err), "%v", err)
+
+			if tc.code == codes.OK && tc.token != nil {
+				require.Equal(t, tc.token["sub"], claims["sub"])
+			}
+		})
+	}
+}
+
+func TestGRPCRules(t *testing.T) {
+	t.Parallel()
+
+	a, err := NewAuthorizer(context.Background(), nil, Config{
+		Issuers:     []IssuerConfig{{Name: "petdemo-test", SharedSecret: config.NewSensitiveString(testSecret)}},
+		Endpoints:   []Endpoint{{Method: "GET", Path: "/pet", Rule: `jwtHasScope("pets.read")`}},
+		GRPCMethods: []GRPCMethod{{Method: "/grpc.testing.Foo/thisEndpoint", Rule: `jwtHasScope("diagnostics")`}},
+	}, nil)
+	require.NoError(t, err)
+
+	rules, err := a.grpcRules(map[string]string{getPetMethod: "GET /pet", "/petdemo.PetService/Other": "GET /other"})
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	require.Contains(t, rules, getPetMethod)
+	require.Contains(t, rules, "/grpc.testing.Foo/thisEndpoint")
+
+	// Unprotected methods are served without credentials.
+	called := false
+	_, err = a.unaryInterceptor(rules)(withLogger(context.Background()), nil, &grpc.UnaryServerInfo{FullMethod: "/grpc.testing.Bar/AnotherEndpoint"}, func(context.Context, interface{}) (interface{}, error) {
+		called = true
+		return nil, nil
+	})
+	require.NoError(t, err)
+	require.True(t, called)
+
+	_, err = a.grpcRules(map[string]string{"/grpc.testing.Foo/thisEndpoint": "GET /pet"})
+	require.ErrorContains(t, err, "/grpc.testing.Foo/thisEndpoint serves GET /pet")
+}
+
+// testStream is a server stream with a context.
+type testStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *testStream) Context() context.Context { return s.ctx }
+
+func TestMiddlewareChallenge(t *testing.T) {
+	t.Parallel()
+
+	a := newTestAuthorizer(t, authCase{rule: `jwtHasScope("pets.read")`})
+	r := chi.NewRouter()
+	a.Middleware(context.Background(), r)
+	r.Get("/pet", func(http.ResponseWriter, *http.Request) {})
+	token := func(scope string) string {
+		return "Bearer " + sign(t, jose.HS256, []byte(testSecret), "", with(claimsAt(time.Hour, 0), "scope", scope))
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		status        int
+		challenge     string
+	}{
+		// Requests without a bearer token are challenged without an error code.
+		{"no header", "", http.StatusUnauthorized, `Bearer realm="Petdemo"`},
+		{"other scheme", "Basic am86c2VjcmV0", http.StatusUnauthorized, `Bearer realm="Petdemo"`},
+		{"empty token", "Bearer ", http.StatusUnauthorized, `Bearer realm="Petdemo"`},
+		{"invalid token", "Bearer not-a-jwt", http.StatusUnauthorized, `Bearer realm="Petdemo", error="invalid_token"`},
+		{"lower case scheme", "bearer not-a-jwt", http.StatusUnauthorized, `Bearer realm="Petdemo", error="invalid_token"`},
+		{"insufficient scope", token("pets.write"), http.StatusForbidden, `Bearer realm="Petdemo", error="insufficient_scope"`},
+		{"authorized", token("pets.read"), http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(http.MethodGet, "/pet", nil).WithContext(withLogger(context.Background()))
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			require.Equal(t, tt.status, w.Code, w.Body.String())
+			require.Equal(t, tt.challenge, w.Header().Get("WWW-Authenticate"))
+		})
+	}
+}
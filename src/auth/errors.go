@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/anz-bank/sysl-go/common"
+	"github.com/anz-bank/sysl-go/jwtauth"
+	"github.com/anz-bank/sysl-go/jwtauth/jwtgrpc"
+)
+
+// authError is a rejected authorization, written as a 401 or 403 response
+// carrying a WWW-Authenticate challenge (RFC 6750). It implements
+// common.ErrorWriter.
+type authError struct {
+	status    int
+	challenge string
+	cause     error
+}
+
+func newAuthError(realm string, cause error) *authError {
+	e := &authError{cause: cause}
+	var authErr *jwtauth.AuthError
+	switch {
+	case errors.Is(cause, jwtgrpc.ErrClaimsValidationFailed):
+		e.status = http.StatusForbidden
+		e.challenge = fmt.Sprintf(`Bearer realm=%q, error="insufficient_scope"`, realm)
+	case errors.Is(cause, errNoBearerToken):
+		// No credentials at all: challenge without an error code.
+		e.status = http.StatusUnauthorized
+		e.challenge = fmt.Sprintf(`Bearer realm=%q`, realm)
+	case errors.As(cause, &authErr):
+		e.status = authErr.HTTPStatus()
+		e.challenge = fmt.Sprintf(`Bearer realm=%q, error="invalid_token"`, realm)
+	default:
+		// The rule itself failed to evaluate, so access cannot be granted.
+		e.status = http.StatusForbidden
+		e.challenge = fmt.Sprintf(`Bearer realm=%q, error="insufficient_scope"`, realm)
+	}
+	return e
+}
+
+// errNoBearerToken rejects requests without a bearer token before their rule
+// is evaluated, as authrules reports them with an error told apart from
+// invalid tokens only by its message.
+var errNoBearerToken = &jwtauth.AuthError{
+	Code:  jwtauth.AuthErrCodeInvalidJWT,
+	Cause: errors.New("no Authorization header containing bearer token"),
+}
+
+func (e *authError) Error() string {
+	return fmt.Sprintf("authorization failed (%d): %v", e.status, e.cause)
+}
+
+func (e *authError) Unwrap() error {
+	return e.cause
+}
+
+// WriteError writes the rejection and reports that it was handled.
+func (e *authError) WriteError(ctx context.Context, w http.ResponseWriter) bool {
+	httpError := common.HTTPError{HTTPCode: e.status, Code: "1003", Description: "Unauthorized error"}
+	switch e.status {
+	case http.StatusUnauthorized:
+		w.Header().Set("WWW-Authenticate", e.challenge)
+	case http.StatusForbidden:
+		w.Header().Set("WWW-Authenticate", e.challenge)
+		httpError.Code, httpError.Description = "1004", "Forbidden"
+	default:
+		httpError.Code, httpError.Description = "9998", "Internal Server Error"
+	}
+	httpError.WriteError(ctx, w)
+	return true
+}
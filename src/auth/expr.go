@@ -0,0 +1,289 @@
+package auth
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/alecthomas/participle"
+	"github.com/anz-bank/sysl-go-demo/src/claims"
+	"github.com/anz-bank/sysl-go-demo/src/mtls"
+	"github.com/anz-bank/sysl-go/authexpr"
+	"github.com/anz-bank/sysl-go/core/authrules"
+	"github.com/anz-bank/sysl-go/jwtauth"
+)
+
+// exprParser parses the authexpr grammar. The grammar is reused as is, only
+// the set of atoms accepted by compileExpr is larger than authexpr's.
+var exprParser = participle.MustBuild(&authexpr.Expr{}, participle.UseLookahead(2))
+
+// predicate is a compiled authorization expression.
+type predicate func(ctx context.Context, claims jwtauth.Claims) bool
+
+// truth is the value of an expression evaluated on the client certificate
+// alone. Atoms on claims are unknown rather than false, and stay unknown
+// under not, so that an expression only holds without claims if it holds
+// whatever the claims would be.
+type truth int8
+
+const (
+	unknown truth = iota
+	no
+	yes
+)
+
+// peerPredicate is a compiled authorization expression evaluated without
+// claims, see truth.
+type peerPredicate func(ctx context.Context) truth
+
+// compiled is an expression compiled for requests with and without claims.
+type compiled struct {
+	eval predicate
+	peer peerPredicate
+}
+
+func truthOf(b bool) truth {
+	if b {
+		return yes
+	}
+	return no
+}
+
+// MakeJWTClaimsBasedAuthorizationRule compiles an authorization expression.
+// In addition to the all, any, not and jwtHasScope of the authexpr package,
+// expressions may use these atoms on any claim, with nested claims addressed
+// by dotted paths such as "org.tenant":
+//
+//	jwt_claim_equals("tenant", "acme")     the claim equals the value
+//	jwt_claim_in("role", "admin", "vet")   the claim equals one of the values
+//	jwt_claim_prefix("sub", "svc-")        the claim starts with the prefix
+//	jwt_claim_regex("email", ".*@acme\\.com") the whole claim matches the regexp
+//
+// Claims holding an array match if any of their elements match. Missing
+// claims never match.
+//
+// The mtls_peer_equals, mtls_peer_in, mtls_peer_prefix and mtls_peer_regex
+// atoms match the verified client certificate of the request (see the mtls
+// package) in the same way, selecting one of its fields by name: subject, cn,
+// dns, uri, email, ip or spiffe_id. For example:
+//
+//	mtls_peer_equals("spiffe_id", "spiffe://acme.com/ns/batch/sa/reconciler")
+//
+// Requests without a token or API key are authorized by their client
+// certificate only if those atoms grant access whatever the claims would be.
+//
+// Unknown atoms, wrong arguments and invalid regular expressions are reported
+// here rather than when a request is authorized.
+func MakeJWTClaimsBasedAuthorizationRule(expression string) (authrules.JWTClaimsBasedAuthorizationRule, error) {
+	c, err := compile(expression)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, claims jwtauth.Claims) (bool, error) {
+		return c.eval(ctx, claims), nil
+	}, nil
+}
+
+// makePeerRule compiles an expression to authorize requests that carry no
+// claims on their client certificate alone. The rule only holds if the
+// mtls_peer_* atoms of the expression make it hold whatever the claims, so
+// that not(jwt_claim_equals(...)) or all(mtls_peer_equals(...),
+// not(jwtHasScope(...))) still require a token.
+func makePeerRule(expression string) (func(ctx context.Context) bool, error) {
+	c, err := compile(expression)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context) bool {
+		return c.peer(ctx) == yes
+	}, nil
+}
+
+func compile(expression string) (compiled, error) {
+	root := &authexpr.Expr{}
+	if err := exprParser.ParseString(expression, root); err != nil {
+		return compiled{}, authexpr.ParseFailed("failed to parse auth expression").WithCause(err)
+	}
+	return compileExpr(root)
+}
+
+func compileExpr(e *authexpr.Expr) (compiled, error) {
+	if e.OpExpr != nil {
+		return compileOp(e.OpExpr)
+	}
+	return compileAtom(e.AtomExpr)
+}
+
+func compileOp(e *authexpr.OpExpr) (compiled, error) {
+	args := make([]compiled, len(e.Args))
+	for i, arg := range e.Args {
+		c, err := compileExpr(arg)
+		if err != nil {
+			return compiled{}, err
+		}
+		args[i] = c
+	}
+	switch e.Name {
+	case "not":
+		if len(args) != 1 {
+			return compiled{}, authexpr.ValidationFailed("not(...) OpExpr must be called with exactly one argument")
+		}
+		return compiled{
+			eval: func(ctx context.Context, claims jwtauth.Claims) bool { return !args[0].eval(ctx, claims) },
+			peer: func(ctx context.Context) truth {
+				switch args[0].peer(ctx) {
+				case yes:
+					return no
+				case no:
+					return yes
+				}
+				return unknown
+			},
+		}, nil
+	case "any":
+		return compiled{
+			eval: func(ctx context.Context, claims jwtauth.Claims) bool {
+				for _, c := range args {
+					if c.eval(ctx, claims) {
+						return true
+					}
+				}
+				return false
+			},
+			peer: func(ctx context.Context) truth {
+				result := no
+				for _, c := range args {
+					switch c.peer(ctx) {
+					case yes:
+						return yes
+					case unknown:
+						result = unknown
+					}
+				}
+				return result
+			},
+		}, nil
+	case "all":
+		return compiled{
+			eval: func(ctx context.Context, claims jwtauth.Claims) bool {
+				for _, c := range args {
+					if !c.eval(ctx, claims) {
+						return false
+					}
+				}
+				return true
+			},
+			peer: func(ctx context.Context) truth {
+				result := yes
+				for _, c := range args {
+					switch c.peer(ctx) {
+					case no:
+						return no
+					case unknown:
+						result = unknown
+					}
+				}
+				return result
+			},
+		}, nil
+	default:
+		return compiled{}, authexpr.ValidationFailed("undefined OpExpr for name: %s", e.Name)
+	}
+}
+
+func compileAtom(e *authexpr.Atom) (compiled, error) {
+	args := make([]string, len(e.Args))
+	for i, arg := range e.Args {
+		if arg.String == nil {
+			return compiled{}, authexpr.ValidationFailed("%s(...) Atom must be called with string literal arguments", e.Name)
+		}
+		args[i] = *arg.String
+	}
+
+	switch e.Name {
+	case "jwtHasScope":
+		if len(args) != 1 {
+			return compiled{}, authexpr.ValidationFailed("jwtHasScope(...) Atom must be called with exactly one string literal argument")
+		}
+		return compiled{
+			eval: func(_ context.Context, claims jwtauth.Claims) bool {
+				ok, _ := authexpr.MakeStandardJWTHasScope(claims)(args[0])
+				return ok
+			},
+			peer: func(context.Context) truth { return unknown },
+		}, nil
+	}
+
+	var source, op string
+	switch {
+	case strings.HasPrefix(e.Name, "jwt_claim_"):
+		source, op = "claim", strings.TrimPrefix(e.Name, "jwt_claim_")
+	case strings.HasPrefix(e.Name, "mtls_peer_"):
+		source, op = "field", strings.TrimPrefix(e.Name, "mtls_peer_")
+	}
+	switch op {
+	case "equals", "prefix", "regex":
+		if len(args) != 2 {
+			return compiled{}, authexpr.ValidationFailed("%s(...) Atom must be called with a %s name and one value", e.Name, source)
+		}
+	case "in":
+		if len(args) < 2 {
+			return compiled{}, authexpr.ValidationFailed("%s(...) Atom must be called with a %s name and at least one value", e.Name, source)
+		}
+	default:
+		return compiled{}, authexpr.ValidationFailed("undefined Atom for name: %s", e.Name)
+	}
+
+	name, values := args[0], args[1:]
+	var lookup func(ctx context.Context, claims jwtauth.Claims) []string
+	if source == "claim" {
+		if name == "" || strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".") || strings.Contains(name, "..") {
+			return compiled{}, authexpr.ValidationFailed("%s(...) Atom has invalid claim name: %q", e.Name, name)
+		}
+		lookup = func(_ context.Context, c jwtauth.Claims) []string { return claims.Values(c, name) }
+	} else {
+		if !mtls.ValidField(name) {
+			return compiled{}, authexpr.ValidationFailed("%s(...) Atom has unknown field %q, expected one of subject, cn, dns, uri, email, ip or spiffe_id", e.Name, name)
+		}
+		lookup = func(ctx context.Context, _ jwtauth.Claims) []string {
+			id, _ := mtls.FromContext(ctx)
+			return id.Values(name)
+		}
+	}
+
+	var match func(string) bool
+	switch op {
+	case "equals", "in":
+		match = func(v string) bool {
+			for _, want := range values {
+				if v == want {
+					return true
+				}
+			}
+			return false
+		}
+	case "prefix":
+		match = func(v string) bool { return strings.HasPrefix(v, values[0]) }
+	case "regex":
+		re, err := regexp.Compile("^(?:" + values[0] + ")$")
+		if err != nil {
+			return compiled{}, authexpr.ValidationFailed("%s(...) Atom has invalid regular expression: %q", e.Name, values[0]).WithCause(err)
+		}
+		match = re.MatchString
+	}
+	eval := func(ctx context.Context, claims jwtauth.Claims) bool {
+		for _, v := range lookup(ctx, claims) {
+			if match(v) {
+				return true
+			}
+		}
+		return false
+	}
+	if source == "claim" {
+		return compiled{eval: eval, peer: func(context.Context) truth { return unknown }}, nil
+	}
+	return compiled{
+		eval: eval,
+		peer: func(ctx context.Context) truth { return truthOf(eval(ctx, nil)) },
+	}, nil
+}
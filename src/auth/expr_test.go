@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/anz-bank/sysl-go/jwtauth"
+	"github.com/stretchr/testify/require"
+)
+
+// testClaims are decoded from JSON, as the claims of verified tokens are.
+const testClaims = `{
+	"sub": "svc-reconciler",
+	"scope": "pets.read pets.write",
+	"email": "jo@acme.com",
+	"roles": ["vet", "admin"],
+	"level": 3,
+	"uid": 1234567,
+	"ratio": 0.5,
+	"verified": true,
+	"org": {"tenant": "acme", "unit": {"id": 42}},
+	"empty": ""
+}`
+
+func TestMakeJWTClaimsBasedAuthorizationRule(t *testing.T) {
+	t.Parallel()
+
+	var claims jwtauth.Claims
+	require.NoError(t, json.Unmarshal([]byte(testClaims), &claims))
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`jwtHasScope("pets.read")`, true},
+		{`jwtHasScope("pets")`, false},
+
+		{`jwt_claim_equals("sub", "svc-reconciler")`, true},
+		{`jwt_claim_equals("sub", "svc")`, false},
+		{`jwt_claim_equals("SUB", "svc-reconciler")`, false},
+		{`jwt_claim_equals("missing", "")`, false},
+		{`jwt_claim_equals("empty", "")`, true},
+
+		{`jwt_claim_in("sub", "a", "svc-reconciler")`, true},
+		{`jwt_claim_in("sub", "a", "b")`, false},
+		{`jwt_claim_in("roles", "admin")`, true},
+		{`jwt_claim_in("roles", "owner", "guest")`, false},
+
+		{`jwt_claim_prefix("sub", "svc-")`, true},
+		{`jwt_claim_prefix("sub", "reconciler")`, false},
+		{`jwt_claim_prefix("roles", "adm")`, true},
+
+		{`jwt_claim_regex("email", ".*@acme\\.com")`, true},
+		{`jwt_claim_regex("email", "acme")`, false},
+		{`jwt_claim_regex("email", "jo@acme.com|x")`, true},
+		{`jwt_claim_regex("email", "jo|.*@evil\\.com")`, false},
+		{`jwt_claim_regex("roles", "v.t")`, true},
+
+		// Nested claims.
+		{`jwt_claim_equals("org.tenant", "acme")`, true},
+		{`jwt_claim_equals("org.unit.id", "42")`, true},
+		{`jwt_claim_equals("org.tenant.id", "acme")`, false},
+		{`jwt_claim_equals("org.missing", "acme")`, false},
+		{`jwt_claim_equals("sub.x", "svc-reconciler")`, false},
+
+		// Non-string claims match their string forms.
+		{`jwt_claim_equals("level", "3")`, true},
+		{`jwt_claim_equals("level", "3.0")`, false},
+		{`jwt_claim_in("ratio", "0.5")`, true},
+		{`jwt_claim_equals("verified", "true")`, true},
+		{`jwt_claim_regex("level", "[0-9]+")`, true},
+		// Large integers keep their digits rather than an exponent form.
+		{`jwt_claim_equals("uid", "1234567")`, true},
+		{`jwt_claim_in("uid", "7654321", "1234567")`, true},
+		{`jwt_claim_regex("uid", "[0-9]{7}")`, true},
+		{`jwt_claim_prefix("uid", "1234")`, true},
+
+		// Operators.
+		{`all(jwtHasScope("pets.read"), jwt_claim_equals("org.tenant", "acme"))`, true},
+		{`all(jwtHasScope("pets.read"), jwt_claim_equals("org.tenant", "evil"))`, false},
+		{`any(jwtHasScope("x"), jwt_claim_in("roles", "vet"))`, true},
+		{`not(jwt_claim_equals("org.tenant", "evil"))`, true},
+		{`not(jwt_claim_equals("org.tenant", "acme"))`, false},
+
+		// Without a client certificate, mtls_peer_* atoms never match.
+		{`mtls_peer_equals("cn", "")`, false},
+		{`mtls_peer_regex("subject", ".*")`, false},
+	}
+	for _, tt := range tests {
+		rule, err := MakeJWTClaimsBasedAuthorizationRule(tt.expr)
+		require.NoError(t, err, tt.expr)
+		got, err := rule(context.Background(), claims)
+		require.NoError(t, err, tt.expr)
+		require.Equal(t, tt.want, got, tt.expr)
+	}
+}
+
+func TestMakeJWTClaimsBasedAuthorizationRuleErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		expr string
+		err  string
+	}{
+		{`jwtHasScope(`, "failed to parse"},
+		{`jwtHasScope("a", "b")`, "exactly one string literal argument"},
+		{`jwtHasScope(true)`, "failed to parse"},
+		{`jwtHasScope()`, "exactly one string literal argument"},
+		{`not(jwtHasScope("a"), jwtHasScope("b"))`, "exactly one argument"},
+		{`either(jwtHasScope("a"))`, "undefined OpExpr for name: either"},
+		{`jwt_claim_contains("sub", "a")`, "undefined Atom for name: jwt_claim_contains"},
+		{`unknown("a")`, "undefined Atom for name: unknown"},
+		{`jwt_claim_equals("sub")`, "a claim name and one value"},
+		{`jwt_claim_equals("sub", "a", "b")`, "a claim name and one value"},
+		{`jwt_claim_prefix("sub")`, "a claim name and one value"},
+		{`jwt_claim_regex("sub", "a", "b")`, "a claim name and one value"},
+		{`jwt_claim_in("sub")`, "a claim name and at least one value"},
+		{`jwt_claim_equals("", "a")`, "invalid claim name"},
+		{`jwt_claim_equals(".sub", "a")`, "invalid claim name"},
+		{`jwt_claim_equals("org.", "a")`, "invalid claim name"},
+		{`jwt_claim_equals("org..tenant", "a")`, "invalid claim name"},
+		{`jwt_claim_regex("email", "(unclosed")`, "invalid regular expression"},
+		{`jwt_claim_regex("email", "a{2,1}")`, "invalid regular expression"},
+		{`mtls_peer_equals("serial", "1")`, `unknown field "serial"`},
+		{`mtls_peer_in("cn")`, "a field name and at least one value"},
+		{`all(jwtHasScope("a"), jwt_claim_regex("email", "["))`, "invalid regular expression"},
+	}
+	for _, tt := range tests {
+		_, err := MakeJWTClaimsBasedAuthorizationRule(tt.expr)
+		require.ErrorContains(t, err, tt.err, tt.expr)
+	}
+}
@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/anz-bank/sysl-go/jwtauth"
+	"github.com/anz-bank/sysl-go/jwtauth/jwtgrpc"
+	"github.com/anz-bank/sysl-go/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/anz-bank/sysl-go-demo/src/mtls"
+)
+
+// GRPCMethod attaches an authorization expression to a gRPC method that
+// serves no REST endpoint. Method is the full name of the method, e.g.
+// /grpc.testing.Foo/thisEndpoint.
+type GRPCMethod struct {
+	Method string `yaml:"method" mapstructure:"method"`
+	Rule   string `yaml:"rule" mapstructure:"rule"`
+}
+
+// GRPCServerOptions returns the interceptors authorizing the calls of the
+// public gRPC server, to be added to core.Hooks.AdditionalGrpcServerOptions.
+//
+// endpoints maps the full names of the gRPC methods serving REST endpoints to
+// those endpoints, as "GET /pet". Such methods are protected by the rules of
+// their endpoints, so that both are authorized alike, and must not have rules
+// of their own. Other methods are protected by the rules of app.auth.grpcMethods.
+//
+// Calls are authorized as requests are by Middleware, with the authorization
+// and API key headers taken from the metadata and the client certificate from
+// the TLS connection. Rejected calls fail with UNAUTHENTICATED, or
+// PERMISSION_DENIED if the caller is known but not allowed. Authorized calls
+// have the caller's claims in their context.
+func (a *Authorizer) GRPCServerOptions(endpoints map[string]string) ([]grpc.ServerOption, error) {
+	rules, err := a.grpcRules(endpoints)
+	if err != nil || len(rules) == 0 {
+		return nil, err
+	}
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(a.unaryInterceptor(rules)),
+		grpc.ChainStreamInterceptor(a.streamInterceptor(rules)),
+	}, nil
+}
+
+// grpcRules returns the rules protecting gRPC methods, by full method name.
+func (a *Authorizer) grpcRules(endpoints map[string]string) (map[string]endpointRule, error) {
+	rules := make(map[string]endpointRule, len(a.grpcMethods))
+	for method, rule := range a.grpcMethods {
+		if endpoint, ok := endpoints[method]; ok {
+			return nil, fmt.Errorf("app.auth.grpcMethods: %s serves %s and is protected by its rule", method, endpoint)
+		}
+		rules[method] = rule
+	}
+	for method, endpoint := range endpoints {
+		if rule, ok := a.rules[endpoint]; ok {
+			rules[method] = rule
+		}
+	}
+	return rules, nil
+}
+
+func (a *Authorizer) unaryInterceptor(rules map[string]endpointRule) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		rule, ok := rules[info.FullMethod]
+		if !ok {
+			return handler(ctx, req)
+		}
+		ctx, err := a.authorizeCall(ctx, info.FullMethod, rule)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (a *Authorizer) streamInterceptor(rules map[string]endpointRule) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		rule, ok := rules[info.FullMethod]
+		if !ok {
+			return handler(srv, ss)
+		}
+		ctx, err := a.authorizeCall(ss.Context(), info.FullMethod, rule)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authorizeCall authorizes a gRPC call against a rule, returning the context
+// of the call with the caller's claims and client certificate.
+func (a *Authorizer) authorizeCall(ctx context.Context, method string, rule endpointRule) (context.Context, error) {
+	if id := mtls.FromPeer(ctx); id != nil {
+		ctx = mtls.NewContext(ctx, id)
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	var authorization string
+	if values := md.Get("authorization"); len(values) > 0 {
+		authorization = values[0]
+	}
+	authCtx, err := a.authorize(ctx, rule, rule.grpcJWT, a.apiKeys.FromMetadata(md), authorization)
+	if err != nil {
+		log.Debugf(ctx, "auth: %s rejected: %v", method, err)
+		return nil, grpcAuthError(err)
+	}
+	return authCtx, nil
+}
+
+// grpcAuthError returns the status of a rejected call, with the code matching
+// the status of a rejected request (see newAuthError).
+func grpcAuthError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	var authErr *jwtauth.AuthError
+	if !errors.As(err, &authErr) {
+		// The rule itself failed to evaluate, so access cannot be granted.
+		return jwtgrpc.ErrClaimsValidationFailed
+	}
+	switch authErr.HTTPStatus() {
+	case http.StatusUnauthorized:
+		return jwtgrpc.ErrAuthenticationFailed
+	case http.StatusForbidden:
+		return status.Error(codes.PermissionDenied, "untrusted credentials")
+	default:
+		return status.Error(codes.Internal, "authentication failed")
+	}
+}
+
+// contextStream replaces the context of a stream.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
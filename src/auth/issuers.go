@@ -0,0 +1,233 @@
+package auth
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/anz-bank/sysl-go/config"
+	"github.com/anz-bank/sysl-go/jwtauth"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+const defaultClockSkew = time.Minute
+
+// IssuerConfig configures a token issuer whose keys are available locally,
+// for environments without a JWKS endpoint to fetch keys from. Exactly one of
+// SharedSecret, PublicKeyFile and JWKSFile must be set.
+type IssuerConfig struct {
+	// Name must match the iss claim of the tokens.
+	Name string `yaml:"name" mapstructure:"name"`
+
+	// SharedSecret verifies HMAC (HS256/384/512) signed tokens.
+	SharedSecret config.SensitiveString `yaml:"sharedSecret" mapstructure:"sharedSecret"`
+	// PublicKeyFile is a PEM encoded public key or certificate (RSA, ECDSA or Ed25519).
+	PublicKeyFile string `yaml:"publicKeyFile" mapstructure:"publicKeyFile"`
+	// JWKSFile is a JSON Web Key Set on disk, keys are selected by the kid header.
+	JWKSFile string `yaml:"jwksFile" mapstructure:"jwksFile"`
+
+	// Audience, if set, requires the aud claim to contain one of these values.
+	Audience []string `yaml:"audience" mapstructure:"audience"`
+	// ClockSkew is the leeway allowed when checking exp and nbf, 1m by default.
+	ClockSkew time.Duration `yaml:"clockSkew" mapstructure:"clockSkew"`
+}
+
+// localIssuer verifies tokens signed with locally configured keys.
+type localIssuer struct {
+	name      string
+	keys      func(kid string) []interface{}
+	algs      []jose.SignatureAlgorithm
+	audience  []string
+	clockSkew time.Duration
+}
+
+var (
+	hmacAlgs   = []jose.SignatureAlgorithm{jose.HS256, jose.HS384, jose.HS512}
+	publicAlgs = []jose.SignatureAlgorithm{
+		jose.RS256, jose.RS384, jose.RS512, jose.PS256, jose.PS384, jose.PS512,
+		jose.ES256, jose.ES384, jose.ES512, jose.EdDSA,
+	}
+)
+
+func newLocalIssuer(c IssuerConfig) (*localIssuer, error) {
+	if c.Name == "" {
+		return nil, errors.New("app.auth.issuers: issuer must have a name")
+	}
+	i := &localIssuer{name: c.Name, audience: c.Audience, clockSkew: c.ClockSkew}
+	if i.clockSkew == 0 {
+		i.clockSkew = defaultClockSkew
+	}
+
+	secret := c.SharedSecret.Value()
+	set := 0
+	for _, s := range []string{secret, c.PublicKeyFile, c.JWKSFile} {
+		if s != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return nil, fmt.Errorf("app.auth.issuers: issuer %s must have exactly one of sharedSecret, publicKeyFile or jwksFile set", c.Name)
+	}
+
+	switch {
+	case secret != "":
+		key := []byte(secret)
+		i.keys = func(string) []interface{} { return []interface{}{key} }
+		i.algs = hmacAlgs
+	case c.PublicKeyFile != "":
+		key, err := readPublicKey(c.PublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("app.auth.issuers: issuer %s: %w", c.Name, err)
+		}
+		i.keys = func(string) []interface{} { return []interface{}{key} }
+		i.algs = publicAlgs
+	default:
+		jwks, err := readJWKS(c.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("app.auth.issuers: issuer %s: %w", c.Name, err)
+		}
+		i.keys = func(kid string) []interface{} {
+			var keys []interface{}
+			for _, k := range jwks.Key(kid) {
+				keys = append(keys, k.Key)
+			}
+			return keys
+		}
+		i.algs = publicAlgs
+	}
+	return i, nil
+}
+
+func readPublicKey(path string) (interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", path)
+	}
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return cert.PublicKey, nil
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	}
+}
+
+func readJWKS(path string) (*jose.JSONWebKeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var jwks jose.JSONWebKeySet
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(jwks.Keys) == 0 {
+		return nil, fmt.Errorf("%s: key set is empty", path)
+	}
+	return &jwks, nil
+}
+
+// verify checks the signature and the iss, aud, exp and nbf claims of token.
+func (i *localIssuer) verify(token *jwt.JSONWebToken) (jwtauth.Claims, error) {
+	if len(token.Headers) != 1 {
+		return nil, &jwtauth.AuthError{Code: jwtauth.AuthErrCodeInvalidJWT, Cause: errors.New("token must have one header")}
+	}
+	header := token.Headers[0]
+	if !hasAlg(i.algs, header.Algorithm) {
+		return nil, &jwtauth.AuthError{
+			Code:  jwtauth.AuthErrCodeBadSignature,
+			Cause: fmt.Errorf("signing algorithm %s not accepted for issuer %s", header.Algorithm, i.name),
+		}
+	}
+	keys := i.keys(header.KeyID)
+	if len(keys) == 0 {
+		return nil, &jwtauth.AuthError{Code: jwtauth.AuthErrCodeUntrustedSource, Cause: errors.New("no matching key id for incoming jwt")}
+	}
+
+	var registered jwt.Claims
+	var claims jwtauth.Claims
+	var err error
+	for _, key := range keys {
+		if err = token.Claims(key, &registered, &claims); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, &jwtauth.AuthError{Code: jwtauth.AuthErrCodeBadSignature, Cause: fmt.Errorf("jwt verify error: %w", err)}
+	}
+
+	if registered.Expiry == nil {
+		return nil, &jwtauth.AuthError{Code: jwtauth.AuthErrCodeInvalidJWT, Cause: errors.New("token has no exp claim")}
+	}
+	expected := jwt.Expected{Issuer: i.name, Time: time.Now()}
+	if err := registered.ValidateWithLeeway(expected, i.clockSkew); err != nil {
+		return nil, &jwtauth.AuthError{Code: jwtauth.AuthErrCodeInvalidJWT, Cause: err}
+	}
+	if len(i.audience) > 0 && !hasAudience(registered.Audience, i.audience) {
+		return nil, &jwtauth.AuthError{Code: jwtauth.AuthErrCodeInvalidJWT, Cause: jwt.ErrInvalidAudience}
+	}
+	return claims, nil
+}
+
+func hasAlg(algs []jose.SignatureAlgorithm, alg string) bool {
+	for _, a := range algs {
+		if string(a) == alg {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAudience(aud jwt.Audience, accepted []string) bool {
+	for _, a := range accepted {
+		if aud.Contains(a) {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticator verifies tokens from local issuers itself, and passes tokens
+// from any other issuer to the issuers configured under
+// library.authentication.jwtauth.
+type authenticator struct {
+	local  map[string]*localIssuer
+	remote jwtauth.Authenticator
+}
+
+func (a *authenticator) Authenticate(ctx context.Context, raw string) (jwtauth.Claims, error) {
+	token, err := jwt.ParseSigned(raw)
+	if err != nil {
+		return nil, &jwtauth.AuthError{Code: jwtauth.AuthErrCodeInvalidJWT, Cause: fmt.Errorf("jwt parse error: %w", err)}
+	}
+	var unverified jwt.Claims
+	if err := token.UnsafeClaimsWithoutVerification(&unverified); err != nil {
+		return nil, &jwtauth.AuthError{Code: jwtauth.AuthErrCodeInvalidJWT, Cause: fmt.Errorf("jwt parse error: %w", err)}
+	}
+	if issuer, ok := a.local[unverified.Issuer]; ok {
+		return issuer.verify(token)
+	}
+	if a.remote != nil {
+		return a.remote.Authenticate(ctx, raw)
+	}
+	return nil, &jwtauth.AuthError{
+		Code:  jwtauth.AuthErrCodeUntrustedSource,
+		Cause: fmt.Errorf("issuer not registered: %s", strings.TrimSpace(unverified.Issuer)),
+	}
+}
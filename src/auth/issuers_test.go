@@ -0,0 +1,277 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/anz-bank/sysl-go/config"
+	"github.com/anz-bank/sysl-go/jwtauth"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+// accepted is the code of test cases without an error, which are never
+// rejected with AuthErrCodeUnknown.
+const accepted = jwtauth.AuthErrCodeUnknown
+
+// sign returns a compact JWT with the given claims, signed with key.
+func sign(t *testing.T, alg jose.SignatureAlgorithm, key interface{}, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	opts := (&jose.SignerOptions{}).WithType("JWT")
+	if kid != "" {
+		opts = opts.WithHeader(jose.HeaderKey("kid"), kid)
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: alg, Key: key}, opts)
+	require.NoError(t, err)
+	token, err := jwt.Signed(signer).Claims(claims).CompactSerialize()
+	require.NoError(t, err)
+	return token
+}
+
+// claimsAt returns valid claims for issuer petdemo-test, shifted by the given
+// offsets from now.
+func claimsAt(exp, nbf time.Duration) map[string]interface{} {
+	now := time.Now()
+	return map[string]interface{}{
+		"iss":   "petdemo-test",
+		"sub":   "tester",
+		"aud":   []string{"petdemo"},
+		"scope": "pets.read",
+		"exp":   now.Add(exp).Unix(),
+		"nbf":   now.Add(nbf).Unix(),
+	}
+}
+
+func with(claims map[string]interface{}, key string, value interface{}) map[string]interface{} {
+	if value == nil {
+		delete(claims, key)
+	} else {
+		claims[key] = value
+	}
+	return claims
+}
+
+func TestLocalIssuerClaims(t *testing.T) {
+	t.Parallel()
+
+	a, err := newAuthenticator(context.Background(), []IssuerConfig{{
+		Name:         "petdemo-test",
+		SharedSecret: config.NewSensitiveString(testSecret),
+		Audience:     []string{"other", "petdemo"},
+		ClockSkew:    30 * time.Second,
+	}}, nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+		code   int
+	}{
+		{name: "valid", claims: claimsAt(time.Hour, -time.Minute)},
+		{name: "expired within leeway", claims: claimsAt(-20*time.Second, -time.Hour)},
+		{name: "expired beyond leeway", claims: claimsAt(-40*time.Second, -time.Hour), code: jwtauth.AuthErrCodeInvalidJWT},
+		{name: "not yet valid within leeway", claims: claimsAt(time.Hour, 20*time.Second)},
+		{name: "not yet valid beyond leeway", claims: claimsAt(time.Hour, 40*time.Second), code: jwtauth.AuthErrCodeInvalidJWT},
+		{name: "without exp", claims: with(claimsAt(0, -time.Minute), "exp", nil), code: jwtauth.AuthErrCodeInvalidJWT},
+		{name: "without nbf", claims: with(claimsAt(time.Hour, 0), "nbf", nil)},
+		{name: "one of the audiences", claims: with(claimsAt(time.Hour, 0), "aud", []string{"x", "other"})},
+		{name: "single audience", claims: with(claimsAt(time.Hour, 0), "aud", "petdemo")},
+		{name: "wrong audience", claims: with(claimsAt(time.Hour, 0), "aud", []string{"x"}), code: jwtauth.AuthErrCodeInvalidJWT},
+		{name: "without audience", claims: with(claimsAt(time.Hour, 0), "aud", nil), code: jwtauth.AuthErrCodeInvalidJWT},
+		{name: "unknown issuer", claims: with(claimsAt(time.Hour, 0), "iss", "elsewhere"), code: jwtauth.AuthErrCodeUntrustedSource},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			claims, err := a.Authenticate(context.Background(), sign(t, jose.HS256, []byte(testSecret), "", tt.claims))
+			if tt.code == accepted {
+				require.NoError(t, err)
+				require.Equal(t, "tester", claims["sub"])
+				require.Equal(t, "pets.read", claims["scope"])
+				return
+			}
+			requireAuthError(t, tt.code, err)
+		})
+	}
+}
+
+func TestLocalIssuerDefaultClockSkew(t *testing.T) {
+	t.Parallel()
+
+	a, err := newAuthenticator(context.Background(), []IssuerConfig{{
+		Name:         "petdemo-test",
+		SharedSecret: config.NewSensitiveString(testSecret),
+	}}, nil)
+	require.NoError(t, err)
+
+	_, err = a.Authenticate(context.Background(), sign(t, jose.HS256, []byte(testSecret), "", claimsAt(-50*time.Second, -time.Hour)))
+	require.NoError(t, err)
+	_, err = a.Authenticate(context.Background(), sign(t, jose.HS256, []byte(testSecret), "", claimsAt(-70*time.Second, -time.Hour)))
+	requireAuthError(t, jwtauth.AuthErrCodeInvalidJWT, err)
+}
+
+func TestLocalIssuerKeys(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	require.NoError(t, err)
+	pemFile := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(pemFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600))
+
+	jwks, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+		{Key: &ecKey.PublicKey, KeyID: "ec-1", Algorithm: string(jose.ES256), Use: "sig"},
+	}})
+	require.NoError(t, err)
+	jwksFile := filepath.Join(dir, "jwks.json")
+	require.NoError(t, os.WriteFile(jwksFile, jwks, 0o600))
+
+	tests := []struct {
+		name   string
+		issuer IssuerConfig
+		token  func(t *testing.T) string
+		code   int
+	}{
+		{
+			name:   "shared secret",
+			issuer: IssuerConfig{SharedSecret: config.NewSensitiveString(testSecret)},
+			token: func(t *testing.T) string {
+				return sign(t, jose.HS512, []byte(testSecret), "", claimsAt(time.Hour, 0))
+			},
+		},
+		{
+			name:   "wrong shared secret",
+			issuer: IssuerConfig{SharedSecret: config.NewSensitiveString(testSecret)},
+			token: func(t *testing.T) string {
+				return sign(t, jose.HS256, []byte("fedcba9876543210fedcba9876543210"), "", claimsAt(time.Hour, 0))
+			},
+			code: jwtauth.AuthErrCodeBadSignature,
+		},
+		{
+			name:   "public key file",
+			issuer: IssuerConfig{PublicKeyFile: pemFile},
+			token: func(t *testing.T) string {
+				return sign(t, jose.RS256, rsaKey, "", claimsAt(time.Hour, 0))
+			},
+		},
+		{
+			name:   "public key used as HMAC secret",
+			issuer: IssuerConfig{PublicKeyFile: pemFile},
+			token: func(t *testing.T) string {
+				return sign(t, jose.HS256, der, "", claimsAt(time.Hour, 0))
+			},
+			code: jwtauth.AuthErrCodeBadSignature,
+		},
+		{
+			name:   "JWKS file",
+			issuer: IssuerConfig{JWKSFile: jwksFile},
+			token: func(t *testing.T) string {
+				return sign(t, jose.ES256, ecKey, "ec-1", claimsAt(time.Hour, 0))
+			},
+		},
+		{
+			name:   "JWKS file with unknown key id",
+			issuer: IssuerConfig{JWKSFile: jwksFile},
+			token: func(t *testing.T) string {
+				return sign(t, jose.ES256, ecKey, "ec-2", claimsAt(time.Hour, 0))
+			},
+			code: jwtauth.AuthErrCodeUntrustedSource,
+		},
+		{
+			name:   "JWKS file with another key",
+			issuer: IssuerConfig{JWKSFile: jwksFile},
+			token: func(t *testing.T) string {
+				return sign(t, jose.ES256, otherKey, "ec-1", claimsAt(time.Hour, 0))
+			},
+			code: jwtauth.AuthErrCodeBadSignature,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tt.issuer.Name = "petdemo-test"
+			a, err := newAuthenticator(context.Background(), []IssuerConfig{tt.issuer}, nil)
+			require.NoError(t, err)
+			_, err = a.Authenticate(context.Background(), tt.token(t))
+			if tt.code == accepted {
+				require.NoError(t, err)
+				return
+			}
+			requireAuthError(t, tt.code, err)
+		})
+	}
+}
+
+func TestLocalIssuerMalformedToken(t *testing.T) {
+	t.Parallel()
+
+	a, err := newAuthenticator(context.Background(), []IssuerConfig{{
+		Name:         "petdemo-test",
+		SharedSecret: config.NewSensitiveString(testSecret),
+	}}, nil)
+	require.NoError(t, err)
+	_, err = a.Authenticate(context.Background(), "not.a.jwt")
+	requireAuthError(t, jwtauth.AuthErrCodeInvalidJWT, err)
+}
+
+func TestNewLocalIssuerErrors(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	emptyJWKS := filepath.Join(dir, "empty.json")
+	require.NoError(t, os.WriteFile(emptyJWKS, []byte(`{"keys": []}`), 0o600))
+	notPEM := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(notPEM, []byte("not a key"), 0o600))
+
+	tests := []struct {
+		name   string
+		issuer IssuerConfig
+		err    string
+	}{
+		{"no name", IssuerConfig{JWKSFile: emptyJWKS}, "issuer must have a name"},
+		{"no key", IssuerConfig{Name: "a"}, "exactly one of"},
+		{"two keys", IssuerConfig{Name: "a", SharedSecret: config.NewSensitiveString("s"), JWKSFile: emptyJWKS}, "exactly one of"},
+		{"empty JWKS", IssuerConfig{Name: "a", JWKSFile: emptyJWKS}, "key set is empty"},
+		{"not PEM", IssuerConfig{Name: "a", PublicKeyFile: notPEM}, "no PEM data found"},
+		{"missing file", IssuerConfig{Name: "a", JWKSFile: filepath.Join(dir, "missing.json")}, "no such file"},
+	}
+	for _, tt := range tests {
+		_, err := newLocalIssuer(tt.issuer)
+		require.ErrorContains(t, err, tt.err, tt.name)
+	}
+
+	_, err := newAuthenticator(context.Background(), []IssuerConfig{
+		{Name: "a", SharedSecret: config.NewSensitiveString("s")},
+		{Name: "a", SharedSecret: config.NewSensitiveString("t")},
+	}, nil)
+	require.ErrorContains(t, err, "duplicate issuer a")
+}
+
+func requireAuthError(t *testing.T, code int, err error) {
+	t.Helper()
+	var authErr *jwtauth.AuthError
+	require.ErrorAs(t, err, &authErr)
+	require.Equal(t, code, authErr.Code, authErr.Error())
+}
@@ -0,0 +1,161 @@
+// Package cassette records the interactions of a service with its real
+// downstreams into cassette files, and replays them through the mocked
+// downstreams of the sysl-go e2e tester, so that regression tests can be
+// built from real downstream behaviour.
+//
+// A test records when CASSETTE_RECORD is set, and replays otherwise:
+//
+//	if cassette.Recording() {
+//		rec := cassette.NewRecorder("testdata/getpetlist.yaml")
+//		srv = petdemo.NewIntegrationTestServer(t, ctx, rec.Wrap(createService), cfg)
+//		defer func() { require.NoError(t, rec.Save()) }()
+//	} else {
+//		srv = petdemo.NewTestServer(t, ctx, createService, cfg)
+//		cassette.Replay(t, srv.GetE2eTester(), "testdata/getpetlist.yaml", cassette.MatchQuery)
+//	}
+package cassette
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v2"
+)
+
+// RecordEnv is the environment variable that switches tests to record mode.
+const RecordEnv = "CASSETTE_RECORD"
+
+// Redacted replaces the values of redacted headers.
+const Redacted = "REDACTED"
+
+// DefaultRedactedHeaders are the headers whose values are never written to a cassette.
+var DefaultRedactedHeaders = []string{
+	"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-API-Key", "X-Signature",
+}
+
+// Recording reports whether tests should record new cassettes.
+func Recording() bool {
+	return os.Getenv(RecordEnv) != ""
+}
+
+// Cassette is the content of a cassette file.
+type Cassette struct {
+	Interactions []Interaction `yaml:"interactions"`
+}
+
+// Interaction is a single downstream request and its response.
+type Interaction struct {
+	// Service is the name of the downstream, such as petstore.
+	Service  string   `yaml:"service"`
+	Request  Request  `yaml:"request"`
+	Response Response `yaml:"response"`
+}
+
+// Request is a recorded downstream request. Path is relative to the
+// service URL of the downstream.
+type Request struct {
+	Method  string              `yaml:"method"`
+	Path    string              `yaml:"path"`
+	Query   map[string][]string `yaml:"query,omitempty"`
+	Headers map[string][]string `yaml:"headers,omitempty"`
+	Body    Body                `yaml:"body,omitempty"`
+}
+
+// Response is a recorded downstream response.
+type Response struct {
+	Status  int                 `yaml:"status"`
+	Headers map[string][]string `yaml:"headers,omitempty"`
+	Body    Body                `yaml:"body,omitempty"`
+}
+
+// Body holds a request or response body, as text where possible so that
+// cassettes stay readable.
+type Body struct {
+	Text   string `yaml:"text,omitempty"`
+	Base64 string `yaml:"base64,omitempty"`
+}
+
+func newBody(data []byte) Body {
+	if utf8.Valid(data) {
+		return Body{Text: string(data)}
+	}
+	return Body{Base64: base64.StdEncoding.EncodeToString(data)}
+}
+
+// Bytes returns the body content.
+func (b Body) Bytes() ([]byte, error) {
+	if b.Base64 != "" {
+		return base64.StdEncoding.DecodeString(b.Base64)
+	}
+	return []byte(b.Text), nil
+}
+
+// Load reads a cassette file.
+func Load(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Cassette
+	if err := yaml.UnmarshalStrict(data, &c); err != nil {
+		return nil, fmt.Errorf("cassette %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// Save writes the cassette to path, creating its directory if needed.
+func (c *Cassette) Save(path string) error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// redact copies header, replacing the values of the redacted headers and
+// dropping the headers that are recomputed when a body is sent.
+func redact(header http.Header, redacted []string) map[string][]string {
+	if len(header) == 0 {
+		return nil
+	}
+	result := make(map[string][]string, len(header))
+	for name, values := range header {
+		switch http.CanonicalHeaderKey(name) {
+		case "Content-Length", "Transfer-Encoding", "Connection":
+			continue
+		}
+		if isRedacted(name, redacted) {
+			values = []string{Redacted}
+		}
+		result[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+	}
+	return result
+}
+
+func isRedacted(name string, redacted []string) bool {
+	for _, r := range redacted {
+		if strings.EqualFold(r, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedKeys returns the keys of m in order, for deterministic reports.
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,199 @@
+package cassette
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anz-bank/sysl-go/testutil/e2e"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anz-bank/sysl-go-demo/src/testhooks"
+)
+
+// petstore is a real downstream served under a path prefix, as in the
+// service URL of the petstore downstream.
+func petstore(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/pet-demo/pet", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=secret")
+		switch r.Method {
+		case http.MethodGet:
+			fmt.Fprintf(w, `{"limit":%q}`, r.URL.Query().Get("limit"))
+		case http.MethodPost:
+			body, _ := io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write(body)
+		}
+	})
+	mux.HandleFunc("/pet-demo/photo", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte{0x89, 'P', 'N', 'G', 0xff, 0x00})
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+type call struct {
+	method, path, body string
+	status             int
+	response           string
+}
+
+var calls = []call{
+	{http.MethodGet, "/pet?limit=2", "", http.StatusOK, `{"limit":"2"}`},
+	{http.MethodPost, "/pet", `{"name": "rex"}`, http.StatusCreated, `{"name": "rex"}`},
+	{http.MethodGet, "/pet?limit=3", "", http.StatusOK, `{"limit":"3"}`},
+	{http.MethodGet, "/photo", "", http.StatusOK, "\x89PNG\xff\x00"},
+}
+
+// do makes the calls through client to the downstream at serviceURL.
+func do(t *testing.T, client *http.Client, serviceURL, token string) {
+	t.Helper()
+	for _, c := range calls {
+		var body io.Reader
+		if c.body != "" {
+			body = strings.NewReader(c.body)
+		}
+		req, err := http.NewRequest(c.method, serviceURL+c.path, body)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("X-Client", "cassette-test")
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		got, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+		require.Equal(t, c.status, resp.StatusCode, c.path)
+		require.Equal(t, c.response, string(got), c.path)
+	}
+}
+
+func record(t *testing.T) string {
+	t.Helper()
+	srv := petstore(t)
+	path := filepath.Join(t.TempDir(), "testdata", "petstore.yaml")
+	rec := NewRecorder(path, "X-Client")
+	client := &http.Client{Transport: rec.Transport("petstore", srv.URL+"/pet-demo/", http.DefaultTransport)}
+	do(t, client, srv.URL+"/pet-demo", "recorded-token")
+	require.Len(t, rec.Interactions(), len(calls))
+	require.NoError(t, rec.Save())
+	return path
+}
+
+func TestRecord(t *testing.T) {
+	t.Parallel()
+
+	c, err := Load(record(t))
+	require.NoError(t, err)
+	require.Len(t, c.Interactions, len(calls))
+
+	get := c.Interactions[0]
+	require.Equal(t, "petstore", get.Service)
+	require.Equal(t, http.MethodGet, get.Request.Method)
+	// The path of the service URL is not recorded.
+	require.Equal(t, "/pet", get.Request.Path)
+	require.Equal(t, map[string][]string{"limit": {"2"}}, get.Request.Query)
+	require.Equal(t, []string{Redacted}, get.Request.Headers["Authorization"])
+	require.Equal(t, []string{Redacted}, get.Request.Headers["X-Client"])
+	require.Equal(t, http.StatusOK, get.Response.Status)
+	require.Equal(t, []string{Redacted}, get.Response.Headers["Set-Cookie"])
+	require.Equal(t, []string{"application/json"}, get.Response.Headers["Content-Type"])
+	require.NotContains(t, get.Response.Headers, "Content-Length")
+	require.Equal(t, `{"limit":"2"}`, get.Response.Body.Text)
+
+	post := c.Interactions[1]
+	require.Nil(t, post.Request.Query)
+	require.Equal(t, `{"name": "rex"}`, post.Request.Body.Text)
+	require.Equal(t, http.StatusCreated, post.Response.Status)
+
+	// Bodies that are not text are kept in base64.
+	photo := c.Interactions[3].Response.Body
+	require.Empty(t, photo.Text)
+	data, err := photo.Bytes()
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x89, 'P', 'N', 'G', 0xff, 0x00}, data)
+}
+
+func TestReplay(t *testing.T) {
+	t.Parallel()
+
+	path := record(t)
+	for name, match := range map[string]Match{"route": MatchRoute, "query": MatchQuery, "strict": MatchStrict} {
+		match := match
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			tester, _, _ := e2e.NewTester(t, context.Background(), nil)
+			client, serviceURL, err := tester.HTTPClientGetter("petstore")
+			require.NoError(t, err)
+			Replay(t, tester, path, match)
+
+			// Redacted headers are not compared, so another token is accepted.
+			do(t, client, serviceURL, "another-token")
+			// Fails the test if any recorded interaction was not made.
+			tester.Close()
+		})
+	}
+}
+
+func TestReplayMismatch(t *testing.T) {
+	t.Parallel()
+
+	path := record(t)
+	tests := []struct {
+		name  string
+		match Match
+		call  func(client *http.Client, serviceURL string) error
+		want  string
+	}{
+		{"query", MatchQuery, func(client *http.Client, serviceURL string) error {
+			_, err := client.Get(serviceURL + "/pet?limit=5")
+			return err
+		}, "interaction 0 (petstore GET /pet): query"},
+		{"body", MatchStrict, func(client *http.Client, serviceURL string) error {
+			_, err := client.Get(serviceURL + "/pet?limit=2")
+			if err != nil {
+				return err
+			}
+			_, err = client.Post(serviceURL+"/pet", "application/json", strings.NewReader(`{"name":"max"}`))
+			return err
+		}, "interaction 1 (petstore POST /pet): body"},
+		{"missing", MatchRoute, func(*http.Client, string) error { return nil }, "un-hit expected calls"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			f := &testhooks.Failures{}
+			tester, _, _ := e2e.NewTester(f, context.Background(), nil)
+			client, serviceURL, err := tester.HTTPClientGetter("petstore")
+			require.NoError(t, err)
+			Replay(f, tester, path, tt.match)
+			require.NoError(t, tt.call(client, serviceURL))
+			tester.Close()
+			require.Contains(t, f.String(), tt.want)
+		})
+	}
+}
+
+func TestLoadStrict(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "bad.yaml")
+	require.NoError(t, (&Cassette{}).Save(path))
+	_, err := Load(path)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte("interactions:\n  - service: petstore\n    reqest: {}\n"), 0o600))
+	_, err = Load(path)
+	require.ErrorContains(t, err, "field reqest not found")
+}
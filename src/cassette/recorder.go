@@ -0,0 +1,124 @@
+package cassette
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/anz-bank/sysl-go-demo/src/testhooks"
+)
+
+// Recorder captures the downstream interactions of a service.
+type Recorder struct {
+	path     string
+	redacted []string
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+// NewRecorder creates a Recorder saving to the cassette file at path.
+// Headers in redact are redacted in addition to DefaultRedactedHeaders.
+func NewRecorder(path string, redact ...string) *Recorder {
+	return &Recorder{
+		path:     path,
+		redacted: append(append([]string(nil), DefaultRedactedHeaders...), redact...),
+	}
+}
+
+// Wrap returns createService with the hooks it returns patched to record
+// every downstream HTTP call. Requests are recorded as they are sent on the
+// wire, after the RoundTrippers added by the service itself.
+func (r *Recorder) Wrap(createService interface{}) interface{} {
+	return testhooks.WrapDownstreams(createService, r.Transport)
+}
+
+// Transport returns a RoundTripper that records the calls made through base
+// to the named downstream.
+func (r *Recorder) Transport(serviceName, serviceURL string, base http.RoundTripper) http.RoundTripper {
+	prefix := ""
+	if u, err := url.Parse(serviceURL); err == nil {
+		prefix = strings.TrimSuffix(u.Path, "/")
+	}
+	return &recordingTransport{recorder: r, service: serviceName, prefix: prefix, base: base}
+}
+
+// Interactions returns the interactions recorded so far.
+func (r *Recorder) Interactions() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Interaction(nil), r.cassette.Interactions...)
+}
+
+// Save writes the recorded interactions to the cassette file.
+func (r *Recorder) Save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cassette.Save(r.path)
+}
+
+func (r *Recorder) add(i Interaction) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, i)
+}
+
+type recordingTransport struct {
+	recorder *Recorder
+	service  string
+	prefix   string
+	base     http.RoundTripper
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		if reqBody, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		_ = req.Body.Close()
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		// Transport errors are not recorded, there is no response to replay.
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	path := strings.TrimPrefix(req.URL.Path, t.prefix)
+	if path == "" {
+		path = "/"
+	}
+	var query map[string][]string
+	if q := req.URL.Query(); len(q) > 0 {
+		query = q
+	}
+	t.recorder.add(Interaction{
+		Service: t.service,
+		Request: Request{
+			Method:  req.Method,
+			Path:    path,
+			Query:   query,
+			Headers: redact(req.Header, t.recorder.redacted),
+			Body:    newBody(reqBody),
+		},
+		Response: Response{
+			Status:  resp.StatusCode,
+			Headers: redact(resp.Header, t.recorder.redacted),
+			Body:    newBody(respBody),
+		},
+	})
+	return resp, nil
+}
@@ -0,0 +1,99 @@
+package cassette
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/anz-bank/sysl-go/syslgo"
+	"github.com/anz-bank/sysl-go/testutil/e2e"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Match sets how closely replayed requests must match the recorded ones.
+type Match int
+
+const (
+	// MatchRoute only requires the method and path to match.
+	MatchRoute Match = iota
+	// MatchQuery also requires the query parameters to match.
+	MatchQuery
+	// MatchStrict also requires the recorded request headers, except the
+	// redacted and ignored ones, and the body to match.
+	MatchStrict
+)
+
+// Replay loads the cassette at path and expects its interactions on the
+// mocked downstreams of tester, answering each with the recorded response.
+// Interactions with the same method and path are expected in recorded order,
+// and the test fails if any of them is not made. ignoreHeaders are not
+// compared with MatchStrict, for headers that change on every request.
+func Replay(t syslgo.TestingT, tester *e2e.Tester, path string, match Match, ignoreHeaders ...string) {
+	c, err := Load(path)
+	require.NoError(t, err)
+
+	endpoints := make(map[string]e2e.Endpoint)
+	for i, interaction := range c.Interactions {
+		key := fmt.Sprintf("%s %s %s", interaction.Service, interaction.Request.Method, interaction.Request.Path)
+		endpoint, ok := endpoints[key]
+		if !ok {
+			endpoint = tester.NewDownstream(interaction.Service, interaction.Request.Method, interaction.Request.Path)
+			endpoints[key] = endpoint
+		}
+		loc := fmt.Sprintf("%s: interaction %d (%s)", path, i, key)
+		tests := expectations(t, interaction.Request, match, ignoreHeaders, loc)
+		tests = append(tests, respond(t, interaction.Response, loc))
+		endpoint.Expect(tests...)
+	}
+}
+
+func expectations(t syslgo.TestingT, req Request, match Match, ignoreHeaders []string, loc string) []e2e.Tests {
+	var tests []e2e.Tests
+	if match >= MatchQuery {
+		expected := url.Values(req.Query).Encode()
+		tests = append(tests, func(t syslgo.TestingT, _ http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, expected, r.URL.Query().Encode(), "%s: query", loc)
+		})
+	}
+	if match >= MatchStrict {
+		expectedBody, err := req.Body.Bytes()
+		require.NoError(t, err, loc)
+		tests = append(tests, func(t syslgo.TestingT, _ http.ResponseWriter, r *http.Request) {
+			for _, name := range sortedKeys(req.Headers) {
+				values := req.Headers[name]
+				if isRedacted(name, ignoreHeaders) || (len(values) == 1 && values[0] == Redacted) {
+					continue
+				}
+				assert.Equal(t, values, r.Header.Values(name), "%s: header %s", loc, name)
+			}
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err, loc)
+			if json.Valid(expectedBody) && json.Valid(body) {
+				assert.JSONEq(t, string(expectedBody), string(body), "%s: body", loc)
+			} else {
+				assert.Equal(t, string(expectedBody), string(body), "%s: body", loc)
+			}
+		})
+	}
+	return tests
+}
+
+func respond(t syslgo.TestingT, resp Response, loc string) e2e.Tests {
+	body, err := resp.Body.Bytes()
+	require.NoError(t, err, loc)
+	return func(_ syslgo.TestingT, w http.ResponseWriter, _ *http.Request) {
+		for name, values := range resp.Headers {
+			if len(values) == 1 && values[0] == Redacted {
+				continue
+			}
+			for _, value := range values {
+				w.Header().Add(name, value)
+			}
+		}
+		w.WriteHeader(resp.Status)
+		_, _ = w.Write(body)
+	}
+}
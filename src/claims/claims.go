@@ -0,0 +1,46 @@
+// Package claims reads the values of JWT claims, as matched by authorization
+// expressions and feature flag rules.
+package claims
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/anz-bank/sysl-go/jwtauth"
+)
+
+// Values returns the string forms of the (possibly nested) claim at path,
+// whose parts are separated by dots. Arrays give one value per item.
+func Values(claims jwtauth.Claims, path string) []string {
+	var value interface{} = claims
+	for _, part := range strings.Split(path, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = m[part]
+	}
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			values = append(values, format(item))
+		}
+		return values
+	default:
+		return []string{format(v)}
+	}
+}
+
+// format returns the string form of a claim value. JSON numbers decode as
+// float64, which are formatted without an exponent so that integer ids such
+// as 1234567 keep their digits.
+func format(v interface{}) string {
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
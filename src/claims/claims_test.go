@@ -0,0 +1,47 @@
+package claims
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/anz-bank/sysl-go/jwtauth"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValues(t *testing.T) {
+	t.Parallel()
+
+	var claims jwtauth.Claims
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"sub": "jo",
+		"uid": 1234567,
+		"big": 12345678901234,
+		"ratio": 0.5,
+		"verified": true,
+		"roles": ["vet", 7654321],
+		"org": {"unit": {"id": 42}}
+	}`), &claims))
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"sub", []string{"jo"}},
+		{"uid", []string{"1234567"}},
+		{"big", []string{"12345678901234"}},
+		{"ratio", []string{"0.5"}},
+		{"verified", []string{"true"}},
+		{"roles", []string{"vet", "7654321"}},
+		{"org.unit.id", []string{"42"}},
+		{"org.missing", nil},
+		{"sub.x", nil},
+		{"missing", nil},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.path, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, Values(claims, tt.path))
+		})
+	}
+}
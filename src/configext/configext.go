@@ -0,0 +1,182 @@
+// Package configext lets the application add settings inside the config
+// sections owned by sysl-go, such as genCode.downstream.<name>, which
+// sysl-go's strict config loading would otherwise reject as unknown keys.
+//
+// Extension keys are removed from the config file before sysl-go reads it
+// (see core.WithConfigFile) and decoded separately by the application. They
+// cannot be overridden by environment variables, so secrets in them can be
+// given as references instead (see ResolveSecret).
+package configext
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/anz-bank/sysl-go/config"
+	"github.com/mitchellh/mapstructure"
+	"gopkg.in/yaml.v2"
+)
+
+// Extension is a single extension block found in the config file.
+type Extension struct {
+	// Path is the full dotted path of the block, as written in the config file.
+	Path string
+	// Name is the key matched by the * of the pattern, if it has one.
+	Name string
+
+	value interface{}
+}
+
+// Extensions holds the extension blocks removed from a config file.
+type Extensions struct {
+	found map[string][]Extension // by pattern
+}
+
+// Split removes the keys matching patterns from the YAML config data and
+// returns the remaining data together with the removed blocks. Patterns are
+// dotted paths, matched case-insensitively, in which a single segment may be
+// * to match any key, e.g. genCode.downstream.*.auth.
+func Split(data []byte, patterns ...string) ([]byte, *Extensions, error) {
+	var root map[interface{}]interface{}
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, nil, err
+	}
+	ext := &Extensions{found: make(map[string][]Extension)}
+	for _, pattern := range patterns {
+		extract(root, strings.Split(pattern, "."), "", "", func(e Extension) {
+			ext.found[pattern] = append(ext.found[pattern], e)
+		})
+	}
+	stripped, err := yaml.Marshal(root)
+	if err != nil {
+		return nil, nil, err
+	}
+	return stripped, ext, nil
+}
+
+func extract(node map[interface{}]interface{}, pattern []string, path, name string, found func(Extension)) {
+	for key, value := range node {
+		k := fmt.Sprint(key)
+		if pattern[0] != "*" && !strings.EqualFold(k, pattern[0]) {
+			continue
+		}
+		n := name
+		if pattern[0] == "*" {
+			n = k
+		}
+		p := joinPath(path, k)
+		if len(pattern) == 1 {
+			found(Extension{Path: p, Name: n, value: Normalize(value)})
+			delete(node, key)
+			continue
+		}
+		if child, ok := value.(map[interface{}]interface{}); ok {
+			extract(child, pattern[1:], p, n, found)
+		}
+	}
+}
+
+// Normalize converts the maps decoded by yaml.v2 into maps with string keys,
+// as needed by mapstructure and encoding/json.
+func Normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[fmt.Sprint(key)] = Normalize(item)
+		}
+		return m
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = Normalize(item)
+		}
+		return items
+	default:
+		return v
+	}
+}
+
+func joinPath(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
+
+// Get returns the blocks found for pattern, which must be one of the patterns
+// given to Split. A nil *Extensions holds no blocks.
+func (e *Extensions) Get(pattern string) []Extension {
+	if e == nil {
+		return nil
+	}
+	return e.found[pattern]
+}
+
+// Decode decodes the block into out, a pointer to a struct with mapstructure
+// tags, using the same conversions as sysl-go (durations, SensitiveString).
+// SensitiveString fields may hold secret references, which are resolved with
+// ResolveSecret. Keys that do not match a field are reported as errors.
+func (x Extension) Decode(out interface{}) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+			secretHookFunc(),
+			config.StringToSensitiveStringHookFunc(),
+		),
+		ErrorUnused:      true,
+		WeaklyTypedInput: true,
+		Result:           out,
+	})
+	if err != nil {
+		return err
+	}
+	if err := decoder.Decode(x.value); err != nil {
+		return fmt.Errorf("%s: %w", x.Path, err)
+	}
+	return nil
+}
+
+// Prefixes of the secret references accepted by ResolveSecret.
+const (
+	envPrefix  = "env:"
+	filePrefix = "file:"
+)
+
+// ResolveSecret returns the secret referenced by value: the environment
+// variable NAME for env:NAME, or the contents of the file PATH for file:PATH,
+// without trailing newlines. Other values are returned as they are.
+func ResolveSecret(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, envPrefix):
+		name := strings.TrimPrefix(value, envPrefix)
+		secret, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return secret, nil
+	case strings.HasPrefix(value, filePrefix):
+		data, err := os.ReadFile(strings.TrimPrefix(value, filePrefix))
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	default:
+		return value, nil
+	}
+}
+
+// secretHookFunc resolves the secret references decoded into SensitiveString
+// fields, before sysl-go's hook converts them.
+func secretHookFunc() mapstructure.DecodeHookFuncType {
+	sensitiveString := reflect.TypeOf(config.SensitiveString{})
+	return func(f, t reflect.Type, data interface{}) (interface{}, error) {
+		if f.Kind() != reflect.String || t != sensitiveString {
+			return data, nil
+		}
+		return ResolveSecret(data.(string))
+	}
+}
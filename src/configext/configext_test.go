@@ -0,0 +1,74 @@
+package configext
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anz-bank/sysl-go/config"
+	"github.com/stretchr/testify/require"
+)
+
+type secretConfig struct {
+	ID     string                 `mapstructure:"id"`
+	Secret config.SensitiveString `mapstructure:"secret"`
+}
+
+func TestDecodeSecretReferences(t *testing.T) {
+	// Not parallel, as it sets environment variables.
+	t.Setenv("CONFIGEXT_TEST_SECRET", "from-env")
+	file := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, os.WriteFile(file, []byte("from-file\n"), 0o600))
+
+	tests := []struct {
+		name   string
+		secret string
+		want   string
+		err    string
+	}{
+		{"plain", "plain-secret", "plain-secret", ""},
+		{"env", "env:CONFIGEXT_TEST_SECRET", "from-env", ""},
+		{"file", "file:" + file, "from-file", ""},
+		{"unset env", "env:CONFIGEXT_TEST_UNSET", "", "environment variable CONFIGEXT_TEST_UNSET is not set"},
+		{"missing file", "file:" + file + ".missing", "", "no such file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := []byte("downstream:\n  petstore:\n    signing:\n      id: key-1\n      secret: " + tt.secret + "\n")
+			_, ext, err := Split(data, "downstream.*.signing")
+			require.NoError(t, err)
+			found := ext.Get("downstream.*.signing")
+			require.Len(t, found, 1)
+
+			var cfg secretConfig
+			err = found[0].Decode(&cfg)
+			if tt.err != "" {
+				require.ErrorContains(t, err, "downstream.petstore.signing")
+				require.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, "key-1", cfg.ID)
+			require.Equal(t, tt.want, cfg.Secret.Value())
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	t.Parallel()
+
+	data := []byte("genCode:\n  downstream:\n    petstore:\n      serviceURL: http://localhost\n      Auth:\n        token: x\n")
+	stripped, ext, err := Split(data, "genCode.downstream.*.auth")
+	require.NoError(t, err)
+	require.Equal(t, "genCode:\n  downstream:\n    petstore:\n      serviceURL: http://localhost\n", string(stripped))
+	found := ext.Get("genCode.downstream.*.auth")
+	require.Len(t, found, 1)
+	require.Equal(t, "genCode.downstream.petstore.Auth", found[0].Path)
+	require.Equal(t, "petstore", found[0].Name)
+
+	var unknown struct {
+		Other string `mapstructure:"other"`
+	}
+	require.ErrorContains(t, found[0].Decode(&unknown), "genCode.downstream.petstore.Auth")
+	require.Empty(t, (*Extensions)(nil).Get("genCode.downstream.*.auth"))
+}
@@ -0,0 +1,246 @@
+package contract
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Response is a documented response of an operation.
+type Response struct {
+	// Status is a status code such as "200", a range such as "5XX" or "default".
+	Status      string
+	Description string
+	// ContentType is the first documented media type, "" for a response without a body.
+	ContentType string
+
+	media map[string]interface{}
+}
+
+// Code returns the status code to send for the response: the code itself,
+// the first code of a range, or 500 for default.
+func (r Response) Code() int {
+	if code, err := strconv.Atoi(r.Status); err == nil {
+		return code
+	}
+	if len(r.Status) == 3 && strings.EqualFold(r.Status[1:], "XX") {
+		if n, err := strconv.Atoi(r.Status[:1]); err == nil {
+			return n * 100
+		}
+	}
+	return 500
+}
+
+// Success reports whether the response is documented for a 2xx status.
+func (r Response) Success() bool {
+	return strings.HasPrefix(r.Status, "2")
+}
+
+// ExampleNames lists the named examples of the response, sorted.
+func (r Response) ExampleNames() []string {
+	examples, _ := r.media["examples"].(map[string]interface{})
+	names := make([]string, 0, len(examples))
+	for name := range examples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Responses lists the documented responses of the operation at method and
+// path template, sorted by status with ranges after codes and default last.
+func (s *Spec) Responses(method, template string) []Response {
+	paths, _ := s.doc["paths"].(map[string]interface{})
+	item, _ := s.resolve(paths[template]).(map[string]interface{})
+	op, _ := item[strings.ToLower(method)].(map[string]interface{})
+	responses, _ := op["responses"].(map[string]interface{})
+
+	result := make([]Response, 0, len(responses))
+	for status, raw := range responses {
+		resp, _ := s.resolve(raw).(map[string]interface{})
+		r := Response{Status: status}
+		r.Description, _ = resp["description"].(string)
+		content, _ := resp["content"].(map[string]interface{})
+		types := make([]string, 0, len(content))
+		for contentType := range content {
+			types = append(types, contentType)
+		}
+		sort.Strings(types)
+		if len(types) > 0 {
+			r.ContentType = types[0]
+			r.media, _ = content[types[0]].(map[string]interface{})
+		}
+		result = append(result, r)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return statusOrder(result[i].Status) < statusOrder(result[j].Status)
+	})
+	return result
+}
+
+func statusOrder(status string) string {
+	switch {
+	case status == "default":
+		return "9" + status
+	case strings.HasSuffix(strings.ToUpper(status), "XX"):
+		return "8" + status
+	default:
+		return "0" + status
+	}
+}
+
+// Example returns the body of the named example of the response, or with
+// an empty name, its example, the example of its schema, or data generated
+// from its schema with rnd. It returns nil for a response without a body.
+func (s *Spec) Example(r Response, name string, rnd *rand.Rand) ([]byte, error) {
+	if r.media == nil {
+		return nil, nil
+	}
+	var value interface{}
+	switch examples, _ := r.media["examples"].(map[string]interface{}); {
+	case name != "":
+		example, ok := s.resolve(examples[name]).(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("response %s has no example %q", r.Status, name)
+		}
+		value = example["value"]
+	case r.media["example"] != nil:
+		value = r.media["example"]
+	case len(examples) > 0:
+		example, _ := s.resolve(examples[r.ExampleNames()[0]]).(map[string]interface{})
+		value = example["value"]
+	default:
+		value = s.fake(r.media["schema"], rnd, 0)
+	}
+	if !isJSON(r.ContentType) {
+		if str, ok := value.(string); ok {
+			return []byte(str), nil
+		}
+	}
+	return json.Marshal(value)
+}
+
+// maxFakeDepth stops generating data for recursive schemas.
+const maxFakeDepth = 8
+
+const fakeLetters = "abcdefghijklmnopqrstuvwxyz"
+
+// fake generates a value valid against the schema, within the limits of the
+// supported keywords: patterns are not honoured.
+//
+//nolint:funlen,gocognit
+func (s *Spec) fake(rawSchema interface{}, rnd *rand.Rand, depth int) interface{} {
+	schema, _ := s.resolve(rawSchema).(map[string]interface{})
+	if schema == nil || depth > maxFakeDepth {
+		return nil
+	}
+	if example, ok := schema["example"]; ok {
+		return example
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok && len(enum) > 0 {
+		return enum[rnd.Intn(len(enum))]
+	}
+	if all, ok := schema["allOf"].([]interface{}); ok {
+		merged := map[string]interface{}{}
+		for _, sub := range all {
+			if obj, ok := s.fake(sub, rnd, depth+1).(map[string]interface{}); ok {
+				for k, v := range obj {
+					merged[k] = v
+				}
+			}
+		}
+		return merged
+	}
+	for _, key := range []string{"oneOf", "anyOf"} {
+		if alternatives, ok := schema[key].([]interface{}); ok && len(alternatives) > 0 {
+			return s.fake(alternatives[0], rnd, depth+1)
+		}
+	}
+
+	switch schema["type"] {
+	case "string":
+		return fakeString(schema, rnd)
+	case "integer":
+		min, max := 0.0, 1000.0
+		if v, ok := toFloat(schema["minimum"]); ok {
+			min = v
+		}
+		if v, ok := toFloat(schema["maximum"]); ok {
+			max = v
+		}
+		if max < min {
+			max = min
+		}
+		return int64(min) + rnd.Int63n(int64(max-min)+1)
+	case "number":
+		min, max := 0.0, 1000.0
+		if v, ok := toFloat(schema["minimum"]); ok {
+			min = v
+		}
+		if v, ok := toFloat(schema["maximum"]); ok {
+			max = v
+		}
+		return min + rnd.Float64()*(max-min)
+	case "boolean":
+		return rnd.Intn(2) == 1
+	case "array":
+		n := 1 + rnd.Intn(3)
+		if min, ok := toInt(schema["minItems"]); ok && n < min {
+			n = min
+		}
+		if max, ok := toInt(schema["maxItems"]); ok && n > max {
+			n = max
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			items[i] = s.fake(schema["items"], rnd, depth+1)
+		}
+		return items
+	case "object":
+		properties, _ := schema["properties"].(map[string]interface{})
+		obj := make(map[string]interface{}, len(properties))
+		for _, name := range sortedNames(properties) {
+			obj[name] = s.fake(properties[name], rnd, depth+1)
+		}
+		return obj
+	default:
+		return nil
+	}
+}
+
+func fakeString(schema map[string]interface{}, rnd *rand.Rand) string {
+	switch schema["format"] {
+	case "date-time":
+		return time.Unix(1600000000+rnd.Int63n(100000000), 0).UTC().Format(time.RFC3339)
+	case "date":
+		return time.Unix(1600000000+rnd.Int63n(100000000), 0).UTC().Format("2006-01-02")
+	case "uuid":
+		b := make([]byte, 16)
+		_, _ = rnd.Read(b)
+		return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+	case "uri", "url":
+		return "https://example.com/" + fakeWord(rnd, 8)
+	case "email":
+		return fakeWord(rnd, 8) + "@example.com"
+	}
+	n := 8
+	if min, ok := toInt(schema["minLength"]); ok && n < min {
+		n = min
+	}
+	if max, ok := toInt(schema["maxLength"]); ok && n > max {
+		n = max
+	}
+	return fakeWord(rnd, n)
+}
+
+func fakeWord(rnd *rand.Rand, n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = fakeLetters[rnd.Intn(len(fakeLetters))]
+	}
+	return string(b)
+}
@@ -0,0 +1,331 @@
+package contract
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Locations of violations.
+const (
+	LocationOperation   = "operation"
+	LocationStatus      = "status"
+	LocationContentType = "content-type"
+	LocationBody        = "body"
+)
+
+// Violation is a difference between a response and the spec.
+type Violation struct {
+	Location string
+	// Pointer is the JSON pointer of the offending value in the body, "" for the whole body.
+	Pointer string
+	Message string
+}
+
+func (v Violation) String() string {
+	if v.Location == LocationBody {
+		return fmt.Sprintf("body %q: %s", v.Pointer, v.Message)
+	}
+	return fmt.Sprintf("%s: %s", v.Location, v.Message)
+}
+
+func (s *Spec) validateJSON(schema interface{}, body []byte) []Violation {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return []Violation{{Location: LocationBody, Message: "invalid JSON: " + err.Error()}}
+	}
+	var v validator
+	v.spec = s
+	v.validate(schema, value, "")
+	return v.violations
+}
+
+type validator struct {
+	spec       *Spec
+	violations []Violation
+}
+
+func (v *validator) fail(pointer, format string, args ...interface{}) {
+	v.violations = append(v.violations, Violation{Location: LocationBody, Pointer: pointer, Message: fmt.Sprintf(format, args...)})
+}
+
+// matches reports whether value is valid against schema, without recording violations.
+func (v *validator) matches(schema, value interface{}, pointer string) bool {
+	sub := validator{spec: v.spec}
+	sub.validate(schema, value, pointer)
+	return len(sub.violations) == 0
+}
+
+//nolint:funlen,gocognit
+func (v *validator) validate(rawSchema, value interface{}, pointer string) {
+	schema, _ := v.spec.resolve(rawSchema).(map[string]interface{})
+	if schema == nil {
+		return
+	}
+
+	if value == nil {
+		if schema["nullable"] == true || schema["type"] == nil {
+			return
+		}
+		v.fail(pointer, "must not be null")
+		return
+	}
+
+	if all, ok := schema["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			v.validate(sub, value, pointer)
+		}
+	}
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok {
+		matched := false
+		for _, sub := range anyOf {
+			if v.matches(sub, value, pointer) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.fail(pointer, "does not match any schema of anyOf")
+		}
+	}
+	if oneOf, ok := schema["oneOf"].([]interface{}); ok {
+		n := 0
+		for _, sub := range oneOf {
+			if v.matches(sub, value, pointer) {
+				n++
+			}
+		}
+		if n != 1 {
+			v.fail(pointer, "matches %d schemas of oneOf, expected exactly 1", n)
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok && !inEnum(enum, value) {
+		v.fail(pointer, "%s is not one of the allowed values %v", describe(value), enum)
+	}
+
+	typ, _ := schema["type"].(string)
+	switch typ {
+	case "":
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			v.fail(pointer, "expected string, got %s", kind(value))
+			return
+		}
+		v.validateString(schema, str, pointer)
+	case "integer", "number":
+		n, ok := value.(json.Number)
+		if !ok {
+			v.fail(pointer, "expected %s, got %s", typ, kind(value))
+			return
+		}
+		v.validateNumber(schema, typ, n, pointer)
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			v.fail(pointer, "expected boolean, got %s", kind(value))
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			v.fail(pointer, "expected array, got %s", kind(value))
+			return
+		}
+		if min, ok := toInt(schema["minItems"]); ok && len(items) < min {
+			v.fail(pointer, "has %d items, expected at least %d", len(items), min)
+		}
+		if max, ok := toInt(schema["maxItems"]); ok && len(items) > max {
+			v.fail(pointer, "has %d items, expected at most %d", len(items), max)
+		}
+		if itemSchema, ok := schema["items"]; ok {
+			for i, item := range items {
+				v.validate(itemSchema, item, pointer+"/"+strconv.Itoa(i))
+			}
+		}
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			v.fail(pointer, "expected object, got %s", kind(value))
+			return
+		}
+		v.validateObject(schema, obj, pointer)
+	default:
+		v.fail(pointer, "unsupported schema type %q", typ)
+	}
+}
+
+func (v *validator) validateString(schema map[string]interface{}, str, pointer string) {
+	length := utf8.RuneCountInString(str)
+	if min, ok := toInt(schema["minLength"]); ok && length < min {
+		v.fail(pointer, "has length %d, expected at least %d", length, min)
+	}
+	if max, ok := toInt(schema["maxLength"]); ok && length > max {
+		v.fail(pointer, "has length %d, expected at most %d", length, max)
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			v.fail(pointer, "spec has an invalid pattern %q", pattern)
+		} else if !re.MatchString(str) {
+			v.fail(pointer, "%q does not match pattern %q", str, pattern)
+		}
+	}
+	switch schema["format"] {
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, str); err != nil {
+			v.fail(pointer, "%q is not a date-time", str)
+		}
+	case "date":
+		if _, err := time.Parse("2006-01-02", str); err != nil {
+			v.fail(pointer, "%q is not a date", str)
+		}
+	}
+}
+
+func (v *validator) validateNumber(schema map[string]interface{}, typ string, n json.Number, pointer string) {
+	f, _, err := big.ParseFloat(n.String(), 10, 128, big.ToNearestEven)
+	if err != nil {
+		v.fail(pointer, "invalid number %s", n)
+		return
+	}
+	if typ == "integer" {
+		if !f.IsInt() {
+			v.fail(pointer, "expected integer, got %s", n)
+			return
+		}
+		i, _ := f.Int(nil)
+		switch schema["format"] {
+		case "int32":
+			if !i.IsInt64() || i.Int64() < math.MinInt32 || i.Int64() > math.MaxInt32 {
+				v.fail(pointer, "%s does not fit in int32", n)
+			}
+		case "int64":
+			if !i.IsInt64() {
+				v.fail(pointer, "%s does not fit in int64", n)
+			}
+		}
+	}
+	value, _ := f.Float64()
+	exclusiveMin, _ := schema["exclusiveMinimum"].(bool)
+	exclusiveMax, _ := schema["exclusiveMaximum"].(bool)
+	if min, ok := toFloat(schema["minimum"]); ok && (value < min || (exclusiveMin && value == min)) {
+		v.fail(pointer, "%s is below the minimum %v", n, schema["minimum"])
+	}
+	if max, ok := toFloat(schema["maximum"]); ok && (value > max || (exclusiveMax && value == max)) {
+		v.fail(pointer, "%s is above the maximum %v", n, schema["maximum"])
+	}
+}
+
+func (v *validator) validateObject(schema, obj map[string]interface{}, pointer string) {
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, name := range required {
+			name := fmt.Sprint(name)
+			if _, present := obj[name]; !present {
+				v.fail(pointer+"/"+escape(name), "required property is missing")
+			}
+		}
+	}
+	properties, _ := schema["properties"].(map[string]interface{})
+	for _, name := range sortedNames(obj) {
+		child := pointer + "/" + escape(name)
+		if propSchema, ok := properties[name]; ok {
+			v.validate(propSchema, obj[name], child)
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				v.fail(child, "property is not allowed")
+			}
+		case map[string]interface{}:
+			v.validate(additional, obj[name], child)
+		}
+	}
+}
+
+// escape escapes a JSON pointer token.
+func escape(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+func sortedNames(obj map[string]interface{}) []string {
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, allowed := range enum {
+		if n, ok := value.(json.Number); ok {
+			if f, ok := toFloat(allowed); ok {
+				if g, err := n.Float64(); err == nil && f == g {
+					return true
+				}
+			}
+			continue
+		}
+		if reflect.DeepEqual(allowed, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func kind(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func describe(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+func toInt(value interface{}) (int, bool) {
+	f, ok := toFloat(value)
+	return int(f), ok
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
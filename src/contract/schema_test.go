@@ -0,0 +1,231 @@
+package contract
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testSpec = `
+openapi: 3.0.0
+servers:
+  - url: http://petstore.example.com/v1
+paths:
+  /pet/{id}:
+    get:
+      responses:
+        "200":
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Pet'
+        "204":
+          description: no content
+  /pets:
+    get:
+      responses:
+        "200":
+          content:
+            application/json:
+              schema:
+                type: array
+                maxItems: 2
+                items:
+                  $ref: '#/components/schemas/Pet'
+components:
+  schemas:
+    Pet:
+      type: object
+      required: [id, name]
+      additionalProperties: false
+      properties:
+        id:
+          type: integer
+          format: int32
+          minimum: 1
+        name:
+          type: string
+          minLength: 1
+          maxLength: 10
+          pattern: '^[A-Za-z]+$'
+        status:
+          type: string
+          enum: [available, sold]
+        weight:
+          type: number
+          exclusiveMaximum: true
+          maximum: 100
+        vaccinated:
+          type: boolean
+        born:
+          type: string
+          format: date
+        tag:
+          type: string
+          nullable: true
+        grid:
+          type: array
+          items:
+            type: array
+            minItems: 1
+            items:
+              type: integer
+        a/b~c:
+          type: string
+        owner:
+          $ref: '#/components/schemas/Owner'
+    Owner:
+      type: object
+      required: [name]
+      additionalProperties:
+        type: string
+      properties:
+        name:
+          type: string
+        kind:
+          oneOf:
+            - type: string
+              enum: [person]
+            - type: string
+              enum: [company, person]
+        contact:
+          anyOf:
+            - type: string
+              pattern: '@'
+            - type: integer
+`
+
+func parseTestSpec(t *testing.T) *Spec {
+	t.Helper()
+	s, err := Parse([]byte(testSpec))
+	require.NoError(t, err)
+	return s
+}
+
+func TestValidateJSON(t *testing.T) {
+	t.Parallel()
+
+	s := parseTestSpec(t)
+	tests := []struct {
+		name string
+		path string
+		body string
+		want []string // violations, as formatted by Violation.String
+	}{
+		{"valid", "/pet/1", `{"id": 1, "name": "Rex", "status": "sold", "weight": 99.5, "vaccinated": true,
+			"born": "2020-02-29", "tag": null, "grid": [[1, 2], [3]], "a/b~c": "x",
+			"owner": {"name": "Ann", "kind": "company", "contact": "ann@example.com", "note": "extra"}}`, nil},
+
+		{"required", "/pet/1", `{}`, []string{
+			`body "/id": required property is missing`,
+			`body "/name": required property is missing`,
+		}},
+		{"nested required", "/pet/1", `{"id": 1, "name": "Rex", "owner": {}}`, []string{
+			`body "/owner/name": required property is missing`,
+		}},
+		{"not an object", "/pet/1", `[]`, []string{`body "": expected object, got array`}},
+		{"invalid JSON", "/pet/1", `{"id": 1`, []string{`body "": invalid JSON: unexpected EOF`}},
+		{"type mismatches", "/pet/1", `{"id": "1", "name": 5, "vaccinated": "yes", "grid": {}, "owner": "Ann"}`, []string{
+			`body "/grid": expected array, got object`,
+			`body "/id": expected integer, got string`,
+			`body "/name": expected string, got number`,
+			`body "/owner": expected object, got string`,
+			`body "/vaccinated": expected boolean, got string`,
+		}},
+		{"null", "/pet/1", `{"id": null, "name": "Rex", "tag": null}`, []string{`body "/id": must not be null`}},
+		{"integers", "/pet/1", `{"id": 1.5, "name": "Rex"}`, []string{`body "/id": expected integer, got 1.5`}},
+		{"int32", "/pet/1", `{"id": 2147483648, "name": "Rex"}`, []string{`body "/id": 2147483648 does not fit in int32`}},
+		{"minimum", "/pet/1", `{"id": 0, "name": "Rex"}`, []string{`body "/id": 0 is below the minimum 1`}},
+		{"exclusive maximum", "/pet/1", `{"id": 1, "name": "Rex", "weight": 100}`, []string{`body "/weight": 100 is above the maximum 100`}},
+		{"string length", "/pet/1", `{"id": 1, "name": ""}`, []string{
+			`body "/name": has length 0, expected at least 1`,
+			`body "/name": "" does not match pattern "^[A-Za-z]+$"`,
+		}},
+		{"max length", "/pet/1", `{"id": 1, "name": "Bartholomew"}`, []string{`body "/name": has length 11, expected at most 10`}},
+		{"pattern", "/pet/1", `{"id": 1, "name": "R2D2"}`, []string{`body "/name": "R2D2" does not match pattern "^[A-Za-z]+$"`}},
+		{"date", "/pet/1", `{"id": 1, "name": "Rex", "born": "2021-02-29"}`, []string{`body "/born": "2021-02-29" is not a date`}},
+		{"enum", "/pet/1", `{"id": 1, "name": "Rex", "status": "lost"}`, []string{
+			`body "/status": "lost" is not one of the allowed values [available sold]`,
+		}},
+		{"additional property", "/pet/1", `{"id": 1, "name": "Rex", "colour": "brown"}`, []string{`body "/colour": property is not allowed`}},
+		{"additional property schema", "/pet/1", `{"id": 1, "name": "Rex", "owner": {"name": "Ann", "age": 40}}`, []string{
+			`body "/owner/age": expected string, got number`,
+		}},
+		{"escaped pointer", "/pet/1", `{"id": 1, "name": "Rex", "a/b~c": 1}`, []string{`body "/a~1b~0c": expected string, got number`}},
+		{"nested arrays", "/pet/1", `{"id": 1, "name": "Rex", "grid": [[1], [], [2, "3"], 4]}`, []string{
+			`body "/grid/1": has 0 items, expected at least 1`,
+			`body "/grid/2/1": expected integer, got string`,
+			`body "/grid/3": expected array, got number`,
+		}},
+		{"oneOf", "/pet/1", `{"id": 1, "name": "Rex", "owner": {"name": "Ann", "kind": "person"}}`, []string{
+			`body "/owner/kind": matches 2 schemas of oneOf, expected exactly 1`,
+		}},
+		{"anyOf", "/pet/1", `{"id": 1, "name": "Rex", "owner": {"name": "Ann", "contact": true}}`, []string{
+			`body "/owner/contact": does not match any schema of anyOf`,
+		}},
+		{"array items", "/pets", `[{"id": 1, "name": "Rex"}, {"id": 2}, {"id": 3, "name": "Max"}]`, []string{
+			`body "": has 3 items, expected at most 2`,
+			`body "/1/name": required property is missing`,
+		}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			header := http.Header{"Content-Type": {"application/json; charset=utf-8"}}
+			var got []string
+			for _, v := range s.VerifyResponse(http.MethodGet, tt.path, http.StatusOK, header, []byte(tt.body)) {
+				got = append(got, v.String())
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestVerifyResponse(t *testing.T) {
+	t.Parallel()
+
+	s := parseTestSpec(t)
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		status      int
+		contentType string
+		body        string
+		want        []string
+	}{
+		{"unknown operation", http.MethodPost, "/pet/1", http.StatusOK, "application/json", `{}`, []string{
+			"operation: POST /pet/1 is not in the spec",
+		}},
+		{"undocumented status", http.MethodGet, "/pet/1", http.StatusNotFound, "application/json", `{}`, []string{
+			"status: status 404 is not documented for GET /pet/{id}",
+		}},
+		{"no content", http.MethodGet, "/pet/1", http.StatusNoContent, "", "", nil},
+		{"unexpected body", http.MethodGet, "/pet/1", http.StatusNoContent, "text/plain", "gone", []string{
+			`body "": status 204 is documented without a body`,
+		}},
+		{"content type", http.MethodGet, "/pet/1", http.StatusOK, "text/html", "<p>", []string{
+			"content-type: content type text/html is not one of application/json",
+		}},
+		{"invalid content type", http.MethodGet, "/pet/1", http.StatusOK, "", "{}", []string{
+			`content-type: invalid content type ""`,
+		}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			header := http.Header{}
+			if tt.contentType != "" {
+				header.Set("Content-Type", tt.contentType)
+			}
+			var got []string
+			for _, v := range s.VerifyResponse(tt.method, tt.path, tt.status, header, []byte(tt.body)) {
+				got = append(got, v.String())
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
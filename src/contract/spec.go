@@ -0,0 +1,266 @@
+// Package contract verifies downstream responses against the OpenAPI 3 spec
+// of the downstream, such as specs/petstore.yaml, reporting every mismatch
+// with the JSON pointer of the offending value. It detects contract drift in
+// tests and from the command line, before it surfaces in production as a
+// DownstreamUnexpectedResponseError. It also produces example responses
+// from the spec, for mocking the downstream (see specmock).
+//
+// Only the parts of OpenAPI used to describe responses are supported:
+// status codes, content types and JSON schemas with $ref, type, format,
+// nullable, enum, required, properties, additionalProperties, items,
+// allOf, anyOf, oneOf and the usual length and range keywords.
+package contract
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/anz-bank/sysl-go-demo/src/configext"
+)
+
+// Spec is a parsed OpenAPI document.
+type Spec struct {
+	doc map[string]interface{}
+}
+
+// Operation identifies an operation of the spec.
+type Operation struct {
+	Method string
+	Path   string
+	// Parameters reports whether the operation has path or required query parameters.
+	Parameters bool
+}
+
+// Load reads the OpenAPI document at path.
+func Load(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Parse parses an OpenAPI document in YAML or JSON.
+func Parse(data []byte) (*Spec, error) {
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	doc, ok := configext.Normalize(raw).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("not an OpenAPI document")
+	}
+	if _, ok := doc["openapi"]; !ok {
+		return nil, fmt.Errorf("not an OpenAPI 3 document, openapi is missing")
+	}
+	return &Spec{doc: doc}, nil
+}
+
+// ServerURL returns the URL of the first server of the spec, if any.
+func (s *Spec) ServerURL() string {
+	servers, _ := s.doc["servers"].([]interface{})
+	if len(servers) == 0 {
+		return ""
+	}
+	server, _ := servers[0].(map[string]interface{})
+	u, _ := server["url"].(string)
+	return u
+}
+
+var methods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// Operations lists the operations of the spec, sorted by path and method.
+func (s *Spec) Operations() []Operation {
+	var ops []Operation
+	paths, _ := s.doc["paths"].(map[string]interface{})
+	for path, item := range paths {
+		item, _ := s.resolve(item).(map[string]interface{})
+		for _, method := range methods {
+			op, ok := item[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			ops = append(ops, Operation{
+				Method:     strings.ToUpper(method),
+				Path:       path,
+				Parameters: strings.Contains(path, "{") || s.hasRequiredQuery(item, op),
+			})
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		if ops[i].Path != ops[j].Path {
+			return ops[i].Path < ops[j].Path
+		}
+		return ops[i].Method < ops[j].Method
+	})
+	return ops
+}
+
+func (s *Spec) hasRequiredQuery(item, op map[string]interface{}) bool {
+	params, _ := item["parameters"].([]interface{})
+	opParams, _ := op["parameters"].([]interface{})
+	for _, p := range append(params, opParams...) {
+		p, _ := s.resolve(p).(map[string]interface{})
+		if p["in"] == "query" && p["required"] == true {
+			return true
+		}
+	}
+	return false
+}
+
+// operation finds the operation matching method and a concrete request path.
+func (s *Spec) operation(method, path string) (map[string]interface{}, string) {
+	paths, _ := s.doc["paths"].(map[string]interface{})
+	// Prefer templates without parameters, e.g. /pet/mine over /pet/{id}.
+	var candidates []string
+	for template := range paths {
+		if matchTemplate(template, path) {
+			candidates = append(candidates, template)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return strings.Count(candidates[i], "{") < strings.Count(candidates[j], "{")
+	})
+	for _, template := range candidates {
+		item, _ := s.resolve(paths[template]).(map[string]interface{})
+		if op, ok := item[strings.ToLower(method)].(map[string]interface{}); ok {
+			return op, template
+		}
+	}
+	return nil, ""
+}
+
+func matchTemplate(template, path string) bool {
+	t := strings.Split(strings.Trim(template, "/"), "/")
+	p := strings.Split(strings.Trim(path, "/"), "/")
+	if len(t) != len(p) {
+		return false
+	}
+	for i := range t {
+		if strings.HasPrefix(t[i], "{") && strings.HasSuffix(t[i], "}") {
+			if p[i] == "" {
+				return false
+			}
+			continue
+		}
+		if t[i] != p[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// response finds the documented response for status: the exact code, then
+// its range such as 2XX, then default.
+func (s *Spec) response(op map[string]interface{}, status int) (map[string]interface{}, bool) {
+	responses, _ := op["responses"].(map[string]interface{})
+	code := strconv.Itoa(status)
+	for _, key := range []string{code, code[:1] + "XX", code[:1] + "xx", "default"} {
+		if r, ok := responses[key]; ok {
+			resp, _ := s.resolve(r).(map[string]interface{})
+			return resp, true
+		}
+	}
+	return nil, false
+}
+
+// resolve follows $ref until it reaches a value without one.
+func (s *Spec) resolve(value interface{}) interface{} {
+	for i := 0; i < 32; i++ {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return value
+		}
+		ref, ok := m["$ref"].(string)
+		if !ok {
+			return value
+		}
+		value = s.lookup(ref)
+	}
+	return value
+}
+
+// lookup returns the value referenced by a local $ref such as #/components/schemas/Pet.
+func (s *Spec) lookup(ref string) interface{} {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil
+	}
+	var node interface{} = s.doc
+	for _, token := range strings.Split(ref[2:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		node = m[token]
+	}
+	return node
+}
+
+// VerifyResponse checks a response of the operation at method and path,
+// where path is relative to the server URL.
+func (s *Spec) VerifyResponse(method, path string, status int, header http.Header, body []byte) []Violation {
+	op, template := s.operation(method, path)
+	if op == nil {
+		return []Violation{{Location: LocationOperation, Message: fmt.Sprintf("%s %s is not in the spec", method, path)}}
+	}
+	resp, ok := s.response(op, status)
+	if !ok {
+		return []Violation{{Location: LocationStatus, Message: fmt.Sprintf("status %d is not documented for %s %s", status, method, template)}}
+	}
+	content, _ := resp["content"].(map[string]interface{})
+	if len(content) == 0 {
+		if len(body) > 0 {
+			return []Violation{{Location: LocationBody, Message: fmt.Sprintf("status %d is documented without a body", status)}}
+		}
+		return nil
+	}
+
+	contentType := header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return []Violation{{Location: LocationContentType, Message: fmt.Sprintf("invalid content type %q", contentType)}}
+	}
+	media, ok := matchMedia(content, mediaType)
+	if !ok {
+		documented := make([]string, 0, len(content))
+		for k := range content {
+			documented = append(documented, k)
+		}
+		sort.Strings(documented)
+		return []Violation{{Location: LocationContentType, Message: fmt.Sprintf("content type %s is not one of %s", mediaType, strings.Join(documented, ", "))}}
+	}
+	schema, hasSchema := media["schema"]
+	if !hasSchema || !isJSON(mediaType) {
+		return nil
+	}
+	return s.validateJSON(schema, body)
+}
+
+func matchMedia(content map[string]interface{}, mediaType string) (map[string]interface{}, bool) {
+	candidates := []string{mediaType, strings.SplitN(mediaType, "/", 2)[0] + "/*", "*/*"}
+	for _, key := range candidates {
+		for documented, media := range content {
+			if strings.EqualFold(documented, key) {
+				m, _ := media.(map[string]interface{})
+				return m, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func isJSON(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
@@ -0,0 +1,76 @@
+package contract
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/anz-bank/sysl-go/syslgo"
+
+	"github.com/anz-bank/sysl-go-demo/src/testhooks"
+)
+
+// Check fails the test with every violation of resp against the spec. path
+// is relative to the server URL. The body of resp is read and replaced, so
+// resp can still be used after the check.
+func (s *Spec) Check(t syslgo.TestingT, method, path string, resp *http.Response) {
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		t.Errorf("contract: %s %s: reading body: %v", method, path, err)
+		return
+	}
+	report(t, method, path, s.VerifyResponse(method, path, resp.StatusCode, resp.Header, body))
+}
+
+// Wrap returns createService with the responses of the named downstreams
+// checked against their spec, whether they come from the mocks of
+// NewTestServer or from the real services of NewIntegrationTestServer.
+// Downstreams without a spec are not checked.
+func Wrap(t syslgo.TestingT, createService interface{}, specs map[string]*Spec) interface{} {
+	return testhooks.WrapDownstreams(createService, func(serviceName, serviceURL string, rt http.RoundTripper) http.RoundTripper {
+		spec, ok := specs[serviceName]
+		if !ok {
+			return rt
+		}
+		prefix := ""
+		if u, err := url.Parse(serviceURL); err == nil {
+			prefix = strings.TrimSuffix(u.Path, "/")
+		}
+		return &checkingTransport{t: t, spec: spec, prefix: prefix, base: rt}
+	})
+}
+
+type checkingTransport struct {
+	t      syslgo.TestingT
+	spec   *Spec
+	prefix string
+	base   http.RoundTripper
+}
+
+func (c *checkingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := c.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	path := strings.TrimPrefix(req.URL.Path, c.prefix)
+	if path == "" {
+		path = "/"
+	}
+	c.spec.Check(c.t, req.Method, path, resp)
+	return resp, nil
+}
+
+func report(t syslgo.TestingT, method, path string, violations []Violation) {
+	if len(violations) == 0 {
+		return
+	}
+	lines := make([]string, len(violations))
+	for i, v := range violations {
+		lines[i] = "  " + v.String()
+	}
+	t.Errorf("contract: %s %s violates the spec:\n%s", method, path, strings.Join(lines, "\n"))
+}
@@ -0,0 +1,226 @@
+// Package cors answers CORS preflight requests and adds CORS headers to the
+// responses of the public server, for browser clients on other origins.
+package cors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anz-bank/sysl-go-demo/src/configext"
+)
+
+// Extension is the config extension holding the CORS settings.
+const Extension = "genCode.upstream.http.cors"
+
+var defaultMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+
+// Config configures CORS, found under genCode.upstream.http.cors.
+type Config struct {
+	// AllowedOrigins are exact origins such as https://pets.example.com,
+	// wildcard subdomains such as https://*.example.com, or * for any origin.
+	AllowedOrigins []string `yaml:"allowedOrigins" mapstructure:"allowedOrigins"`
+	// AllowedMethods defaults to GET, HEAD and POST.
+	AllowedMethods []string `yaml:"allowedMethods" mapstructure:"allowedMethods"`
+	// AllowedHeaders are the request headers browsers may send, or * for any.
+	AllowedHeaders []string `yaml:"allowedHeaders" mapstructure:"allowedHeaders"`
+	// ExposedHeaders are the response headers scripts may read.
+	ExposedHeaders   []string      `yaml:"exposedHeaders" mapstructure:"exposedHeaders"`
+	AllowCredentials bool          `yaml:"allowCredentials" mapstructure:"allowCredentials"`
+	MaxAge           time.Duration `yaml:"maxAge" mapstructure:"maxAge"`
+}
+
+// CORS holds the compiled settings.
+type CORS struct {
+	anyOrigin  bool
+	origins    map[string]bool
+	suffixes   []originSuffix
+	methods    map[string]bool
+	anyHeader  bool
+	headers    map[string]bool
+	allow      string // Access-Control-Allow-Methods
+	expose     string
+	maxAge     string
+	credential bool
+}
+
+// originSuffix matches the subdomains of a wildcard origin.
+type originSuffix struct {
+	scheme string
+	suffix string // e.g. ".example.com" or ".example.com:8443"
+}
+
+// New compiles the CORS settings found in ext. It returns nil if there are none.
+func New(ext *configext.Extensions) (*CORS, error) {
+	found := ext.Get(Extension)
+	if len(found) == 0 {
+		return nil, nil
+	}
+	var cfg Config
+	if err := found[0].Decode(&cfg); err != nil {
+		return nil, err
+	}
+	c, err := compile(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", found[0].Path, err)
+	}
+	return c, nil
+}
+
+func compile(cfg Config) (*CORS, error) {
+	if len(cfg.AllowedOrigins) == 0 {
+		return nil, errors.New("allowedOrigins must be set")
+	}
+	c := &CORS{
+		origins:    make(map[string]bool),
+		methods:    make(map[string]bool),
+		headers:    make(map[string]bool),
+		credential: cfg.AllowCredentials,
+	}
+	for _, origin := range cfg.AllowedOrigins {
+		switch {
+		case origin == "*":
+			c.anyOrigin = true
+		case strings.Contains(origin, "*"):
+			u, err := url.Parse(strings.Replace(origin, "*.", "wildcard.", 1))
+			if err != nil || !strings.HasPrefix(u.Host, "wildcard.") || strings.Contains(u.Host[len("wildcard."):], "*") || u.Path != "" {
+				return nil, fmt.Errorf("invalid wildcard origin %q, expected e.g. https://*.example.com", origin)
+			}
+			c.suffixes = append(c.suffixes, originSuffix{scheme: u.Scheme, suffix: strings.ToLower(u.Host[len("wildcard"):])})
+		default:
+			u, err := url.Parse(origin)
+			if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+				return nil, fmt.Errorf("invalid origin %q, expected scheme://host[:port]", origin)
+			}
+			c.origins[strings.ToLower(u.Scheme+"://"+u.Host)] = true
+		}
+	}
+	if c.anyOrigin && c.credential {
+		return nil, errors.New("allowCredentials cannot be combined with allowedOrigins *")
+	}
+
+	allowed := cfg.AllowedMethods
+	if len(allowed) == 0 {
+		allowed = defaultMethods
+	}
+	methods := make([]string, 0, len(allowed))
+	for _, m := range allowed {
+		m = strings.ToUpper(m)
+		methods = append(methods, m)
+		c.methods[m] = true
+	}
+	c.allow = strings.Join(methods, ", ")
+	for _, h := range cfg.AllowedHeaders {
+		if h == "*" {
+			c.anyHeader = true
+			continue
+		}
+		c.headers[strings.ToLower(h)] = true
+	}
+	c.expose = strings.Join(cfg.ExposedHeaders, ", ")
+	if cfg.MaxAge > 0 {
+		c.maxAge = strconv.Itoa(int(cfg.MaxAge / time.Second))
+	}
+	return c, nil
+}
+
+func (c *CORS) originAllowed(origin string) bool {
+	if c.anyOrigin {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if c.origins[origin] {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	for _, s := range c.suffixes {
+		if u.Scheme == s.scheme && strings.HasSuffix(u.Host, s.suffix) && len(u.Host) > len(s.suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handler answers preflight requests itself and adds CORS headers to the
+// responses of every other request from an allowed origin. It is meant to
+// run before any other middleware, so that preflight requests, which carry
+// no credentials, are not rejected by authorization. A nil *CORS passes
+// every request through.
+func (c *CORS) Handler(next http.Handler) http.Handler {
+	if c == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			c.preflight(w, r, origin)
+			return
+		}
+		if c.originAllowed(origin) {
+			c.setOrigin(h, origin)
+			if c.expose != "" {
+				h.Set("Access-Control-Expose-Headers", c.expose)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (c *CORS) preflight(w http.ResponseWriter, r *http.Request, origin string) {
+	h := w.Header()
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+	method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+	if !c.originAllowed(origin) || !c.methods[method] {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	var requested []string
+	for _, value := range r.Header.Values("Access-Control-Request-Headers") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				requested = append(requested, name)
+			}
+		}
+	}
+	for _, name := range requested {
+		if !c.anyHeader && !c.headers[strings.ToLower(name)] {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+	}
+
+	c.setOrigin(h, origin)
+	h.Set("Access-Control-Allow-Methods", c.allow)
+	if len(requested) > 0 {
+		h.Set("Access-Control-Allow-Headers", strings.Join(requested, ", "))
+	}
+	if c.maxAge != "" {
+		h.Set("Access-Control-Max-Age", c.maxAge)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *CORS) setOrigin(h http.Header, origin string) {
+	if c.anyOrigin && !c.credential {
+		h.Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	h.Set("Access-Control-Allow-Origin", origin)
+	if c.credential {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
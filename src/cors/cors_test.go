@@ -0,0 +1,231 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/anz-bank/sysl-go-demo/src/configext"
+)
+
+func mustCompile(t *testing.T, cfg Config) *CORS {
+	t.Helper()
+	c, err := compile(cfg)
+	require.NoError(t, err)
+	return c
+}
+
+func TestOriginAllowed(t *testing.T) {
+	t.Parallel()
+
+	c := mustCompile(t, Config{AllowedOrigins: []string{
+		"https://pets.example.com",
+		"http://localhost:3000/",
+		"https://*.Example.org",
+		"https://*.internal.example.net:8443",
+	}})
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://pets.example.com", true},
+		{"HTTPS://Pets.Example.com", true},
+		{"http://pets.example.com", false},
+		{"https://pets.example.com:8443", false},
+		{"https://shop.example.com", false},
+		{"https://pets.example.com.evil.com", false},
+		{"http://localhost:3000", true},
+		{"http://localhost:3001", false},
+		{"http://localhost", false},
+
+		// Wildcards match any subdomain, with the same scheme and port.
+		{"https://a.example.org", true},
+		{"https://a.b.example.org", true},
+		{"https://A.Example.ORG", true},
+		{"https://example.org", false},
+		{"https://.example.org", false},
+		{"http://a.example.org", false},
+		{"https://a.example.org:8443", false},
+		{"https://evilexample.org", false},
+		{"https://a.example.org.evil.com", false},
+		{"https://api.internal.example.net:8443", true},
+		{"https://api.internal.example.net", false},
+
+		{"null", false},
+		{"", false},
+		{"://bad", false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.origin, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, c.originAllowed(tt.origin))
+		})
+	}
+
+	anyOrigins := mustCompile(t, Config{AllowedOrigins: []string{"*"}})
+	require.True(t, anyOrigins.originAllowed("https://anything.example.com"))
+	require.True(t, anyOrigins.originAllowed("null"))
+}
+
+func TestCompileErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		cfg  Config
+		err  string
+	}{
+		{"no origins", Config{}, "allowedOrigins must be set"},
+		{"no scheme", Config{AllowedOrigins: []string{"pets.example.com"}}, `invalid origin "pets.example.com"`},
+		{"path", Config{AllowedOrigins: []string{"https://pets.example.com/app"}}, "expected scheme://host[:port]"},
+		{"wildcard in the middle", Config{AllowedOrigins: []string{"https://pets.*.example.com"}}, "invalid wildcard origin"},
+		{"two wildcards", Config{AllowedOrigins: []string{"https://*.*.example.com"}}, "invalid wildcard origin"},
+		{"wildcard without dot", Config{AllowedOrigins: []string{"https://*example.com"}}, "invalid wildcard origin"},
+		{"wildcard with path", Config{AllowedOrigins: []string{"https://*.example.com/app"}}, "invalid wildcard origin"},
+		{"any origin with credentials", Config{AllowedOrigins: []string{"*"}, AllowCredentials: true}, "cannot be combined"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := compile(tt.cfg)
+			require.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	_, ext, err := configext.Split([]byte("genCode:\n  upstream:\n    http:\n      cors:\n        allowedOrigins: [https://*.example.com]\n        maxAge: 10m\n"), Extension)
+	require.NoError(t, err)
+	c, err := New(ext)
+	require.NoError(t, err)
+	require.True(t, c.originAllowed("https://pets.example.com"))
+	require.Equal(t, "600", c.maxAge)
+
+	_, ext, err = configext.Split([]byte("genCode:\n  upstream:\n    http:\n      cors:\n        allowedOrigins: [example.com]\n"), Extension)
+	require.NoError(t, err)
+	_, err = New(ext)
+	require.ErrorContains(t, err, "genCode.upstream.http.cors: invalid origin")
+
+	c, err = New(nil)
+	require.NoError(t, err)
+	require.Nil(t, c)
+}
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	cfg := Config{
+		AllowedOrigins: []string{"https://pets.example.com", "https://*.example.org"},
+		AllowedMethods: []string{"get", "PUT"},
+		AllowedHeaders: []string{"Authorization", "Content-Type"},
+		ExposedHeaders: []string{"X-Request-Id"},
+		MaxAge:         time.Hour,
+	}
+	credentials := cfg
+	credentials.AllowCredentials = true
+	anyOrigin := Config{AllowedOrigins: []string{"*"}, AllowedHeaders: []string{"*"}}
+
+	type headers map[string]string
+	tests := []struct {
+		name    string
+		cfg     Config
+		method  string
+		request headers
+		status  int
+		want    headers // "" means the header must be absent
+		next    bool
+	}{
+		{"no origin", cfg, http.MethodGet, headers{}, http.StatusOK,
+			headers{"Access-Control-Allow-Origin": "", "Vary": ""}, true},
+		{"allowed origin", cfg, http.MethodGet, headers{"Origin": "https://pets.example.com"}, http.StatusOK,
+			headers{"Access-Control-Allow-Origin": "https://pets.example.com", "Access-Control-Expose-Headers": "X-Request-Id", "Access-Control-Allow-Credentials": "", "Vary": "Origin"}, true},
+		{"wildcard origin", cfg, http.MethodGet, headers{"Origin": "https://shop.example.org"}, http.StatusOK,
+			headers{"Access-Control-Allow-Origin": "https://shop.example.org"}, true},
+		// The request is still served, but the browser hides the response.
+		{"disallowed origin", cfg, http.MethodGet, headers{"Origin": "https://evil.example.com"}, http.StatusOK,
+			headers{"Access-Control-Allow-Origin": "", "Vary": "Origin"}, true},
+		{"credentials", credentials, http.MethodGet, headers{"Origin": "https://pets.example.com"}, http.StatusOK,
+			headers{"Access-Control-Allow-Origin": "https://pets.example.com", "Access-Control-Allow-Credentials": "true"}, true},
+		{"any origin", anyOrigin, http.MethodGet, headers{"Origin": "https://evil.example.com"}, http.StatusOK,
+			headers{"Access-Control-Allow-Origin": "*"}, true},
+		{"options without preflight", cfg, http.MethodOptions, headers{"Origin": "https://pets.example.com"}, http.StatusOK,
+			headers{"Access-Control-Allow-Methods": ""}, true},
+
+		{"preflight", cfg, http.MethodOptions, headers{
+			"Origin":                         "https://pets.example.com",
+			"Access-Control-Request-Method":  "PUT",
+			"Access-Control-Request-Headers": "content-type, authorization",
+		}, http.StatusNoContent, headers{
+			"Access-Control-Allow-Origin":  "https://pets.example.com",
+			"Access-Control-Allow-Methods": "GET, PUT",
+			"Access-Control-Allow-Headers": "content-type, authorization",
+			"Access-Control-Max-Age":       "3600",
+		}, false},
+		{"preflight of wildcard origin", cfg, http.MethodOptions, headers{
+			"Origin":                        "https://a.b.example.org",
+			"Access-Control-Request-Method": "get",
+		}, http.StatusNoContent, headers{
+			"Access-Control-Allow-Origin":  "https://a.b.example.org",
+			"Access-Control-Allow-Headers": "",
+		}, false},
+		{"preflight of disallowed origin", cfg, http.MethodOptions, headers{
+			"Origin":                        "https://example.org",
+			"Access-Control-Request-Method": "GET",
+		}, http.StatusForbidden, headers{"Access-Control-Allow-Origin": ""}, false},
+		{"preflight of disallowed method", cfg, http.MethodOptions, headers{
+			"Origin":                        "https://pets.example.com",
+			"Access-Control-Request-Method": "DELETE",
+		}, http.StatusForbidden, headers{"Access-Control-Allow-Origin": ""}, false},
+		{"preflight of disallowed header", cfg, http.MethodOptions, headers{
+			"Origin":                         "https://pets.example.com",
+			"Access-Control-Request-Method":  "GET",
+			"Access-Control-Request-Headers": "Authorization, X-Debug",
+		}, http.StatusForbidden, headers{"Access-Control-Allow-Origin": ""}, false},
+		{"preflight of any header", anyOrigin, http.MethodOptions, headers{
+			"Origin":                         "https://pets.example.com",
+			"Access-Control-Request-Method":  "POST",
+			"Access-Control-Request-Headers": "X-Debug",
+		}, http.StatusNoContent, headers{
+			"Access-Control-Allow-Origin":  "*",
+			"Access-Control-Allow-Methods": "GET, HEAD, POST",
+			"Access-Control-Allow-Headers": "X-Debug",
+			"Access-Control-Max-Age":       "",
+		}, false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			called := false
+			h := mustCompile(t, tt.cfg).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+			}))
+			req := httptest.NewRequest(tt.method, "/pet", nil)
+			for name, value := range tt.request {
+				req.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			require.Equal(t, tt.status, w.Code)
+			require.Equal(t, tt.next, called)
+			for name, value := range tt.want {
+				require.Equal(t, value, w.Header().Get(name), name)
+			}
+		})
+	}
+}
+
+func TestNilHandler(t *testing.T) {
+	t.Parallel()
+
+	next := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	var c *CORS
+	require.NotNil(t, c.Handler(next))
+}
@@ -0,0 +1,210 @@
+// Package diagnostic implements the grpc.testing Foo and Bar services of
+// test.proto, a known endpoint of the public gRPC server to verify
+// connectivity, TLS and interceptors in each environment:
+//
+//	grpcurl -d '{"query": "ping"}' -H 'x-diagnostic-delay: 200ms' \
+//	    localhost:6062 grpc.testing.Foo/thisEndpoint
+//
+// Both RPCs echo the query and report in the response headers the server
+// that answered, the trace id of the call, the client address and the TLS
+// connection. Clients can ask for a delay or an error with request metadata,
+// to check timeouts and error handling:
+//
+//	x-diagnostic-delay: 500ms        answer after a delay, up to maxDelay
+//	x-diagnostic-code: UNAVAILABLE   fail with a gRPC code, by name or number
+//
+// The services are registered only if enabled under
+// genCode.upstream.grpc.diagnostics.
+package diagnostic
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anz-bank/sysl-go/config"
+	"github.com/anz-bank/sysl-go/core"
+	"github.com/anz-bank/sysl-go/handlerinitialiser"
+	"github.com/anz-bank/sysl-go/log"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/anz-bank/sysl-go-demo/internal/gen/pb/grpc_testing"
+	"github.com/anz-bank/sysl-go-demo/src/configext"
+)
+
+// Extension is the config extension enabling the diagnostic services.
+const Extension = "genCode.upstream.grpc.diagnostics"
+
+// Request metadata asking for a delay or an error.
+const (
+	DelayHeader = "x-diagnostic-delay"
+	CodeHeader  = "x-diagnostic-code"
+)
+
+// defaultTraceHeader is the header carrying trace ids when
+// library.trace.incomingHeaderForID is not set, as in sysl-go.
+const defaultTraceHeader = "RequestID"
+
+// Config configures the diagnostic services, found under
+// genCode.upstream.grpc.diagnostics.
+type Config struct {
+	Enabled bool `yaml:"enabled" mapstructure:"enabled"`
+	// MaxDelay caps the delays that clients can ask for, 10s by default.
+	MaxDelay time.Duration `yaml:"maxDelay" mapstructure:"maxDelay"`
+}
+
+// Service implements the Foo and Bar services.
+type Service struct {
+	pb.UnimplementedFooServer
+	pb.UnimplementedBarServer
+
+	maxDelay    time.Duration
+	hostname    string
+	traceHeader string
+}
+
+// New creates the Service configured in ext. It returns nil if the services
+// are not enabled.
+func New(ext *configext.Extensions) (*Service, error) {
+	found := ext.Get(Extension)
+	if len(found) == 0 {
+		return nil, nil
+	}
+	cfg := Config{MaxDelay: 10 * time.Second}
+	if err := found[0].Decode(&cfg); err != nil {
+		return nil, err
+	}
+	if cfg.MaxDelay < 0 {
+		return nil, fmt.Errorf("%s: maxDelay must not be negative", found[0].Path)
+	}
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	hostname, _ := os.Hostname()
+	return &Service{maxDelay: cfg.MaxDelay, hostname: hostname, traceHeader: defaultTraceHeader}, nil
+}
+
+// Handler registers the services with the public gRPC server. It has the
+// signature of server.GRPCHandler.
+func (s *Service) Handler(ctx context.Context, _ *core.Hooks) (handlerinitialiser.GrpcHandlerInitialiser, error) {
+	if cfg := config.GetDefaultConfig(ctx); cfg != nil && cfg.Library.Trace.IncomingHeaderForID != "" {
+		s.traceHeader = cfg.Library.Trace.IncomingHeaderForID
+	}
+	return s, nil
+}
+
+// RegisterServer registers the services with server.
+func (s *Service) RegisterServer(_ context.Context, server *grpc.Server) {
+	pb.RegisterFooServer(server, s)
+	pb.RegisterBarServer(server, s)
+}
+
+// ThisEndpoint serves grpc.testing.Foo/thisEndpoint.
+func (s *Service) ThisEndpoint(ctx context.Context, req *pb.Request) (*pb.Response, error) {
+	return s.serve(ctx, req)
+}
+
+// AnotherEndpoint serves grpc.testing.Bar/AnotherEndpoint.
+func (s *Service) AnotherEndpoint(ctx context.Context, req *pb.Request) (*pb.Response, error) {
+	return s.serve(ctx, req)
+}
+
+func (s *Service) serve(ctx context.Context, req *pb.Request) (*pb.Response, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	traceID, err := uuid.Parse(first(md, s.traceHeader))
+	if err != nil {
+		traceID = uuid.New()
+	}
+	ctx = log.WithStr(ctx, "traceid", traceID.String())
+	method, _ := grpc.Method(ctx)
+
+	header := metadata.Pairs(
+		"server-hostname", s.hostname,
+		"server-time", time.Now().UTC().Format(time.RFC3339Nano),
+		"traceid", traceID.String(),
+	)
+	if p, ok := peer.FromContext(ctx); ok {
+		header.Set("client-address", p.Addr.String())
+		header.Set("tls", describeTLS(p.AuthInfo))
+	}
+	if err := grpc.SetHeader(ctx, header); err != nil {
+		return nil, err
+	}
+	log.Infof(ctx, "diagnostic call %s, query %q", method, req.GetQuery())
+
+	if value := first(md, DelayHeader); value != "" {
+		delay, err := time.ParseDuration(value)
+		if err != nil || delay < 0 || delay > s.maxDelay {
+			return nil, status.Errorf(codes.InvalidArgument, "%s must be a duration between 0 and %s", DelayHeader, s.maxDelay)
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+	}
+	if value := first(md, CodeHeader); value != "" {
+		code, err := parseCode(value)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "%s: %s", CodeHeader, err)
+		}
+		if code != codes.OK {
+			return nil, status.Errorf(code, "injected by %s", CodeHeader)
+		}
+	}
+	return &pb.Response{Query: req.GetQuery()}, nil
+}
+
+// first returns the first value of the metadata key, which is matched
+// case-insensitively like HTTP headers.
+func first(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// parseCode parses a gRPC code given by name, such as UNAVAILABLE, or by number.
+func parseCode(value string) (codes.Code, error) {
+	text := value
+	if _, err := strconv.Atoi(value); err != nil {
+		text = strconv.Quote(strings.ToUpper(value))
+	}
+	var code codes.Code
+	if err := code.UnmarshalJSON([]byte(text)); err != nil {
+		return 0, fmt.Errorf("unknown code %q", value)
+	}
+	return code, nil
+}
+
+// describeTLS describes the connection of a call, e.g. "TLS 1.3
+// TLS_AES_128_GCM_SHA256, client CN=partner", or "none" without TLS.
+func describeTLS(authInfo credentials.AuthInfo) string {
+	info, ok := authInfo.(credentials.TLSInfo)
+	if !ok {
+		return "none"
+	}
+	state := info.State
+	desc := tlsVersions[state.Version] + " " + tls.CipherSuiteName(state.CipherSuite)
+	if len(state.PeerCertificates) > 0 {
+		desc += ", client " + state.PeerCertificates[0].Subject.String()
+	}
+	return desc
+}
+
+var tlsVersions = map[uint16]string{
+	tls.VersionTLS10: "TLS 1.0",
+	tls.VersionTLS11: "TLS 1.1",
+	tls.VersionTLS12: "TLS 1.2",
+	tls.VersionTLS13: "TLS 1.3",
+}
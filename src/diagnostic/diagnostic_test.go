@@ -0,0 +1,149 @@
+package diagnostic
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/anz-bank/sysl-go/log"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	pb "github.com/anz-bank/sysl-go-demo/internal/gen/pb/grpc_testing"
+	"github.com/anz-bank/sysl-go-demo/src/configext"
+)
+
+func load(t *testing.T, yaml string) (*Service, error) {
+	t.Helper()
+	_, ext, err := configext.Split([]byte(yaml), Extension)
+	require.NoError(t, err)
+	return New(ext)
+}
+
+// dial serves s over an in-memory connection and returns a client of the
+// Foo service, and the errors returned by the handlers of s.
+func dial(t *testing.T, s *Service) (pb.FooClient, <-chan error) {
+	t.Helper()
+	logger := log.NewDefaultLogger()
+	served := make(chan error, 1)
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(log.PutLogger(ctx, logger), req)
+		served <- err
+		return resp, err
+	}))
+	s.RegisterServer(context.Background(), server)
+	lis := bufconn.Listen(1 << 20)
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return pb.NewFooClient(conn), served
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	s, err := load(t, "genCode:\n  upstream:\n    grpc:\n      port: 6062\n")
+	require.NoError(t, err)
+	require.Nil(t, s, "not configured")
+
+	s, err = load(t, "genCode:\n  upstream:\n    grpc:\n      diagnostics:\n        enabled: false\n")
+	require.NoError(t, err)
+	require.Nil(t, s, "disabled")
+
+	s, err = load(t, "genCode:\n  upstream:\n    grpc:\n      diagnostics:\n        enabled: true\n")
+	require.NoError(t, err)
+	require.Equal(t, 10*time.Second, s.maxDelay)
+
+	_, err = load(t, "genCode:\n  upstream:\n    grpc:\n      diagnostics:\n        enabled: true\n        maxDelay: -1s\n")
+	require.EqualError(t, err, "genCode.upstream.grpc.diagnostics: maxDelay must not be negative")
+}
+
+func TestParseCode(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value string
+		code  codes.Code
+		err   string
+	}{
+		{"UNAVAILABLE", codes.Unavailable, ""},
+		{"unavailable", codes.Unavailable, ""},
+		{"Deadline_Exceeded", codes.DeadlineExceeded, ""},
+		{"14", codes.Unavailable, ""},
+		{"0", codes.OK, ""},
+		{"BROKEN", 0, `unknown code "BROKEN"`},
+		{"99", 0, `unknown code "99"`},
+	}
+	for _, tt := range tests {
+		code, err := parseCode(tt.value)
+		if tt.err != "" {
+			require.EqualError(t, err, tt.err, tt.value)
+			continue
+		}
+		require.NoError(t, err, tt.value)
+		require.Equal(t, tt.code, code, tt.value)
+	}
+}
+
+func TestServe(t *testing.T) {
+	t.Parallel()
+
+	s := &Service{maxDelay: time.Second, hostname: "petdemo-0", traceHeader: defaultTraceHeader}
+	client, served := dial(t, s)
+	call := func(ctx context.Context, pairs ...string) (*pb.Response, metadata.MD, error) {
+		var header metadata.MD
+		resp, err := client.ThisEndpoint(metadata.AppendToOutgoingContext(ctx, pairs...), &pb.Request{Query: "ping"}, grpc.Header(&header))
+		<-served
+		return resp, header, err
+	}
+	ctx := context.Background()
+
+	resp, header, err := call(ctx, defaultTraceHeader, "0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0")
+	require.NoError(t, err)
+	require.Equal(t, "ping", resp.GetQuery())
+	require.Equal(t, []string{"petdemo-0"}, header.Get("server-hostname"))
+	require.Equal(t, []string{"0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0"}, header.Get("traceid"))
+	require.Equal(t, []string{"none"}, header.Get("tls"))
+
+	tests := []struct {
+		name  string
+		pairs []string
+		code  codes.Code
+	}{
+		{"delay", []string{DelayHeader, "10ms"}, codes.OK},
+		{"invalid delay", []string{DelayHeader, "soon"}, codes.InvalidArgument},
+		{"delay above maxDelay", []string{DelayHeader, "2s"}, codes.InvalidArgument},
+		{"negative delay", []string{DelayHeader, "-1ms"}, codes.InvalidArgument},
+		{"code by name", []string{CodeHeader, "UNAVAILABLE"}, codes.Unavailable},
+		{"code by number", []string{CodeHeader, "7"}, codes.PermissionDenied},
+		{"code in lower case", []string{CodeHeader, "not_found"}, codes.NotFound},
+		{"OK", []string{CodeHeader, "OK"}, codes.OK},
+		{"unknown code", []string{CodeHeader, "BROKEN"}, codes.InvalidArgument},
+		{"delay then code", []string{DelayHeader, "10ms", CodeHeader, "ABORTED"}, codes.Aborted},
+	}
+	for _, tt := range tests {
+		_, _, err := call(ctx, tt.pairs...)
+		require.Equal(t, tt.code, status.Code(err), "%s: %v", tt.name, err)
+	}
+
+	// The delay ends with the call.
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = client.ThisEndpoint(metadata.AppendToOutgoingContext(timeout, DelayHeader, "1s"), &pb.Request{})
+	require.Equal(t, codes.DeadlineExceeded, status.Code(err), err)
+	require.Equal(t, codes.DeadlineExceeded, status.Code(<-served))
+	require.Less(t, time.Since(start), 500*time.Millisecond)
+}
@@ -0,0 +1,94 @@
+// Package downstream adds per-downstream behaviour, configured next to the
+// sysl-go settings of each downstream, to the downstream HTTP clients.
+package downstream
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/anz-bank/sysl-go-demo/src/configext"
+	"github.com/anz-bank/sysl-go-demo/src/oauth2"
+	"github.com/anz-bank/sysl-go-demo/src/signing"
+	"github.com/anz-bank/sysl-go/config"
+)
+
+const (
+	// AuthExtension is the config extension holding the credentials used to
+	// call a downstream, e.g. genCode.downstream.petstore.auth.
+	AuthExtension = "genCode.downstream.*.auth"
+	// SigningExtension is the config extension holding the key that requests
+	// to a downstream are signed with, e.g. genCode.downstream.petstore.signing.
+	SigningExtension = "genCode.downstream.*.signing"
+)
+
+// Extensions lists the config extensions read by this package, to be removed
+// from the config file with configext.Split.
+var Extensions = []string{AuthExtension, SigningExtension}
+
+// AuthConfig configures how requests to a downstream are authenticated.
+type AuthConfig struct {
+	OAuth2 *oauth2.Config `yaml:"oauth2" mapstructure:"oauth2"`
+}
+
+// Transports holds the RoundTripper wrappers of each downstream.
+type Transports struct {
+	wrappers map[string][]func(http.RoundTripper) http.RoundTripper
+}
+
+// NewTransports creates the wrappers configured in ext.
+func NewTransports(ctx context.Context, ext *configext.Extensions) (*Transports, error) {
+	t := &Transports{wrappers: make(map[string][]func(http.RoundTripper) http.RoundTripper)}
+	for _, x := range ext.Get(AuthExtension) {
+		var cfg AuthConfig
+		if err := x.Decode(&cfg); err != nil {
+			return nil, err
+		}
+		if cfg.OAuth2 != nil {
+			tokenClient, err := config.DefaultHTTPClient(ctx, nil)
+			if err != nil {
+				return nil, err
+			}
+			source, err := oauth2.NewTokenSource(*cfg.OAuth2, tokenClient)
+			if err != nil {
+				return nil, fmt.Errorf("%s.oauth2: %w", x.Path, err)
+			}
+			t.add(x.Name, func(rt http.RoundTripper) http.RoundTripper {
+				return oauth2.NewTransport(source, rt)
+			})
+		}
+	}
+	for _, x := range ext.Get(SigningExtension) {
+		var cfg signing.SignerConfig
+		if err := x.Decode(&cfg); err != nil {
+			return nil, err
+		}
+		signer, err := signing.NewSigner(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", x.Path, err)
+		}
+		// Runs after the token is added, so that the Authorization header can be signed.
+		t.add(x.Name, func(rt http.RoundTripper) http.RoundTripper {
+			return signing.NewTransport(signer, rt)
+		})
+	}
+	return t, nil
+}
+
+func (t *Transports) add(serviceName string, wrap func(http.RoundTripper) http.RoundTripper) {
+	name := strings.ToLower(serviceName)
+	t.wrappers[name] = append(t.wrappers[name], wrap)
+}
+
+// RoundTripper wraps the transport of the named downstream, so that requests
+// pass through the wrappers in the order they were added. It has the
+// signature of core.Hooks.DownstreamRoundTripper.
+func (t *Transports) RoundTripper(serviceName, _ string, original http.RoundTripper) http.RoundTripper {
+	rt := original
+	wrappers := t.wrappers[strings.ToLower(serviceName)]
+	for i := len(wrappers) - 1; i >= 0; i-- {
+		rt = wrappers[i](rt)
+	}
+	return rt
+}
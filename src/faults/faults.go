@@ -0,0 +1,206 @@
+// Package faults provides faults for the mocked downstreams of e2e tests,
+// to test how a service copes with slow, failing and misbehaving
+// downstreams.
+//
+// Faults are e2e.Tests. Latency only delays the call, and can precede the
+// response of a generated mock:
+//
+//	srv.Mocks.Petstore.GetPetList.Expect(faults.Latency(time.Second)).MockResponse(200, nil, pet)
+//
+// The other faults send the response themselves, so they are registered on
+// the endpoint of the downstream instead, which replaces the generated mock
+// of the same operation:
+//
+//	pets := srv.GetE2eTester().NewDownstream("petstore", "GET", "/pet")
+//	faults.FailFirst(pets, 2, faults.ConnectionReset(), e2e.Response(200, nil, pet))
+//
+// Random faults take a seeded *rand.Rand so that tests are deterministic.
+package faults
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/anz-bank/sysl-go/syslgo"
+	"github.com/anz-bank/sysl-go/testutil/e2e"
+)
+
+// Latency delays the call by d, or until the caller gives up.
+func Latency(d time.Duration) e2e.Tests {
+	return func(_ syslgo.TestingT, _ http.ResponseWriter, r *http.Request) {
+		Sleep(r.Context(), d)
+	}
+}
+
+// RandomLatency delays the call by a duration drawn uniformly from [min, max).
+func RandomLatency(rnd *rand.Rand, min, max time.Duration) e2e.Tests {
+	src := newSource(rnd)
+	return func(_ syslgo.TestingT, _ http.ResponseWriter, r *http.Request) {
+		d := min
+		if max > min {
+			d += time.Duration(src.int63n(int64(max - min)))
+		}
+		Sleep(r.Context(), d)
+	}
+}
+
+// ConnectionReset aborts the connection with a TCP reset before responding.
+func ConnectionReset() e2e.Tests {
+	return func(t syslgo.TestingT, w http.ResponseWriter, _ *http.Request) {
+		conn := hijack(t, w)
+		if conn == nil {
+			return
+		}
+		if tcp, ok := conn.(*net.TCPConn); ok {
+			// Discard unsent data and send RST instead of FIN on close.
+			_ = tcp.SetLinger(0)
+		}
+		_ = conn.Close()
+	}
+}
+
+// TruncatedBody responds with the headers and Content-Length of body, then
+// closes the connection after the first n bytes of it.
+func TruncatedBody(code int, headers map[string]string, body []byte, n int) e2e.Tests {
+	if n > len(body) {
+		n = len(body)
+	}
+	return func(t syslgo.TestingT, w http.ResponseWriter, _ *http.Request) {
+		for k, v := range headers {
+			w.Header().Set(k, v)
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(code)
+		_, _ = w.Write(body[:n])
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		if conn := hijack(t, w); conn != nil {
+			_ = conn.Close()
+		}
+	}
+}
+
+// MalformedJSON responds with a JSON content type and a body that is not
+// valid JSON.
+func MalformedJSON(code int) e2e.Tests {
+	return e2e.Response(code, map[string]string{"Content-Type": "application/json"}, []byte(`{"message": "unterminated`))
+}
+
+// Trickle responds with body in chunks of size bytes, one every interval,
+// for testing read timeouts that a slow but live downstream does not trip
+// before the response headers arrive.
+func Trickle(code int, headers map[string]string, body []byte, size int, interval time.Duration) e2e.Tests {
+	if size <= 0 {
+		size = 1
+	}
+	return func(_ syslgo.TestingT, w http.ResponseWriter, r *http.Request) {
+		for k, v := range headers {
+			w.Header().Set(k, v)
+		}
+		w.WriteHeader(code)
+		flusher, _ := w.(http.Flusher)
+		for start := 0; start < len(body); start += size {
+			if start > 0 && !Sleep(r.Context(), interval) {
+				return
+			}
+			end := start + size
+			if end > len(body) {
+				end = len(body)
+			}
+			if _, err := w.Write(body[start:end]); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// Probabilistic applies fault with probability p, and success otherwise.
+func Probabilistic(rnd *rand.Rand, p float64, fault, success e2e.Tests) e2e.Tests {
+	src := newSource(rnd)
+	return func(t syslgo.TestingT, w http.ResponseWriter, r *http.Request) {
+		if src.float64() < p {
+			fault(t, w, r)
+		} else {
+			success(t, w, r)
+		}
+	}
+}
+
+// Times expects n calls to endpoint, each running tests.
+func Times(endpoint e2e.Endpoint, n int, tests ...e2e.Tests) e2e.Endpoint {
+	for i := 0; i < n; i++ {
+		endpoint = endpoint.Expect(tests...)
+	}
+	return endpoint
+}
+
+// FailFirst expects n calls to endpoint failing with fault, followed by one
+// call answered by success.
+func FailFirst(endpoint e2e.Endpoint, n int, fault, success e2e.Tests) e2e.Endpoint {
+	return Times(endpoint, n, fault).Expect(success)
+}
+
+// hijack takes over the connection of w, failing the test if it cannot.
+func hijack(t syslgo.TestingT, w http.ResponseWriter) net.Conn {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		t.Errorf("faults: %T does not support hijacking", w)
+		return nil
+	}
+	conn, _, err := hj.Hijack()
+	if err != nil {
+		t.Errorf("faults: hijack: %v", err)
+		return nil
+	}
+	return conn
+}
+
+// Sleep waits for d and reports whether it did before ctx was done.
+func Sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// source makes a *rand.Rand safe for concurrent calls.
+type source struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func newSource(rnd *rand.Rand) *source {
+	if rnd == nil {
+		panic(fmt.Sprintf("faults: nil %T, use rand.New(rand.NewSource(seed))", rnd))
+	}
+	return &source{rnd: rnd}
+}
+
+func (s *source) int63n(n int64) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rnd.Int63n(n)
+}
+
+func (s *source) float64() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rnd.Float64()
+}
@@ -0,0 +1,191 @@
+package faults
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/anz-bank/sysl-go/testutil/e2e"
+	"github.com/stretchr/testify/require"
+)
+
+// downstream returns a mocked downstream endpoint and a client calling it.
+// Connections are not reused, as http.Transport retries requests that fail
+// on a reused connection, which would hide the faults.
+func downstream(t *testing.T) (e2e.Endpoint, func(timeout time.Duration) (*http.Response, error)) {
+	t.Helper()
+	tester, _, _ := e2e.NewTester(t, context.Background(), nil)
+	_, serviceURL, err := tester.HTTPClientGetter("petstore")
+	require.NoError(t, err)
+	// Fails the test if any expected call was not made.
+	t.Cleanup(tester.Close)
+
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	get := func(timeout time.Duration) (*http.Response, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		t.Cleanup(cancel)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, serviceURL+"/pet", nil)
+		require.NoError(t, err)
+		return client.Do(req)
+	}
+	return tester.NewDownstream("petstore", http.MethodGet, "/pet"), get
+}
+
+func readAll(t *testing.T, resp *http.Response) (string, error) {
+	t.Helper()
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func ok() e2e.Tests {
+	return e2e.Response(http.StatusOK, map[string]string{"Content-Type": "application/json"}, []byte(`{"ok":true}`))
+}
+
+func TestLatency(t *testing.T) {
+	t.Parallel()
+
+	endpoint, get := downstream(t)
+	endpoint.Expect(Latency(100*time.Millisecond), ok())
+	start := time.Now()
+	resp, err := get(5 * time.Second)
+	require.NoError(t, err)
+	body, err := readAll(t, resp)
+	require.NoError(t, err)
+	require.Equal(t, `{"ok":true}`, body)
+	require.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+}
+
+func TestLatencyTimeout(t *testing.T) {
+	t.Parallel()
+
+	endpoint, get := downstream(t)
+	endpoint.Expect(Latency(time.Minute))
+	start := time.Now()
+	_, err := get(50 * time.Millisecond)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), 10*time.Second)
+}
+
+func TestRandomLatency(t *testing.T) {
+	t.Parallel()
+
+	endpoint, get := downstream(t)
+	Times(endpoint, 3, RandomLatency(rand.New(rand.NewSource(1)), 20*time.Millisecond, 40*time.Millisecond), ok())
+	for i := 0; i < 3; i++ {
+		start := time.Now()
+		resp, err := get(5 * time.Second)
+		require.NoError(t, err)
+		_, err = readAll(t, resp)
+		require.NoError(t, err)
+		require.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	}
+}
+
+func TestConnectionReset(t *testing.T) {
+	t.Parallel()
+
+	endpoint, get := downstream(t)
+	FailFirst(endpoint, 2, ConnectionReset(), ok())
+	for i := 0; i < 2; i++ {
+		_, err := get(5 * time.Second)
+		require.Error(t, err)
+	}
+	resp, err := get(5 * time.Second)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	_, err = readAll(t, resp)
+	require.NoError(t, err)
+}
+
+func TestTruncatedBody(t *testing.T) {
+	t.Parallel()
+
+	endpoint, get := downstream(t)
+	endpoint.Expect(TruncatedBody(http.StatusOK, map[string]string{"Content-Type": "application/json"}, []byte(`{"name":"rex"}`), 5))
+	resp, err := get(5 * time.Second)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, int64(14), resp.ContentLength)
+	body, err := readAll(t, resp)
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	require.Equal(t, `{"nam`, body)
+}
+
+func TestMalformedJSON(t *testing.T) {
+	t.Parallel()
+
+	endpoint, get := downstream(t)
+	endpoint.Expect(MalformedJSON(http.StatusInternalServerError))
+	resp, err := get(5 * time.Second)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	body, err := readAll(t, resp)
+	require.NoError(t, err)
+	var v interface{}
+	require.Error(t, json.Unmarshal([]byte(body), &v))
+}
+
+func TestTrickle(t *testing.T) {
+	t.Parallel()
+
+	endpoint, get := downstream(t)
+	endpoint.Expect(Trickle(http.StatusOK, map[string]string{"Content-Type": "text/plain"}, []byte("abcdefg"), 3, 30*time.Millisecond))
+	start := time.Now()
+	resp, err := get(5 * time.Second)
+	require.NoError(t, err)
+	body, err := readAll(t, resp)
+	require.NoError(t, err)
+	require.Equal(t, "abcdefg", body)
+	// Three chunks, with two intervals between them.
+	require.GreaterOrEqual(t, time.Since(start), 60*time.Millisecond)
+}
+
+func TestProbabilistic(t *testing.T) {
+	t.Parallel()
+
+	fault := e2e.Response(http.StatusServiceUnavailable, nil, nil)
+	statuses := func(seed int64, p float64) []int {
+		endpoint, get := downstream(t)
+		Times(endpoint, 10, Probabilistic(rand.New(rand.NewSource(seed)), p, fault, ok()))
+		var got []int
+		for i := 0; i < 10; i++ {
+			resp, err := get(5 * time.Second)
+			require.NoError(t, err)
+			_, _ = readAll(t, resp)
+			got = append(got, resp.StatusCode)
+		}
+		return got
+	}
+	count := func(statuses []int, code int) int {
+		n := 0
+		for _, s := range statuses {
+			if s == code {
+				n++
+			}
+		}
+		return n
+	}
+
+	require.Equal(t, 10, count(statuses(1, 0), http.StatusOK))
+	require.Equal(t, 10, count(statuses(1, 1), http.StatusServiceUnavailable))
+	half := statuses(7, 0.5)
+	require.Equal(t, 10, count(half, http.StatusOK)+count(half, http.StatusServiceUnavailable))
+	require.NotZero(t, count(half, http.StatusOK))
+	require.NotZero(t, count(half, http.StatusServiceUnavailable))
+	// The same seed gives the same faults.
+	require.Equal(t, half, statuses(7, 0.5))
+}
+
+func TestNilRand(t *testing.T) {
+	t.Parallel()
+
+	require.PanicsWithValue(t, "faults: nil *rand.Rand, use rand.New(rand.NewSource(seed))", func() {
+		Probabilistic(nil, 0.5, ok(), ok())
+	})
+}
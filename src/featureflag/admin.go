@@ -0,0 +1,58 @@
+package featureflag
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/anz-bank/sysl-go-demo/src/admin"
+	"github.com/go-chi/chi"
+)
+
+type overrideRequest struct {
+	Enabled bool   `json:"enabled"`
+	TTL     string `json:"ttl"`
+}
+
+type overrideResponse struct {
+	Name    string    `json:"name"`
+	Enabled bool      `json:"enabled"`
+	Expires time.Time `json:"expires"`
+}
+
+// WireAdminRoutes adds the flag endpoints to an admin router:
+//
+//	GET    /         lists every flag with its definition and active override
+//	PUT    /{name}   overrides a flag, body {"enabled": true, "ttl": "15m"}
+//	DELETE /{name}   removes the override of a flag
+func (s *Service) WireAdminRoutes(r chi.Router) {
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		admin.WriteJSON(w, http.StatusOK, s.States())
+	})
+	r.Put("/{name}", func(w http.ResponseWriter, r *http.Request) {
+		var req overrideRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid override request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		var ttl time.Duration
+		if req.TTL != "" {
+			var err error
+			if ttl, err = time.ParseDuration(req.TTL); err != nil {
+				http.Error(w, "invalid ttl: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		name := chi.URLParam(r, "name")
+		expires, err := s.Override(name, req.Enabled, ttl)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		admin.WriteJSON(w, http.StatusOK, overrideResponse{Name: name, Enabled: req.Enabled, Expires: expires})
+	})
+	r.Delete("/{name}", func(w http.ResponseWriter, r *http.Request) {
+		s.ClearOverride(chi.URLParam(r, "name"))
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
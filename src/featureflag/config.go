@@ -0,0 +1,124 @@
+package featureflag
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	defaultReloadInterval = 10 * time.Second
+	defaultOverrideTTL    = 15 * time.Minute
+	defaultMaxOverrideTTL = 24 * time.Hour
+	defaultTenantHeader   = "X-Tenant-ID"
+	defaultTenantClaim    = "tenant"
+	defaultRolloutBy      = "claim:sub"
+)
+
+// Config holds the feature flag definitions, found under app.featureFlags.
+type Config struct {
+	// File optionally names a YAML file holding further flag definitions
+	// under a top-level flags key. The file is polled and reloaded live.
+	// Flags in the file take precedence over flags defined inline.
+	File           string        `yaml:"file" mapstructure:"file"`
+	ReloadInterval time.Duration `yaml:"reloadInterval" mapstructure:"reloadInterval"`
+
+	// TenantHeader and TenantClaim name where the tenant of a request is
+	// read from, the claim taking precedence.
+	TenantHeader string `yaml:"tenantHeader" mapstructure:"tenantHeader"`
+	TenantClaim  string `yaml:"tenantClaim" mapstructure:"tenantClaim"`
+
+	// MaxOverrideTTL limits how long an override set on the admin server lasts.
+	MaxOverrideTTL time.Duration `yaml:"maxOverrideTTL" mapstructure:"maxOverrideTTL"`
+
+	Flags map[string]Flag `yaml:"flags" mapstructure:"flags"`
+}
+
+// Flag defines how a single flag is evaluated. Rules are checked first, then
+// the percentage rollout, and finally the flag falls back to Enabled.
+type Flag struct {
+	Enabled bool `yaml:"enabled" mapstructure:"enabled" json:"enabled"`
+
+	// Percentage, when set, enables the flag for a stable share (0-100) of
+	// requests, bucketed on the RolloutBy attribute.
+	Percentage *float64 `yaml:"percentage" mapstructure:"percentage" json:"percentage,omitempty"`
+	RolloutBy  string   `yaml:"rolloutBy" mapstructure:"rolloutBy" json:"rolloutBy,omitempty"`
+
+	Rules []Rule `yaml:"rules" mapstructure:"rules" json:"rules,omitempty"`
+}
+
+// Rule turns a flag on or off for requests whose attribute matches one of Values.
+type Rule struct {
+	// Attribute is one of "claim:<name>" (dots select nested claims),
+	// "header:<name>" or "tenant".
+	Attribute string   `yaml:"attribute" mapstructure:"attribute" json:"attribute"`
+	Values    []string `yaml:"values" mapstructure:"values" json:"values"`
+	// Enabled is the value of the flag when the rule matches, true if unset.
+	Enabled *bool `yaml:"enabled" mapstructure:"enabled" json:"enabled,omitempty"`
+}
+
+func (c *Config) setDefaults() {
+	if c.ReloadInterval == 0 {
+		c.ReloadInterval = defaultReloadInterval
+	}
+	if c.TenantHeader == "" {
+		c.TenantHeader = defaultTenantHeader
+	}
+	if c.TenantClaim == "" {
+		c.TenantClaim = defaultTenantClaim
+	}
+	if c.MaxOverrideTTL == 0 {
+		c.MaxOverrideTTL = defaultMaxOverrideTTL
+	}
+}
+
+func validateFlags(flags map[string]Flag) error {
+	for name, f := range flags {
+		if f.Percentage != nil && (*f.Percentage < 0 || *f.Percentage > 100) {
+			return fmt.Errorf("feature flag %s: percentage must be between 0 and 100", name)
+		}
+		if f.RolloutBy != "" {
+			if err := validateAttribute(f.RolloutBy); err != nil {
+				return fmt.Errorf("feature flag %s: rolloutBy: %w", name, err)
+			}
+		}
+		for i, r := range f.Rules {
+			if err := validateAttribute(r.Attribute); err != nil {
+				return fmt.Errorf("feature flag %s: rule %d: %w", name, i, err)
+			}
+		}
+	}
+	return nil
+}
+
+func validateAttribute(attr string) error {
+	switch {
+	case attr == "tenant":
+	case strings.HasPrefix(attr, "claim:") && len(attr) > len("claim:"):
+	case strings.HasPrefix(attr, "header:") && len(attr) > len("header:"):
+	default:
+		return fmt.Errorf("invalid attribute %q, expected tenant, claim:<name> or header:<name>", attr)
+	}
+	return nil
+}
+
+// readFlagsFile reads the flag definitions held in the given file.
+func readFlagsFile(path string) (map[string]Flag, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Flags map[string]Flag `yaml:"flags"`
+	}
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, fmt.Errorf("feature flag file %s: %w", path, err)
+	}
+	if err := validateFlags(file.Flags); err != nil {
+		return nil, fmt.Errorf("feature flag file %s: %w", path, err)
+	}
+	return file.Flags, nil
+}
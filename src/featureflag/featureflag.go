@@ -0,0 +1,253 @@
+// Package featureflag evaluates feature flags for each request, based on the
+// JWT claims and headers found in the request context.
+package featureflag
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anz-bank/sysl-go/common"
+	"github.com/anz-bank/sysl-go/jwtauth"
+	"github.com/anz-bank/sysl-go/log"
+
+	"github.com/anz-bank/sysl-go-demo/src/claims"
+)
+
+// Service evaluates feature flags. A nil *Service reports every flag as disabled.
+type Service struct {
+	cfg Config
+
+	mu        sync.RWMutex
+	flags     map[string]Flag
+	overrides map[string]override
+	modTime   time.Time
+}
+
+type override struct {
+	enabled bool
+	expires time.Time
+}
+
+// New creates a Service from config, reading the flags file if one is configured.
+func New(cfg Config) (*Service, error) {
+	cfg.setDefaults()
+	if err := validateFlags(cfg.Flags); err != nil {
+		return nil, err
+	}
+	s := &Service{
+		cfg:       cfg,
+		overrides: make(map[string]override),
+	}
+	s.flags = s.merge(nil)
+	if cfg.File != "" {
+		if err := s.reload(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Start polls the flags file for changes until ctx is done. It returns
+// immediately if no flags file is configured.
+func (s *Service) Start(ctx context.Context) {
+	if s == nil || s.cfg.File == "" {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(s.cfg.ReloadInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.reload(); err != nil {
+					log.Error(ctx, err, "feature flags: reload failed, keeping previous flags")
+				}
+			}
+		}
+	}()
+}
+
+// reload re-reads the flags file if it changed since it was last read.
+func (s *Service) reload() error {
+	info, err := os.Stat(s.cfg.File)
+	if err != nil {
+		return err
+	}
+	s.mu.RLock()
+	unchanged := info.ModTime().Equal(s.modTime)
+	s.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+	fileFlags, err := readFlagsFile(s.cfg.File)
+	if err != nil {
+		return err
+	}
+	flags := s.merge(fileFlags)
+	s.mu.Lock()
+	s.flags = flags
+	s.modTime = info.ModTime()
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *Service) merge(fileFlags map[string]Flag) map[string]Flag {
+	flags := make(map[string]Flag, len(s.cfg.Flags)+len(fileFlags))
+	for name, f := range s.cfg.Flags {
+		flags[strings.ToLower(name)] = f
+	}
+	for name, f := range fileFlags {
+		flags[strings.ToLower(name)] = f
+	}
+	return flags
+}
+
+// Enabled reports whether the named flag is on for the request in ctx.
+// Unknown flags are disabled. Flag names are case-insensitive, as config
+// keys are lower-cased when the config file is loaded.
+func (s *Service) Enabled(ctx context.Context, name string) bool {
+	if s == nil {
+		return false
+	}
+	name = strings.ToLower(name)
+	s.mu.RLock()
+	f, known := s.flags[name]
+	o, overridden := s.overrides[name]
+	s.mu.RUnlock()
+
+	if overridden && time.Now().Before(o.expires) {
+		return o.enabled
+	}
+	if !known {
+		return false
+	}
+	return s.evaluate(ctx, name, f)
+}
+
+func (s *Service) evaluate(ctx context.Context, name string, f Flag) bool {
+	for _, r := range f.Rules {
+		if contains(r.Values, s.attribute(ctx, r.Attribute)) {
+			return r.Enabled == nil || *r.Enabled
+		}
+	}
+	if f.Percentage != nil {
+		rolloutBy := f.RolloutBy
+		if rolloutBy == "" {
+			rolloutBy = defaultRolloutBy
+		}
+		key := s.attribute(ctx, rolloutBy)
+		if len(key) == 0 {
+			return f.Enabled
+		}
+		return bucket(name, key[0]) < *f.Percentage
+	}
+	return f.Enabled
+}
+
+// attribute returns the values of attr for the request in ctx.
+func (s *Service) attribute(ctx context.Context, attr string) []string {
+	switch {
+	case attr == "tenant":
+		if values := claimValues(ctx, s.cfg.TenantClaim); len(values) > 0 {
+			return values
+		}
+		return common.RequestHeaderFromContext(ctx).Values(s.cfg.TenantHeader)
+	case strings.HasPrefix(attr, "claim:"):
+		return claimValues(ctx, strings.TrimPrefix(attr, "claim:"))
+	case strings.HasPrefix(attr, "header:"):
+		return common.RequestHeaderFromContext(ctx).Values(strings.TrimPrefix(attr, "header:"))
+	}
+	return nil
+}
+
+// claimValues returns the string forms of the (possibly nested) claim at path.
+func claimValues(ctx context.Context, path string) []string {
+	c, ok := jwtauth.GetClaimsFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	return claims.Values(c, path)
+}
+
+// bucket maps a flag and key onto a stable value in [0, 100).
+func bucket(name, key string) float64 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name + "\x00" + key))
+	return float64(h.Sum32()%10000) / 100
+}
+
+func contains(want, have []string) bool {
+	for _, w := range want {
+		for _, h := range have {
+			if w == h {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Override forces the named flag to enabled for ttl, capped at the configured
+// maximum. It returns the time the override expires.
+func (s *Service) Override(name string, enabled bool, ttl time.Duration) (time.Time, error) {
+	name = strings.ToLower(name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, known := s.flags[name]; !known {
+		return time.Time{}, fmt.Errorf("unknown feature flag: %s", name)
+	}
+	if ttl <= 0 {
+		ttl = defaultOverrideTTL
+	}
+	if ttl > s.cfg.MaxOverrideTTL {
+		ttl = s.cfg.MaxOverrideTTL
+	}
+	expires := time.Now().Add(ttl)
+	s.overrides[name] = override{enabled: enabled, expires: expires}
+	return expires, nil
+}
+
+// ClearOverride removes any override of the named flag.
+func (s *Service) ClearOverride(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.overrides, strings.ToLower(name))
+}
+
+// State describes a flag as reported by the admin server.
+type State struct {
+	Name       string     `json:"name"`
+	Definition Flag       `json:"definition"`
+	Override   *bool      `json:"override,omitempty"`
+	Expires    *time.Time `json:"overrideExpires,omitempty"`
+}
+
+// States returns the definition and any active override of every flag, sorted by name.
+func (s *Service) States() []State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	states := make([]State, 0, len(s.flags))
+	for name, f := range s.flags {
+		state := State{Name: name, Definition: f}
+		if o, ok := s.overrides[name]; ok {
+			if now.Before(o.expires) {
+				enabled, expires := o.enabled, o.expires
+				state.Override, state.Expires = &enabled, &expires
+			} else {
+				delete(s.overrides, name)
+			}
+		}
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+	return states
+}
@@ -0,0 +1,243 @@
+package featureflag
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anz-bank/sysl-go/common"
+	"github.com/anz-bank/sysl-go/jwtauth"
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/require"
+)
+
+func percent(p float64) *float64 {
+	return &p
+}
+
+func boolean(b bool) *bool {
+	return &b
+}
+
+// request returns the context of a request with the given claims, decoded
+// from JSON as the claims of verified tokens are, and headers.
+func request(t *testing.T, claimsJSON string, header http.Header) context.Context {
+	t.Helper()
+	ctx := common.RequestHeaderToContext(context.Background(), header)
+	if claimsJSON == "" {
+		return ctx
+	}
+	var claims jwtauth.Claims
+	require.NoError(t, json.Unmarshal([]byte(claimsJSON), &claims))
+	return jwtauth.AddClaimsToContext(ctx, claims)
+}
+
+// writeFlags writes a flags file and moves its modification time forward, so
+// that the next reload reads it even within the timestamp resolution.
+func writeFlags(t *testing.T, path, flags string) {
+	t.Helper()
+	modTime := time.Now()
+	if info, err := os.Stat(path); err == nil {
+		modTime = info.ModTime().Add(time.Second)
+	}
+	require.NoError(t, os.WriteFile(path, []byte("flags:\n"+flags), 0o600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestEnabled(t *testing.T) {
+	t.Parallel()
+
+	s, err := New(Config{Flags: map[string]Flag{
+		// Rules are checked before the percentage, which is checked before enabled.
+		"ordered": {Enabled: true, Percentage: percent(100.0), Rules: []Rule{
+			{Attribute: "claim:sub", Values: []string{"blocked"}, Enabled: boolean(false)},
+			{Attribute: "header:X-Beta", Values: []string{"yes"}},
+		}},
+		"none":      {Enabled: true, Percentage: percent(0.0)},
+		"all":       {Percentage: percent(100.0)},
+		"fallback":  {Enabled: true, Percentage: percent(50.0), RolloutBy: "header:X-User"},
+		"plain":     {Enabled: true},
+		"CamelCase": {Enabled: true},
+		"uid":       {Rules: []Rule{{Attribute: "claim:uid", Values: []string{"1234567"}}}},
+		"nested":    {Rules: []Rule{{Attribute: "claim:org.unit", Values: []string{"vets"}}}},
+		"tenant":    {Rules: []Rule{{Attribute: "tenant", Values: []string{"acme"}}}},
+	}})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		flag   string
+		claims string
+		header http.Header
+		want   bool
+	}{
+		{"rule disables", "ordered", `{"sub": "blocked"}`, http.Header{"X-Beta": {"yes"}}, false},
+		{"rule enables by default", "ordered", `{"sub": "jo"}`, http.Header{"X-Beta": {"yes"}}, true},
+		{"percentage before enabled", "none", `{"sub": "jo"}`, nil, false},
+		{"percentage 100", "all", `{"sub": "jo"}`, nil, true},
+		{"no rollout key", "fallback", `{"sub": "jo"}`, nil, true},
+		{"enabled", "plain", "", nil, true},
+		{"unknown", "missing", "", nil, false},
+		{"case-insensitive", "camelcase", "", nil, true},
+		{"numeric claim", "uid", `{"uid": 1234567}`, nil, true},
+		{"nested claim", "nested", `{"org": {"unit": "vets"}}`, nil, true},
+		{"tenant claim", "tenant", `{"tenant": "acme"}`, http.Header{"X-Tenant-Id": {"evil"}}, true},
+		{"tenant claim before header", "tenant", `{"tenant": "evil"}`, http.Header{"X-Tenant-Id": {"acme"}}, false},
+		{"tenant header", "tenant", `{"sub": "jo"}`, http.Header{"X-Tenant-Id": {"acme"}}, true},
+		{"no tenant", "tenant", `{"sub": "jo"}`, nil, false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, s.Enabled(request(t, tt.claims, tt.header), tt.flag))
+		})
+	}
+
+	var disabled *Service
+	require.False(t, disabled.Enabled(context.Background(), "plain"))
+}
+
+func TestBucket(t *testing.T) {
+	t.Parallel()
+
+	s, err := New(Config{Flags: map[string]Flag{"rollout": {Percentage: percent(30.0)}}})
+	require.NoError(t, err)
+	enabled := 0
+	for i := 0; i < 2000; i++ {
+		key := fmt.Sprintf("user-%d", i)
+		b := bucket("rollout", key)
+		require.GreaterOrEqual(t, b, 0.0)
+		require.Less(t, b, 100.0)
+		require.Equal(t, b, bucket("rollout", key), "stable")
+		ctx := request(t, fmt.Sprintf(`{"sub": %q}`, key), nil)
+		if s.Enabled(ctx, "rollout") {
+			enabled++
+			require.True(t, s.Enabled(ctx, "rollout"), "stable")
+		}
+	}
+	require.InDelta(t, 600, enabled, 100)
+	require.NotEqual(t, bucket("a", "user-1"), bucket("b", "user-1"), "buckets differ between flags")
+}
+
+func TestFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "flags.yaml")
+	writeFlags(t, path, "  shared: {enabled: true}\n  fileonly: {enabled: true}\n")
+	s, err := New(Config{File: path, Flags: map[string]Flag{
+		"shared": {Enabled: false},
+		"inline": {Enabled: true},
+	}})
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.True(t, s.Enabled(ctx, "shared"), "file flags take precedence")
+	require.True(t, s.Enabled(ctx, "inline"))
+	require.True(t, s.Enabled(ctx, "fileonly"))
+
+	writeFlags(t, path, "  shared: {enabled: false}\n")
+	require.NoError(t, s.reload())
+	require.False(t, s.Enabled(ctx, "shared"))
+	require.False(t, s.Enabled(ctx, "fileonly"))
+
+	// Invalid files keep the previous flags.
+	for _, flags := range []string{
+		"  shared: {enabled: true, percentage: 101}\n",
+		"  shared: {enabled: true, rules: [{attribute: user, values: [a]}]}\n",
+		"  shared: {enabled: true, unknown: 1}\n",
+	} {
+		writeFlags(t, path, flags)
+		require.Error(t, s.reload(), flags)
+		require.False(t, s.Enabled(ctx, "shared"), flags)
+		require.True(t, s.Enabled(ctx, "inline"), flags)
+	}
+	require.NoError(t, os.Remove(path))
+	require.Error(t, s.reload())
+	require.True(t, s.Enabled(ctx, "inline"))
+
+	_, err = New(Config{File: path})
+	require.Error(t, err)
+	_, err = New(Config{Flags: map[string]Flag{"bad": {RolloutBy: "sub"}}})
+	require.ErrorContains(t, err, "feature flag bad: rolloutBy: invalid attribute")
+}
+
+func TestOverride(t *testing.T) {
+	t.Parallel()
+
+	s, err := New(Config{MaxOverrideTTL: time.Hour, Flags: map[string]Flag{"a": {}, "b": {}, "c": {Enabled: true}}})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	start := time.Now()
+	expires, err := s.Override("A", true, 48*time.Hour)
+	require.NoError(t, err)
+	require.WithinDuration(t, start.Add(time.Hour), expires, time.Second, "capped")
+	require.True(t, s.Enabled(ctx, "a"))
+
+	expires, err = s.Override("b", true, 0)
+	require.NoError(t, err)
+	require.WithinDuration(t, start.Add(defaultOverrideTTL), expires, time.Second, "default ttl")
+
+	_, err = s.Override("missing", true, time.Minute)
+	require.ErrorContains(t, err, "unknown feature flag: missing")
+
+	// Expired overrides no longer apply and are dropped from the states.
+	s.mu.Lock()
+	s.overrides["c"] = override{enabled: false, expires: time.Now().Add(-time.Second)}
+	s.mu.Unlock()
+	require.True(t, s.Enabled(ctx, "c"))
+	states := s.States()
+	require.Equal(t, []string{"a", "b", "c"}, []string{states[0].Name, states[1].Name, states[2].Name})
+	require.True(t, *states[0].Override)
+	require.Nil(t, states[2].Override)
+	require.NotContains(t, s.overrides, "c")
+
+	s.ClearOverride("A")
+	require.False(t, s.Enabled(ctx, "a"))
+}
+
+func TestAdminRoutes(t *testing.T) {
+	t.Parallel()
+
+	s, err := New(Config{Flags: map[string]Flag{"beta": {}}})
+	require.NoError(t, err)
+	r := chi.NewRouter()
+	s.WireAdminRoutes(r)
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		code   int
+		want   string
+	}{
+		{"override", http.MethodPut, "/beta", `{"enabled": true, "ttl": "1m"}`, http.StatusOK, `"name":"beta","enabled":true`},
+		{"invalid body", http.MethodPut, "/beta", `{"enabled": "yes"}`, http.StatusBadRequest, "invalid override request"},
+		{"invalid ttl", http.MethodPut, "/beta", `{"enabled": true, "ttl": "soon"}`, http.StatusBadRequest, "invalid ttl"},
+		{"unknown flag", http.MethodPut, "/gamma", `{"enabled": true}`, http.StatusNotFound, "unknown feature flag: gamma"},
+		{"list", http.MethodGet, "/", "", http.StatusOK, `"name":"beta"`},
+	}
+	for _, tt := range tests {
+		w := send(tt.method, tt.path, tt.body)
+		require.Equal(t, tt.code, w.Code, tt.name)
+		require.Contains(t, w.Body.String(), tt.want, tt.name)
+	}
+	require.True(t, s.Enabled(context.Background(), "beta"))
+
+	require.Equal(t, http.StatusNoContent, send(http.MethodDelete, "/beta", "").Code)
+	require.False(t, s.Enabled(context.Background(), "beta"))
+	require.Equal(t, http.StatusNoContent, send(http.MethodDelete, "/gamma", "").Code)
+}
@@ -0,0 +1,184 @@
+// Package golden compares bodies in e2e tests with golden files, for
+// responses of the service under test:
+//
+//	srv.GetPetList().ExpectResponseCode(200).TestResponseBody(golden.ResponseBody("getpet")).Send()
+//
+// and for requests received by mocked downstreams:
+//
+//	srv.Mocks.Petstore.GetPetList.Expect(golden.RequestBody("petstore-getpet")).MockResponse(200, nil, pet)
+//
+// ResponseBody also tests calls made directly with the e2e.Tester:
+//
+//	tester.Do2(e2e.TestCall2{Method: "GET", URL: "/pet", TestBodyFn: golden.ResponseBody("getpet")})
+//
+// These are test functions rather than ExpectResponseBodyGolden methods, as
+// the endpoint testers are generated, and e2e.ResponseTest functions run
+// after the response body has been read and closed.
+//
+// Golden files are stored in testdata/<name>.golden of the package under
+// test. Its tests register the -update flag with RegisterUpdateFlag, and are
+// run with -update to write the golden files from the actual bodies.
+// JSON bodies are stored indented with sorted keys, so that changes to the
+// models give readable diffs.
+package golden
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/anz-bank/sysl-go/syslgo"
+	"github.com/anz-bank/sysl-go/testutil/e2e"
+	"github.com/stretchr/testify/assert"
+)
+
+// Dir is the directory of the golden files, relative to the package under test.
+const Dir = "testdata"
+
+// Ignored replaces the values of ignored JSON paths.
+const Ignored = "<ignored>"
+
+// update is set by the -update flag, see RegisterUpdateFlag.
+var update bool
+
+// RegisterUpdateFlag registers the -update flag on the command line of the
+// test binary. Call it from an init function of a _test.go file of the
+// package under test, so that non-test binaries and packages that do not use
+// golden files do not get the flag.
+func RegisterUpdateFlag() {
+	flag.BoolVar(&update, "update", false, "rewrite golden files with the actual bodies")
+}
+
+// Option configures a comparison.
+type Option func(*options)
+
+type options struct {
+	ignore [][]string
+}
+
+// Ignore ignores the values at the JSON pointers paths, for volatile fields
+// such as timestamps and ids. A * token matches every key of an object or
+// item of an array, e.g. /pets/*/id.
+func Ignore(paths ...string) Option {
+	return func(o *options) {
+		for _, path := range paths {
+			o.ignore = append(o.ignore, parsePointer(path))
+		}
+	}
+}
+
+// ResponseBody returns a test of the response body of a generated endpoint
+// tester against the golden file name.
+func ResponseBody(name string, opts ...Option) func(t syslgo.TestingT, actual []byte) {
+	return func(t syslgo.TestingT, actual []byte) {
+		Assert(t, name, actual, opts...)
+	}
+}
+
+// RequestBody returns a test of the request body received by a mocked
+// downstream against the golden file name. The body is left readable by the
+// following tests.
+func RequestBody(name string, opts ...Option) e2e.Tests {
+	return func(t syslgo.TestingT, _ http.ResponseWriter, r *http.Request) {
+		var body []byte
+		if r.Body != nil {
+			var err error
+			if body, err = io.ReadAll(r.Body); err != nil {
+				t.Errorf("golden: %s: reading request body: %v", name, err)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		Assert(t, name, body, opts...)
+	}
+}
+
+// Assert compares actual with the golden file name, or writes it with -update.
+func Assert(t syslgo.TestingT, name string, actual []byte, opts ...Option) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	path := filepath.Join(Dir, name+".golden")
+	actual = normalize(actual, o.ignore)
+
+	if update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Errorf("golden: %v", err)
+			return
+		}
+		if err := os.WriteFile(path, actual, 0o600); err != nil {
+			t.Errorf("golden: %v", err)
+		}
+		return
+	}
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Errorf("golden: %v (run the tests with -update to create it)", err)
+		return
+	}
+	assert.Equal(t, string(normalize(expected, o.ignore)), string(actual), "golden file %s (run the tests with -update to rewrite it)", path)
+}
+
+// normalize indents JSON bodies with sorted keys and ignored values
+// replaced. Other bodies are returned unchanged.
+func normalize(body []byte, ignore [][]string) []byte {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil || decoder.More() {
+		return body
+	}
+	for _, path := range ignore {
+		value = replace(value, path)
+	}
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return body
+	}
+	return out.Bytes()
+}
+
+// replace replaces the values at path in value with Ignored.
+func replace(value interface{}, path []string) interface{} {
+	if len(path) == 0 {
+		return Ignored
+	}
+	token, rest := path[0], path[1:]
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if token == "*" || token == key {
+				v[key] = replace(item, rest)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			if token == "*" || token == strconv.Itoa(i) {
+				v[i] = replace(item, rest)
+			}
+		}
+	}
+	return value
+}
+
+// parsePointer splits a JSON pointer into its unescaped tokens.
+func parsePointer(pointer string) []string {
+	if pointer == "" {
+		return nil
+	}
+	tokens := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens
+}
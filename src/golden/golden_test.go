@@ -0,0 +1,117 @@
+package golden
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/anz-bank/sysl-go-demo/src/testhooks"
+)
+
+const pet = `{"tags": [{"name": "good/boy", "id": 7}], "createdAt": "2026-10-19T00:00:00Z", "breed": "husky"}`
+
+func TestAssert(t *testing.T) {
+	t.Parallel()
+
+	ignore := Ignore("/createdAt", "/tags/*/id")
+	tests := []struct {
+		name   string
+		golden string
+		body   string
+		opts   []Option
+		want   string // failure, "" if the body matches
+	}{
+		{"matches", "pet", pet, []Option{ignore}, ""},
+		{"key order and spacing", "pet", `{"breed":"husky","createdAt":1,"tags":[{"id":8,"name":"good/boy"}]}`, []Option{ignore}, ""},
+		{"array index", "pet", pet, []Option{Ignore("/createdAt", "/tags/0/id")}, ""},
+		{"not ignored", "pet", pet, []Option{Ignore("/createdAt")}, `"id": 7`},
+		{"different value", "pet", strings.Replace(pet, "husky", "poodle", 1), []Option{ignore}, "golden file testdata/pet.golden"},
+		{"text", "plain", "plain text\n", nil, ""},
+		{"different text", "plain", "plain text", nil, "run the tests with -update to rewrite it"},
+		{"missing", "missing", pet, nil, "run the tests with -update to create it"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			f := &testhooks.Failures{}
+			ResponseBody(tt.golden, tt.opts...)(f, []byte(tt.body))
+			if tt.want == "" {
+				require.Empty(t, f.String())
+			} else {
+				require.Contains(t, f.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestBody(t *testing.T) {
+	t.Parallel()
+
+	f := &testhooks.Failures{}
+	r := httptest.NewRequest(http.MethodPost, "/pet", strings.NewReader(pet))
+	RequestBody("pet", Ignore("/createdAt", "/tags/*/id"))(f, httptest.NewRecorder(), r)
+	require.Empty(t, f.String())
+	// The body is still readable by the following tests.
+	body, err := io.ReadAll(r.Body)
+	require.NoError(t, err)
+	require.Equal(t, pet, string(body))
+}
+
+func TestNormalize(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		body   string
+		ignore []string
+		want   string
+	}{
+		{"sorted and indented", `{"b":1,"a":[true,null]}`, nil, "{\n  \"a\": [\n    true,\n    null\n  ],\n  \"b\": 1\n}\n"},
+		{"numbers kept", `{"n":12345678901234567890,"f":1.50}`, nil, "{\n  \"f\": 1.50,\n  \"n\": 12345678901234567890\n}\n"},
+		{"html not escaped", `{"a":"<b>"}`, nil, "{\n  \"a\": \"<b>\"\n}\n"},
+		{"ignored", `{"a":{"b/c":1,"d~e":2}}`, []string{"/a/b~1c", "/a/d~0e"}, "{\n  \"a\": {\n    \"b/c\": \"<ignored>\",\n    \"d~e\": \"<ignored>\"\n  }\n}\n"},
+		{"whole body ignored", `{"a":1}`, []string{""}, "\"<ignored>\"\n"},
+		{"missing path", `{"a":1}`, []string{"/b/c"}, "{\n  \"a\": 1\n}\n"},
+		{"not JSON", `a: 1`, nil, `a: 1`},
+		{"several values", `{"a":1} {"a":2}`, nil, `{"a":1} {"a":2}`},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var o options
+			Ignore(tt.ignore...)(&o)
+			require.Equal(t, tt.want, string(normalize([]byte(tt.body), o.ignore)))
+		})
+	}
+}
+
+// TestUpdate is not parallel, as it changes the working directory and the
+// update flag. Parallel tests only start once it is done.
+func TestUpdate(t *testing.T) {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	dir := t.TempDir()
+	require.NoError(t, os.Chdir(dir))
+	update = true
+	t.Cleanup(func() {
+		update = false
+		require.NoError(t, os.Chdir(wd))
+	})
+
+	f := &testhooks.Failures{}
+	Assert(f, "nested/pet", []byte(pet), Ignore("/createdAt", "/tags/*/id"))
+	require.Empty(t, f.String())
+	written, err := os.ReadFile(filepath.Join(dir, Dir, "nested", "pet.golden"))
+	require.NoError(t, err)
+	expected, err := os.ReadFile(filepath.Join(wd, Dir, "pet.golden"))
+	require.NoError(t, err)
+	require.Equal(t, string(expected), string(written))
+}
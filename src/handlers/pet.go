@@ -3,28 +3,43 @@ package handlers
 import (
 	"context"
 	"net/http"
+	"strings"
 
 	petdemo "github.com/anz-bank/sysl-go-demo/internal/gen/pkg/servers/Petdemo"
 	"github.com/anz-bank/sysl-go-demo/internal/gen/pkg/servers/Petdemo/petstore"
+	"github.com/anz-bank/sysl-go-demo/src/featureflag"
 	"github.com/anz-bank/sysl-go/common"
 )
 
+// FlagLowercaseBreed makes GetRandomPetPicListRead return breeds in lower case.
+const FlagLowercaseBreed = "lowercaseBreed"
+
+// Pet serves the Petdemo pet endpoints
+type Pet struct {
+	Flags *featureflag.Service
+}
+
 // GetRandomPetPicListRead reads random pic from downstream
-func GetRandomPetPicListRead(ctx context.Context,
+func (p *Pet) GetRandomPetPicListRead(ctx context.Context,
 	getRandomPetPicListRequest *petdemo.GetPetListRequest,
 	client petdemo.GetPetListClient) (*petdemo.Pet, error) {
 
 	// Retrieve the pets (using a fresh set of headers for the request)
 	// This is required because by default Sysl-go reuses inbound headers for downstream requests
-	ctx = common.RequestHeaderToContext(ctx, http.Header{})
+	downstreamCtx := common.RequestHeaderToContext(ctx, http.Header{})
 	reqPetstore := petstore.GetPetListRequest{}
-	pet, err := client.PetstoreGetPetList(ctx, &reqPetstore)
+	pet, err := client.PetstoreGetPetList(downstreamCtx, &reqPetstore)
 	if err != nil {
 		return nil, err
 	}
 
+	breed := string(*pet)
+	if p.Flags.Enabled(ctx, FlagLowercaseBreed) {
+		breed = strings.ToLower(breed)
+	}
+
 	// Return the result
 	return &petdemo.Pet{
-		Breed: string(*pet),
+		Breed: breed,
 	}, nil
 }
@@ -0,0 +1,59 @@
+package leakcheck
+
+import (
+	"context"
+	"sync"
+)
+
+// trackedContext is the context of a request checked for leaks. Its Done
+// channel is its own rather than that of the context it wraps, so Go cannot
+// link the contexts derived from it to their parent directly. It starts a
+// goroutine for each of them instead, which runs until the derived context
+// is cancelled and so reveals contexts that are never cancelled.
+type trackedContext struct {
+	context.Context
+	tracker *tracker
+
+	done chan struct{}
+	once sync.Once
+	mu   sync.Mutex
+	err  error
+}
+
+func newTrackedContext(parent context.Context) *trackedContext {
+	c := &trackedContext{Context: parent, tracker: &tracker{}, done: make(chan struct{})}
+	go func() {
+		select {
+		case <-parent.Done():
+			c.cancel(parent.Err())
+		case <-c.done:
+		}
+	}()
+	return c
+}
+
+func (c *trackedContext) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *trackedContext) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *trackedContext) Value(key interface{}) interface{} {
+	if key == (trackerKey{}) {
+		return c.tracker
+	}
+	return c.Context.Value(key)
+}
+
+func (c *trackedContext) cancel(err error) {
+	c.once.Do(func() {
+		c.mu.Lock()
+		c.err = err
+		c.mu.Unlock()
+		close(c.done)
+	})
+}
@@ -0,0 +1,80 @@
+package leakcheck
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// goroutine is a goroutine parsed from a runtime.Stack dump.
+type goroutine struct {
+	id int64
+	// creator is the id of the goroutine that created it, 0 if unknown.
+	creator int64
+	// stack is the dump of the goroutine, including the statement that
+	// created it and, with GODEBUG=tracebackancestors, its ancestors.
+	stack string
+}
+
+// dumpGoroutines returns every running goroutine.
+func dumpGoroutines() []goroutine {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	var result []goroutine
+	for _, block := range bytes.Split(buf, []byte("\n\n")) {
+		if g, ok := parseGoroutine(string(block)); ok {
+			result = append(result, g)
+		}
+	}
+	return result
+}
+
+// parseGoroutine parses a goroutine of a dump, which looks like
+//
+//	goroutine 42 [chan receive]:
+//	main.worker(...)
+//		/src/main.go:12 +0x1d
+//	created by main.start in goroutine 7
+//		/src/main.go:8 +0x25
+func parseGoroutine(block string) (goroutine, bool) {
+	var g goroutine
+	header, _, _ := strings.Cut(block, "\n")
+	if !strings.HasPrefix(header, "goroutine ") {
+		return g, false
+	}
+	id, err := strconv.ParseInt(strings.Fields(header)[1], 10, 64)
+	if err != nil {
+		return g, false
+	}
+	g.id, g.stack = id, strings.TrimSpace(block)
+	if i := strings.Index(block, "\ncreated by "); i >= 0 {
+		line, _, _ := strings.Cut(block[i+1:], "\n")
+		if j := strings.LastIndex(line, " in goroutine "); j >= 0 {
+			g.creator, _ = strconv.ParseInt(line[j+len(" in goroutine "):], 10, 64)
+		}
+	}
+	return g, true
+}
+
+func goroutineIDs(goroutines []goroutine) map[int64]bool {
+	ids := make(map[int64]bool, len(goroutines))
+	for _, g := range goroutines {
+		ids[g.id] = true
+	}
+	return ids
+}
+
+// currentGoroutineID returns the id of the calling goroutine.
+func currentGoroutineID() int64 {
+	buf := make([]byte, 64)
+	g, _ := parseGoroutine(string(buf[:runtime.Stack(buf, false)]))
+	return g.id
+}
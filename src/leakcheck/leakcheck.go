@@ -0,0 +1,258 @@
+// Package leakcheck fails tests whose requests leak resources after the
+// handler has returned:
+//
+//   - downstream response bodies that were never closed, reported with the
+//     stack of the request that opened them;
+//   - goroutines started while handling the request that are still running,
+//     reported with their stack and the statement that created them;
+//   - contexts derived from the request context that were never cancelled.
+//
+// It is enabled by wrapping the createService function of a test server:
+//
+//	srv := petdemo.NewTestServer(t, ctx, leakcheck.Wrap(t, createService), cfg)
+//
+// Go records only the statement that created a goroutine. Run the tests
+// with GODEBUG=tracebackancestors=10 to report the full stacks at which
+// leaked goroutines and contexts were created.
+//
+// Requests are expected to be sent one at a time, as the generated endpoint
+// testers do: goroutines are attributed to the request whose handler
+// created them, directly or through other goroutines that it created.
+package leakcheck
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anz-bank/sysl-go/core"
+	"github.com/anz-bank/sysl-go/syslgo"
+	"github.com/go-chi/chi"
+
+	"github.com/anz-bank/sysl-go-demo/src/testhooks"
+)
+
+// Option configures the leak detection.
+type Option func(*options)
+
+type options struct {
+	grace  time.Duration
+	ignore []string
+}
+
+// Grace sets how long leaked resources are given to be released after the
+// handler has returned, e.g. for goroutines that are already finishing.
+// Default 500ms.
+func Grace(d time.Duration) Option {
+	return func(o *options) {
+		o.grace = d
+	}
+}
+
+// IgnoreGoroutines ignores goroutines whose stack contains any of
+// substrings, e.g. a function name, for goroutines that outlive requests by
+// design such as caches refreshed in the background.
+func IgnoreGoroutines(substrings ...string) Option {
+	return func(o *options) {
+		o.ignore = append(o.ignore, substrings...)
+	}
+}
+
+// defaultIgnored are goroutines of net/http that outlive requests by design,
+// e.g. to keep downstream connections alive.
+var defaultIgnored = []string{
+	"net/http.(*persistConn).",
+	"net/http.(*connReader).backgroundRead",
+	"net/http.(*Transport).dialConnFor",
+	"net/http.setRequestCancel",
+	"net/http.(*http2",
+}
+
+// Wrap returns createService with every request to the service checked for
+// leaks, which fail t. createService must have the signature expected by
+// the generated NewTestServer and NewIntegrationTestServer functions.
+func Wrap(t syslgo.TestingT, createService interface{}, opts ...Option) interface{} {
+	d := &detector{t: t, options: options{grace: 500 * time.Millisecond, ignore: defaultIgnored}}
+	for _, opt := range opts {
+		opt(&d.options)
+	}
+	createService = testhooks.WrapDownstreams(createService, d.wrapDownstream)
+	return testhooks.Patch(createService, func(hooks *core.Hooks) {
+		next := hooks.AddHTTPMiddleware
+		hooks.AddHTTPMiddleware = func(ctx context.Context, r chi.Router) {
+			r.Use(d.middleware)
+			if next != nil {
+				next(ctx, r)
+			}
+		}
+	})
+}
+
+type detector struct {
+	t syslgo.TestingT
+	options
+}
+
+// middleware checks each request for leaks once the handler has returned.
+func (d *detector) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := newTrackedContext(r.Context())
+		before := goroutineIDs(dumpGoroutines())
+		handler := currentGoroutineID()
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+
+		var leaks []string
+		for deadline := time.Now().Add(d.grace); ; time.Sleep(10 * time.Millisecond) {
+			leaks = append(ctx.tracker.openBodies(), d.leakedGoroutines(before, handler)...)
+			if len(leaks) == 0 || time.Now().After(deadline) {
+				break
+			}
+		}
+		ctx.cancel(context.Canceled)
+		if len(leaks) == 0 {
+			return
+		}
+		if !strings.Contains(os.Getenv("GODEBUG"), "tracebackancestors=") {
+			leaks = append(leaks, "run with GODEBUG=tracebackancestors=10 to see where goroutines and contexts were created")
+		}
+		d.t.Errorf("leakcheck: %s %s leaked after the handler returned:\n\n%s", r.Method, r.URL.Path, strings.Join(leaks, "\n\n"))
+	})
+}
+
+// leakedGoroutines reports the goroutines running now that were not running
+// before and descend from the handler goroutine. The goroutines that Go
+// starts to propagate the cancellation of a tracked context to contexts
+// derived from it are reported as contexts that were never cancelled.
+func (d *detector) leakedGoroutines(before map[int64]bool, handler int64) []string {
+	all := dumpGoroutines()
+	running := goroutineIDs(all)
+	descends := map[int64]bool{handler: true}
+	var candidates []goroutine
+	for _, g := range all {
+		if !before[g.id] && g.id != handler && !d.ignored(g) {
+			candidates = append(candidates, g)
+		}
+	}
+	// Goroutines may be created by other new goroutines, so iterate until no
+	// more descendants are found.
+	for found := true; found; {
+		found = false
+		for _, g := range candidates {
+			if descends[g.id] {
+				continue
+			}
+			exited := g.creator != 0 && !before[g.creator] && !running[g.creator]
+			if descends[g.creator] || exited {
+				descends[g.id], found = true, true
+			}
+		}
+	}
+
+	var leaks []string
+	for _, g := range candidates {
+		if !descends[g.id] {
+			continue
+		}
+		if strings.Contains(g.stack, "context.(*cancelCtx).propagateCancel") {
+			leaks = append(leaks, fmt.Sprintf("context never cancelled, created by goroutine %d:\n%s", g.creator, g.stack))
+		} else {
+			leaks = append(leaks, "goroutine still running:\n"+g.stack)
+		}
+	}
+	return leaks
+}
+
+func (d *detector) ignored(g goroutine) bool {
+	for _, s := range d.ignore {
+		if strings.Contains(g.stack, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// wrapDownstream tracks the response bodies of downstream requests sent
+// while handling a request.
+func (d *detector) wrapDownstream(_, _ string, rt http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := rt.RoundTrip(req)
+		if tracker, ok := req.Context().Value(trackerKey{}).(*tracker); ok && err == nil && resp.Body != nil {
+			resp.Body = tracker.add(req, resp.Body)
+		}
+		return resp, err
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+type trackerKey struct{}
+
+// tracker records the downstream response bodies opened by a request.
+type tracker struct {
+	mu     sync.Mutex
+	bodies []*trackedBody
+}
+
+type trackedBody struct {
+	io.ReadCloser
+	request string
+	stack   string
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func (b *trackedBody) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+	return b.ReadCloser.Close()
+}
+
+func (t *tracker) add(req *http.Request, body io.ReadCloser) io.ReadCloser {
+	b := &trackedBody{ReadCloser: body, request: req.Method + " " + req.URL.String(), stack: callers(3)}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.bodies = append(t.bodies, b)
+	return b
+}
+
+func (t *tracker) openBodies() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var leaks []string
+	for _, b := range t.bodies {
+		b.mu.Lock()
+		if !b.closed {
+			leaks = append(leaks, fmt.Sprintf("response body of %s not closed, opened at:\n%s", b.request, b.stack))
+		}
+		b.mu.Unlock()
+	}
+	return leaks
+}
+
+// callers formats the stack of the caller, skipping skip frames.
+func callers(skip int) string {
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(skip, pcs)])
+	var b strings.Builder
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&b, "%s(...)\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,135 @@
+package leakcheck
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/anz-bank/sysl-go-demo/src/testhooks"
+)
+
+// serve sends a request to handler wrapped by the middleware of a detector
+// and returns the leaks that it reported.
+func serve(t *testing.T, handler http.HandlerFunc, opts ...Option) string {
+	t.Helper()
+	f := &testhooks.Failures{}
+	d := &detector{t: f, options: options{grace: 50 * time.Millisecond, ignore: defaultIgnored}}
+	for _, opt := range opts {
+		opt(&d.options)
+	}
+	d.middleware(handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/pet", nil))
+	return f.String()
+}
+
+// The middleware tests are not run in parallel, as goroutines whose creator
+// has exited may be attributed to the request of another test.
+func TestMiddleware(t *testing.T) { //nolint:paralleltest // see above
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"ok":true}`)
+	}))
+	defer downstream.Close()
+	d := &detector{}
+	rt := d.wrapDownstream("petstore", downstream.URL, http.DefaultTransport)
+	get := func(r *http.Request) *http.Response {
+		req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, downstream.URL, nil)
+		require.NoError(t, err)
+		resp, err := rt.RoundTrip(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	release := make(chan struct{})
+	defer close(release)
+	block := func() { <-release }
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		opts    []Option
+		want    string // leak, "" if none
+	}{
+		{"none", func(w http.ResponseWriter, r *http.Request) {
+			resp := get(r)
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			ctx, cancel := context.WithCancel(r.Context())
+			defer cancel()
+			go func() { <-ctx.Done() }()
+		}, nil, ""},
+		{"body", func(w http.ResponseWriter, r *http.Request) {
+			get(r) //nolint:bodyclose // leaked on purpose
+		}, nil, "response body of GET " + downstream.URL + " not closed"},
+		{"goroutine", func(w http.ResponseWriter, r *http.Request) {
+			go block()
+		}, nil, "goroutine still running"},
+		{"nested goroutine", func(w http.ResponseWriter, r *http.Request) {
+			done := make(chan struct{})
+			go func() {
+				go block()
+				close(done)
+			}()
+			<-done
+		}, nil, "goroutine still running"},
+		{"ignored goroutine", func(w http.ResponseWriter, r *http.Request) {
+			go block()
+		}, []Option{IgnoreGoroutines("leakcheck.TestMiddleware")}, ""},
+		{"finished within grace", func(w http.ResponseWriter, r *http.Request) {
+			go time.Sleep(10 * time.Millisecond)
+		}, []Option{Grace(time.Second)}, ""},
+		{"context", func(w http.ResponseWriter, r *http.Request) {
+			_, cancel := context.WithCancel(r.Context())
+			_ = cancel
+		}, nil, "context never cancelled"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			leaks := serve(t, tt.handler, tt.opts...)
+			if tt.want == "" {
+				require.Empty(t, leaks)
+				return
+			}
+			require.Contains(t, leaks, "leakcheck: GET /pet leaked after the handler returned")
+			require.Contains(t, leaks, tt.want)
+		})
+	}
+}
+
+func TestParseGoroutine(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		block string
+		want  goroutine
+		ok    bool
+	}{
+		{"created", "goroutine 42 [chan receive]:\nmain.worker(...)\n\t/src/main.go:12 +0x1d\ncreated by main.start in goroutine 7\n\t/src/main.go:8 +0x25",
+			goroutine{id: 42, creator: 7}, true},
+		{"main", "goroutine 1 [running]:\nmain.main()\n\t/src/main.go:3 +0x1d", goroutine{id: 1}, true},
+		{"not a goroutine", "main.main()\n\t/src/main.go:3 +0x1d", goroutine{}, false},
+		{"invalid id", "goroutine x [running]:", goroutine{}, false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			g, ok := parseGoroutine(tt.block)
+			require.Equal(t, tt.ok, ok)
+			require.Equal(t, tt.want.id, g.id)
+			require.Equal(t, tt.want.creator, g.creator)
+		})
+	}
+}
+
+func TestCurrentGoroutineID(t *testing.T) {
+	t.Parallel()
+
+	id := currentGoroutineID()
+	require.NotZero(t, id)
+	require.True(t, goroutineIDs(dumpGoroutines())[id])
+}
@@ -0,0 +1,90 @@
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/anz-bank/sysl-go-demo/src/metrics"
+)
+
+// ScrapeDownstream counts downstream requests from the metrics served on
+// the admin server at adminURL (see metrics.Downstream).
+func ScrapeDownstream(adminURL string) DownstreamCounter {
+	metricsURL := strings.TrimSuffix(adminURL, "/") + "/-/metrics"
+	return func(ctx context.Context) (map[string]int64, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, metricsURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", string(expfmt.FmtText))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("GET %s: %s", metricsURL, resp.Status)
+		}
+		var parser expfmt.TextParser
+		families, err := parser.TextToMetricFamilies(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("GET %s: %w", metricsURL, err)
+		}
+		counts := map[string]int64{}
+		family, ok := families[metrics.DownstreamRequestsName]
+		if !ok {
+			return counts, nil
+		}
+		for _, m := range family.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if label.GetName() == "service" {
+					counts[label.GetValue()] += int64(m.GetCounter().GetValue())
+				}
+			}
+		}
+		return counts, nil
+	}
+}
+
+// Counter counts downstream requests in process, for services started by a
+// generated NewIntegrationTestServer.
+type Counter struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+// Wrap counts the requests sent through rt. It has the signature of the
+// wrap function of testhooks.WrapDownstreams.
+func (c *Counter) Wrap(serviceName, _ string, rt http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		c.mu.Lock()
+		if c.counts == nil {
+			c.counts = map[string]int64{}
+		}
+		c.counts[serviceName]++
+		c.mu.Unlock()
+		return rt.RoundTrip(req)
+	})
+}
+
+// Count implements DownstreamCounter.
+func (c *Counter) Count(context.Context) (map[string]int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := make(map[string]int64, len(c.counts))
+	for k, v := range c.counts {
+		counts[k] = v
+	}
+	return counts, nil
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,86 @@
+package loadtest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const metricsText = `# HELP downstream_requests_total Requests sent to downstreams, by service and status code.
+# TYPE downstream_requests_total counter
+downstream_requests_total{code="200",service="petstore"} 3
+downstream_requests_total{code="error",service="petstore"} 1
+downstream_requests_total{code="503",service="other"} 2
+# HELP go_goroutines Number of goroutines that currently exist.
+# TYPE go_goroutines gauge
+go_goroutines 12
+`
+
+func TestScrapeDownstream(t *testing.T) {
+	t.Parallel()
+
+	responses := map[string]string{
+		"/ok/-/metrics":      metricsText,
+		"/none/-/metrics":    "# TYPE go_goroutines gauge\ngo_goroutines 12\n",
+		"/invalid/-/metrics": "downstream_requests_total{service=\n",
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	counts, err := ScrapeDownstream(srv.URL + "/ok/")(ctx)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{"petstore": 4, "other": 2}, counts)
+
+	counts, err = ScrapeDownstream(srv.URL + "/none")(ctx)
+	require.NoError(t, err)
+	require.Empty(t, counts)
+
+	_, err = ScrapeDownstream(srv.URL + "/invalid")(ctx)
+	require.ErrorContains(t, err, "GET "+srv.URL+"/invalid/-/metrics: ")
+	_, err = ScrapeDownstream(srv.URL + "/missing")(ctx)
+	require.ErrorContains(t, err, "404 Not Found")
+}
+
+func TestCounter(t *testing.T) {
+	t.Parallel()
+
+	var c Counter
+	counts, err := c.Count(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, counts)
+
+	var sent []string
+	next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		sent = append(sent, req.URL.Path)
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+	petstore := c.Wrap("petstore", "http://petstore", next)
+	other := c.Wrap("other", "http://other", next)
+	for _, rt := range []http.RoundTripper{petstore, petstore, other} {
+		resp, err := rt.RoundTrip(httptest.NewRequest(http.MethodGet, "http://petstore/pet", strings.NewReader("")))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+	require.Equal(t, []string{"/pet", "/pet", "/pet"}, sent)
+
+	counts, err = c.Count(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{"petstore": 2, "other": 1}, counts)
+	// Count returns a copy.
+	counts["petstore"] = 0
+	again, err := c.Count(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(2), again["petstore"])
+}
@@ -0,0 +1,289 @@
+// Package loadtest drives a Petdemo instance with a weighted mix of
+// requests, at a target rate or concurrency, and reports latency
+// percentiles, errors by kind and the calls made to downstreams.
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Profile describes the load to generate.
+type Profile struct {
+	// RPS is the target rate of requests per second, started whether or not
+	// earlier requests have completed. Either RPS or Concurrency is set.
+	RPS float64 `yaml:"rps"`
+	// Concurrency is the number of workers sending requests back to back.
+	Concurrency int `yaml:"concurrency"`
+	// MaxInFlight limits the concurrent requests at a target RPS. Requests
+	// due while the limit is reached are skipped and reported. Default 1000.
+	MaxInFlight int `yaml:"maxInFlight"`
+	// Duration of the run. Default 10s.
+	Duration time.Duration `yaml:"duration"`
+	// Timeout of each request. Default 10s.
+	Timeout time.Duration `yaml:"timeout"`
+	// Seed seeds the choice of endpoints, for repeatable runs.
+	Seed      int64      `yaml:"seed"`
+	Endpoints []Endpoint `yaml:"endpoints"`
+}
+
+// Endpoint is a request sent by the load test.
+type Endpoint struct {
+	Name    string            `yaml:"name"`
+	Method  string            `yaml:"method"`
+	Path    string            `yaml:"path"`
+	Headers map[string]string `yaml:"headers"`
+	Body    string            `yaml:"body"`
+	// Weight is the relative frequency of the endpoint. Default 1.
+	Weight int `yaml:"weight"`
+}
+
+// LoadProfile reads the profile at path, replacing ${NAME} in it with the
+// environment variable NAME, e.g. for tokens.
+func LoadProfile(path string) (*Profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var p Profile
+	if err := yaml.UnmarshalStrict([]byte(os.ExpandEnv(string(data))), &p); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &p, nil
+}
+
+func (p *Profile) validate() error {
+	switch {
+	case p.RPS < 0 || p.Concurrency < 0:
+		return fmt.Errorf("loadtest: rps and concurrency must not be negative")
+	case p.RPS > 0 && p.Concurrency > 0:
+		return fmt.Errorf("loadtest: set either rps or concurrency, not both")
+	case p.RPS == 0 && p.Concurrency == 0:
+		return fmt.Errorf("loadtest: set rps or concurrency")
+	case len(p.Endpoints) == 0:
+		return fmt.Errorf("loadtest: no endpoints")
+	}
+	if p.MaxInFlight == 0 {
+		p.MaxInFlight = 1000
+	}
+	if p.Duration == 0 {
+		p.Duration = 10 * time.Second
+	}
+	if p.Timeout == 0 {
+		p.Timeout = 10 * time.Second
+	}
+	for i := range p.Endpoints {
+		e := &p.Endpoints[i]
+		if e.Path == "" {
+			return fmt.Errorf("loadtest: endpoint %d has no path", i)
+		}
+		if e.Method == "" {
+			e.Method = http.MethodGet
+		}
+		if e.Name == "" {
+			e.Name = e.Method + " " + e.Path
+		}
+		if e.Weight < 0 {
+			return fmt.Errorf("loadtest: endpoint %s has a negative weight", e.Name)
+		}
+		if e.Weight == 0 {
+			e.Weight = 1
+		}
+	}
+	return nil
+}
+
+// DownstreamCounter returns the number of requests sent to each downstream
+// so far. It is called before and after the run.
+type DownstreamCounter func(ctx context.Context) (map[string]int64, error)
+
+// Run sends the requests of profile to the service at baseURL until the
+// duration of the profile elapses or ctx is done. downstream may be nil.
+func Run(ctx context.Context, baseURL string, profile Profile, downstream DownstreamCounter) (*Report, error) {
+	if err := profile.validate(); err != nil {
+		return nil, err
+	}
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	var before map[string]int64
+	if downstream != nil {
+		var err error
+		if before, err = downstream(ctx); err != nil {
+			return nil, fmt.Errorf("loadtest: counting downstream requests: %w", err)
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = profile.MaxInFlight
+	if profile.Concurrency > transport.MaxIdleConnsPerHost {
+		transport.MaxIdleConnsPerHost = profile.Concurrency
+	}
+	client := &http.Client{Transport: transport, Timeout: profile.Timeout}
+	defer transport.CloseIdleConnections()
+
+	r := &runner{
+		client:  client,
+		baseURL: baseURL,
+		profile: profile,
+		picker:  newPicker(profile.Endpoints, profile.Seed),
+	}
+	runCtx, cancel := context.WithTimeout(ctx, profile.Duration)
+	defer cancel()
+	start := time.Now()
+	if profile.RPS > 0 {
+		r.runRate(runCtx)
+	} else {
+		r.runConcurrent(runCtx)
+	}
+	elapsed := time.Since(start)
+
+	report := newReport(baseURL, profile, elapsed, r.results, r.skipped)
+	if downstream != nil {
+		after, err := downstream(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("loadtest: counting downstream requests: %w", err)
+		}
+		report.Downstream = make(map[string]int64, len(after))
+		for service, n := range after {
+			report.Downstream[service] = n - before[service]
+		}
+	}
+	return report, nil
+}
+
+type runner struct {
+	client  *http.Client
+	baseURL string
+	profile Profile
+	picker  *picker
+
+	mu      sync.Mutex
+	results []result
+	skipped int
+}
+
+// runConcurrent runs Concurrency workers sending requests back to back.
+func (r *runner) runConcurrent(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < r.profile.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				r.send(ctx)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// runRate starts requests at the target rate, regardless of the latency of
+// earlier ones, so that slow responses show up as latency rather than as a
+// lower rate.
+func (r *runner) runRate(ctx context.Context) {
+	interval := time.Duration(float64(time.Second) / r.profile.RPS)
+	inFlight := make(chan struct{}, r.profile.MaxInFlight)
+	var wg sync.WaitGroup
+	next := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case <-time.After(time.Until(next)):
+		}
+		next = next.Add(interval)
+		select {
+		case inFlight <- struct{}{}:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-inFlight }()
+				r.send(ctx)
+			}()
+		default:
+			r.mu.Lock()
+			r.skipped++
+			r.mu.Unlock()
+		}
+	}
+}
+
+// send sends one request. Requests cut short by the end of the run are not
+// recorded.
+func (r *runner) send(ctx context.Context) {
+	e := r.picker.pick()
+	var body io.Reader
+	if e.Body != "" {
+		body = strings.NewReader(e.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, e.Method, r.baseURL+e.Path, body)
+	if err != nil {
+		r.record(result{endpoint: e.Name, kind: KindOther})
+		return
+	}
+	for k, v := range e.Headers {
+		req.Header.Set(k, v)
+	}
+
+	start := time.Now()
+	resp, err := r.client.Do(req)
+	if err == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}
+	latency := time.Since(start)
+	if err != nil && ctx.Err() != nil && errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+	res := result{endpoint: e.Name, latency: latency}
+	if resp != nil {
+		res.status = resp.StatusCode
+	}
+	res.kind = classify(err, res.status)
+	r.record(res)
+}
+
+func (r *runner) record(res result) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results = append(r.results, res)
+}
+
+// picker chooses endpoints at random by weight.
+type picker struct {
+	mu        sync.Mutex
+	rnd       *rand.Rand
+	endpoints []Endpoint
+	total     int
+}
+
+func newPicker(endpoints []Endpoint, seed int64) *picker {
+	p := &picker{rnd: rand.New(rand.NewSource(seed)), endpoints: endpoints} //nolint:gosec // not security sensitive
+	for _, e := range endpoints {
+		p.total += e.Weight
+	}
+	return p
+}
+
+func (p *picker) pick() Endpoint {
+	p.mu.Lock()
+	n := p.rnd.Intn(p.total)
+	p.mu.Unlock()
+	for _, e := range p.endpoints {
+		if n < e.Weight {
+			return e
+		}
+		n -= e.Weight
+	}
+	return p.endpoints[len(p.endpoints)-1]
+}
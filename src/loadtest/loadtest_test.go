@@ -0,0 +1,145 @@
+package loadtest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	endpoints := []Endpoint{{Path: "/pet"}}
+	tests := []struct {
+		name    string
+		profile Profile
+		err     string
+	}{
+		{"negative rps", Profile{RPS: -1, Endpoints: endpoints}, "rps and concurrency must not be negative"},
+		{"negative concurrency", Profile{Concurrency: -1, Endpoints: endpoints}, "rps and concurrency must not be negative"},
+		{"both", Profile{RPS: 1, Concurrency: 1, Endpoints: endpoints}, "set either rps or concurrency, not both"},
+		{"neither", Profile{Endpoints: endpoints}, "set rps or concurrency"},
+		{"no endpoints", Profile{RPS: 1}, "no endpoints"},
+		{"no path", Profile{RPS: 1, Endpoints: []Endpoint{{Path: "/pet"}, {Name: "pet"}}}, "endpoint 1 has no path"},
+		{"negative weight", Profile{RPS: 1, Endpoints: []Endpoint{{Path: "/pet", Weight: -1}}}, "endpoint GET /pet has a negative weight"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.EqualError(t, tt.profile.validate(), "loadtest: "+tt.err)
+		})
+	}
+
+	p := Profile{Concurrency: 1, Endpoints: []Endpoint{{Path: "/pet"}, {Name: "create", Method: http.MethodPost, Path: "/pet", Weight: 3}}}
+	require.NoError(t, p.validate())
+	require.Equal(t, 1000, p.MaxInFlight)
+	require.Equal(t, 10*time.Second, p.Duration)
+	require.Equal(t, 10*time.Second, p.Timeout)
+	require.Equal(t, Endpoint{Name: "GET /pet", Method: http.MethodGet, Path: "/pet", Weight: 1}, p.Endpoints[0])
+	require.Equal(t, Endpoint{Name: "create", Method: http.MethodPost, Path: "/pet", Weight: 3}, p.Endpoints[1])
+}
+
+func TestLoadProfile(t *testing.T) {
+	t.Setenv("TOKEN", "secret")
+
+	p, err := LoadProfile("../../config/loadtest.yaml")
+	require.NoError(t, err)
+	require.Equal(t, "Bearer secret", p.Endpoints[0].Headers["Authorization"])
+	require.NoError(t, p.validate())
+
+	path := filepath.Join(t.TempDir(), "profile.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("rps: 1\nrate: 2\n"), 0o600))
+	_, err = LoadProfile(path)
+	require.ErrorContains(t, err, "field rate not found")
+	_, err = LoadProfile(filepath.Join(t.TempDir(), "missing.yaml"))
+	require.Error(t, err)
+}
+
+func TestPicker(t *testing.T) {
+	t.Parallel()
+
+	endpoints := []Endpoint{{Name: "a", Weight: 1}, {Name: "b", Weight: 3}, {Name: "c", Weight: 6}}
+	p := newPicker(endpoints, 1)
+	counts := map[string]int{}
+	var sequence []string
+	for i := 0; i < 10000; i++ {
+		name := p.pick().Name
+		counts[name]++
+		if i < 20 {
+			sequence = append(sequence, name)
+		}
+	}
+	require.InDelta(t, 1000, counts["a"], 150)
+	require.InDelta(t, 3000, counts["b"], 250)
+	require.InDelta(t, 6000, counts["c"], 250)
+
+	// The same seed picks the same endpoints.
+	again := newPicker(endpoints, 1)
+	for i, name := range sequence {
+		require.Equal(t, name, again.pick().Name, i)
+	}
+}
+
+func TestRunRate(t *testing.T) {
+	t.Parallel()
+
+	// Requests block until the end of the run, so that MaxInFlight is reached.
+	var received int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&received, 1)
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	report, err := Run(context.Background(), srv.URL, Profile{
+		RPS:         100,
+		MaxInFlight: 2,
+		Duration:    300 * time.Millisecond,
+		Endpoints:   []Endpoint{{Path: "/slow"}},
+	}, nil)
+	require.NoError(t, err)
+	require.Equal(t, int32(2), atomic.LoadInt32(&received))
+	// Requests cut short by the end of the run are not recorded.
+	require.Zero(t, report.Requests)
+	require.Greater(t, report.Skipped, 10)
+}
+
+func TestRunConcurrent(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	var calls int64
+	downstream := func(context.Context) (map[string]int64, error) {
+		calls++
+		return map[string]int64{"petstore": 10 * calls, "other": 5}, nil
+	}
+	report, err := Run(context.Background(), srv.URL+"/", Profile{
+		Concurrency: 2,
+		Duration:    100 * time.Millisecond,
+		Endpoints:   []Endpoint{{Name: "ok", Path: "/ok"}, {Name: "fail", Path: "/fail"}},
+	}, downstream)
+	require.NoError(t, err)
+	require.Equal(t, srv.URL, report.Target)
+	require.Positive(t, report.Requests)
+	ok, fail := report.Endpoints["ok"], report.Endpoints["fail"]
+	require.Equal(t, report.Requests, ok.Requests+fail.Requests)
+	require.Zero(t, ok.Errors)
+	require.Equal(t, fail.Requests, fail.Errors)
+	require.Equal(t, map[string]int{"http_503": fail.Errors}, fail.ErrorKinds)
+	require.Equal(t, map[string]int{"200": ok.Requests, "503": fail.Requests}, report.StatusCodes)
+	require.Equal(t, map[string]int64{"petstore": 10, "other": 0}, report.Downstream)
+}
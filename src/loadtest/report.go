@@ -0,0 +1,221 @@
+package loadtest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+// Kinds of request outcomes. Responses with an error status are of kind
+// "http_<code>", e.g. http_503.
+const (
+	KindOK                = "ok"
+	KindTimeout           = "timeout"
+	KindConnectionRefused = "connection_refused"
+	KindConnectionReset   = "connection_reset"
+	KindEOF               = "eof"
+	KindOther             = "other"
+)
+
+type result struct {
+	endpoint string
+	latency  time.Duration
+	status   int
+	kind     string
+}
+
+// Report is the outcome of a run, written as JSON for comparing runs.
+type Report struct {
+	Target      string    `json:"target"`
+	Started     time.Time `json:"started"`
+	RPS         float64   `json:"rps,omitempty"`
+	Concurrency int       `json:"concurrency,omitempty"`
+	DurationSec float64   `json:"durationSec"`
+	// Requests is the number of completed requests.
+	Requests int `json:"requests"`
+	Errors   int `json:"errors"`
+	// Skipped counts the requests not sent because MaxInFlight was reached.
+	Skipped     int     `json:"skipped"`
+	AchievedRPS float64 `json:"achievedRps"`
+	Latency     Latency `json:"latency"`
+	// StatusCodes counts the responses by status code.
+	StatusCodes map[string]int `json:"statusCodes"`
+	// ErrorKinds counts the failed requests by kind, see KindTimeout.
+	ErrorKinds map[string]int            `json:"errorKinds"`
+	Endpoints  map[string]EndpointReport `json:"endpoints"`
+	// Downstream counts the requests sent to each downstream during the run.
+	Downstream map[string]int64 `json:"downstream,omitempty"`
+}
+
+// EndpointReport is the outcome of the requests to one endpoint.
+type EndpointReport struct {
+	Requests   int            `json:"requests"`
+	Errors     int            `json:"errors"`
+	Latency    Latency        `json:"latency"`
+	ErrorKinds map[string]int `json:"errorKinds,omitempty"`
+}
+
+// Latency summarises latencies, in milliseconds.
+type Latency struct {
+	P50  float64 `json:"p50Ms"`
+	P90  float64 `json:"p90Ms"`
+	P99  float64 `json:"p99Ms"`
+	Max  float64 `json:"maxMs"`
+	Mean float64 `json:"meanMs"`
+}
+
+func newReport(target string, profile Profile, elapsed time.Duration, results []result, skipped int) *Report {
+	r := &Report{
+		Target:      target,
+		Started:     time.Now().Add(-elapsed).UTC().Truncate(time.Millisecond),
+		RPS:         profile.RPS,
+		Concurrency: profile.Concurrency,
+		DurationSec: elapsed.Seconds(),
+		Requests:    len(results),
+		Skipped:     skipped,
+		StatusCodes: map[string]int{},
+		ErrorKinds:  map[string]int{},
+		Endpoints:   map[string]EndpointReport{},
+	}
+	if elapsed > 0 {
+		r.AchievedRPS = float64(len(results)) / elapsed.Seconds()
+	}
+
+	all := make([]time.Duration, 0, len(results))
+	byEndpoint := map[string][]time.Duration{}
+	for _, res := range results {
+		all = append(all, res.latency)
+		byEndpoint[res.endpoint] = append(byEndpoint[res.endpoint], res.latency)
+		if res.status != 0 {
+			r.StatusCodes[strconv.Itoa(res.status)]++
+		}
+		e := r.Endpoints[res.endpoint]
+		e.Requests++
+		if res.kind != KindOK {
+			r.Errors++
+			r.ErrorKinds[res.kind]++
+			e.Errors++
+			if e.ErrorKinds == nil {
+				e.ErrorKinds = map[string]int{}
+			}
+			e.ErrorKinds[res.kind]++
+		}
+		r.Endpoints[res.endpoint] = e
+	}
+	r.Latency = summarise(all)
+	for name, latencies := range byEndpoint {
+		e := r.Endpoints[name]
+		e.Latency = summarise(latencies)
+		r.Endpoints[name] = e
+	}
+	return r
+}
+
+// summarise computes nearest-rank percentiles of latencies.
+func summarise(latencies []time.Duration) Latency {
+	if len(latencies) == 0 {
+		return Latency{}
+	}
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	percentile := func(p float64) float64 {
+		i := int(p*float64(len(sorted))+0.999999) - 1
+		if i < 0 {
+			i = 0
+		}
+		return ms(sorted[i])
+	}
+	var total time.Duration
+	for _, l := range sorted {
+		total += l
+	}
+	return Latency{
+		P50:  percentile(0.50),
+		P90:  percentile(0.90),
+		P99:  percentile(0.99),
+		Max:  ms(sorted[len(sorted)-1]),
+		Mean: ms(total / time.Duration(len(sorted))),
+	}
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// classify returns the kind of a request outcome.
+func classify(err error, status int) string {
+	var netErr net.Error
+	switch {
+	case err == nil && status >= 400:
+		return "http_" + strconv.Itoa(status)
+	case err == nil:
+		return KindOK
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return KindTimeout
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return KindConnectionRefused
+	case errors.Is(err, syscall.ECONNRESET):
+		return KindConnectionReset
+	case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		return KindEOF
+	default:
+		return KindOther
+	}
+}
+
+// WriteJSON writes the report as indented JSON to path, or to stdout if
+// path is "-".
+func (r *Report) WriteJSON(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// WriteSummary writes a human readable summary of the report.
+func (r *Report) WriteSummary(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "target\t%s\n", r.Target)
+	fmt.Fprintf(tw, "requests\t%d in %.1fs (%.1f/s), %d errors, %d skipped\n", r.Requests, r.DurationSec, r.AchievedRPS, r.Errors, r.Skipped)
+	fmt.Fprintf(tw, "latency\tp50 %.1fms\tp90 %.1fms\tp99 %.1fms\tmax %.1fms\n", r.Latency.P50, r.Latency.P90, r.Latency.P99, r.Latency.Max)
+	for _, name := range sortedKeys(r.Endpoints) {
+		e := r.Endpoints[name]
+		fmt.Fprintf(tw, "  %s\t%d requests, %d errors\tp50 %.1fms\tp90 %.1fms\tp99 %.1fms\n", name, e.Requests, e.Errors, e.Latency.P50, e.Latency.P90, e.Latency.P99)
+	}
+	for _, code := range sortedKeys(r.StatusCodes) {
+		fmt.Fprintf(tw, "status %s\t%d\n", code, r.StatusCodes[code])
+	}
+	for _, kind := range sortedKeys(r.ErrorKinds) {
+		fmt.Fprintf(tw, "error %s\t%d\n", kind, r.ErrorKinds[kind])
+	}
+	for _, service := range sortedKeys(r.Downstream) {
+		fmt.Fprintf(tw, "downstream %s\t%d requests\n", service, r.Downstream[service])
+	}
+	_ = tw.Flush()
+}
+
+// sortedKeys returns the keys of a map with string keys, sorted.
+func sortedKeys(m interface{}) []string {
+	keys := reflect.ValueOf(m).MapKeys()
+	result := make([]string, len(keys))
+	for i, k := range keys {
+		result[i] = k.String()
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,113 @@
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarise(t *testing.T) {
+	t.Parallel()
+
+	latencies := make([]time.Duration, 0, 100)
+	for i := 100; i > 0; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	require.Equal(t, Latency{P50: 50, P90: 90, P99: 99, Max: 100, Mean: 50.5}, summarise(latencies))
+	require.Equal(t, time.Duration(100)*time.Millisecond, latencies[0], "not sorted in place")
+
+	require.Equal(t, Latency{P50: 1.5, P90: 1.5, P99: 1.5, Max: 1.5, Mean: 1.5}, summarise([]time.Duration{1500 * time.Microsecond}))
+	require.Equal(t, Latency{P50: 1, P90: 2, P99: 2, Max: 2, Mean: 1.5}, summarise([]time.Duration{2 * time.Millisecond, time.Millisecond}))
+	require.Equal(t, Latency{}, summarise(nil))
+}
+
+func TestClassify(t *testing.T) {
+	t.Parallel()
+
+	get := func(err error) error {
+		return &url.Error{Op: "Get", URL: "http://localhost/pet", Err: err}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	<-ctx.Done()
+
+	tests := []struct {
+		err    error
+		status int
+		want   string
+	}{
+		{nil, 200, KindOK},
+		{nil, 302, KindOK},
+		{nil, 404, "http_404"},
+		{nil, 503, "http_503"},
+		{get(ctx.Err()), 0, KindTimeout},
+		{get(&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}), 0, KindConnectionRefused},
+		{get(&net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}), 0, KindConnectionReset},
+		{get(io.EOF), 0, KindEOF},
+		{fmt.Errorf("reading body: %w", io.ErrUnexpectedEOF), 200, KindEOF},
+		{errors.New("boom"), 0, KindOther},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, classify(tt.err, tt.status), "%v %d", tt.err, tt.status)
+	}
+}
+
+func TestReport(t *testing.T) {
+	t.Parallel()
+
+	results := []result{
+		{endpoint: "pet", latency: 10 * time.Millisecond, status: 200, kind: KindOK},
+		{endpoint: "pet", latency: 30 * time.Millisecond, status: 503, kind: "http_503"},
+		{endpoint: "pet", latency: 20 * time.Millisecond, kind: KindTimeout},
+		{endpoint: "create", latency: 40 * time.Millisecond, status: 201, kind: KindOK},
+	}
+	r := newReport("http://localhost", Profile{RPS: 2}, 2*time.Second, results, 3)
+	require.Equal(t, 4, r.Requests)
+	require.Equal(t, 2, r.Errors)
+	require.Equal(t, 3, r.Skipped)
+	require.Equal(t, 2.0, r.AchievedRPS)
+	require.Equal(t, map[string]int{"200": 1, "201": 1, "503": 1}, r.StatusCodes)
+	require.Equal(t, map[string]int{"http_503": 1, KindTimeout: 1}, r.ErrorKinds)
+	require.Equal(t, EndpointReport{
+		Requests:   3,
+		Errors:     2,
+		Latency:    Latency{P50: 20, P90: 30, P99: 30, Max: 30, Mean: 20},
+		ErrorKinds: map[string]int{"http_503": 1, KindTimeout: 1},
+	}, r.Endpoints["pet"])
+	require.Nil(t, r.Endpoints["create"].ErrorKinds)
+	require.Equal(t, Latency{P50: 20, P90: 40, P99: 40, Max: 40, Mean: 25}, r.Latency)
+
+	r.Downstream = map[string]int64{"petstore": 2}
+	var summary bytes.Buffer
+	r.WriteSummary(&summary)
+	for _, line := range []string{
+		"4 in 2.0s (2.0/s), 2 errors, 3 skipped",
+		"3 requests, 2 errors",
+		"status 503",
+		"error timeout",
+		"downstream petstore",
+	} {
+		require.Contains(t, summary.String(), line)
+	}
+
+	path := filepath.Join(t.TempDir(), "report.json")
+	require.NoError(t, r.WriteJSON(path))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var written Report
+	require.NoError(t, json.Unmarshal(data, &written))
+	require.Equal(t, r.Endpoints, written.Endpoints)
+	require.Equal(t, r.Downstream, written.Downstream)
+}
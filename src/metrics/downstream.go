@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DownstreamRequestsName is the name of the counter of downstream requests.
+const DownstreamRequestsName = "downstream_requests_total"
+
+// Downstream counts the requests sent to downstreams, by service and status
+// code, or "error" for requests that failed without a response.
+type Downstream struct {
+	requests *prometheus.CounterVec
+}
+
+// NewDownstream creates a Downstream, to be registered in a Registry.
+func NewDownstream() *Downstream {
+	return &Downstream{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: DownstreamRequestsName,
+			Help: "Requests sent to downstreams, by service and status code.",
+		}, []string{"service", "code"}),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (d *Downstream) Describe(ch chan<- *prometheus.Desc) {
+	d.requests.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (d *Downstream) Collect(ch chan<- prometheus.Metric) {
+	d.requests.Collect(ch)
+}
+
+// RoundTripper counts the requests sent through original. It has the
+// signature of core.Hooks.DownstreamRoundTripper.
+func (d *Downstream) RoundTripper(serviceName, _ string, original http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := original.RoundTrip(req)
+		code := "error"
+		if err == nil {
+			code = strconv.Itoa(resp.StatusCode)
+		}
+		d.requests.WithLabelValues(serviceName, code).Inc()
+		return resp, err
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
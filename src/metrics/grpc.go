@@ -0,0 +1,284 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// Types of gRPC calls, the grpc_type label of the gRPC metrics.
+const (
+	grpcUnary        = "unary"
+	grpcClientStream = "client_stream"
+	grpcServerStream = "server_stream"
+	grpcBidiStream   = "bidi_stream"
+)
+
+// GRPC measures the calls served by the public gRPC server and the calls
+// made to gRPC downstreams, by method and status code:
+//
+//	grpc_server_handled_total        calls completed, by code
+//	grpc_server_handling_seconds     latency of the calls, until the last message
+//	grpc_server_in_flight            calls in progress
+//	grpc_server_msg_received_total   messages received on streams and unary calls
+//	grpc_server_msg_sent_total       messages sent on streams and unary calls
+//
+// and the same grpc_client_* metrics for downstream calls. Methods are
+// labelled by grpc_service and grpc_method, e.g. petdemo.PetService and
+// GetPet, and by grpc_type: unary, client_stream, server_stream or
+// bidi_stream.
+type GRPC struct {
+	server *grpcMetrics
+	client *grpcMetrics
+}
+
+// grpcMetrics are the metrics of one side of the calls, server or client.
+type grpcMetrics struct {
+	handled  *prometheus.CounterVec
+	handling *prometheus.HistogramVec
+	inFlight *prometheus.GaugeVec
+	received *prometheus.CounterVec
+	sent     *prometheus.CounterVec
+}
+
+// NewGRPC creates a GRPC, to be registered in a Registry.
+func NewGRPC() *GRPC {
+	return &GRPC{
+		server: newGRPCMetrics("server", "served"),
+		client: newGRPCMetrics("client", "sent to downstreams"),
+	}
+}
+
+func newGRPCMetrics(side, calls string) *grpcMetrics {
+	labels := []string{"grpc_type", "grpc_service", "grpc_method"}
+	return &grpcMetrics{
+		handled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_" + side + "_handled_total",
+			Help: "gRPC calls " + calls + ", by method and status code.",
+		}, append(labels, "grpc_code")),
+		handling: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "grpc_" + side + "_handling_seconds",
+			Help:    "Latency of the gRPC calls " + calls + ", by method.",
+			Buckets: prometheus.DefBuckets,
+		}, labels),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "grpc_" + side + "_in_flight",
+			Help: "gRPC calls " + calls + " in progress, by method.",
+		}, labels),
+		received: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_" + side + "_msg_received_total",
+			Help: "Messages received on the gRPC calls " + calls + ", by method.",
+		}, labels),
+		sent: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_" + side + "_msg_sent_total",
+			Help: "Messages sent on the gRPC calls " + calls + ", by method.",
+		}, labels),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (g *GRPC) Describe(ch chan<- *prometheus.Desc) {
+	g.server.describe(ch)
+	g.client.describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (g *GRPC) Collect(ch chan<- prometheus.Metric) {
+	g.server.collect(ch)
+	g.client.collect(ch)
+}
+
+func (m *grpcMetrics) describe(ch chan<- *prometheus.Desc) {
+	m.handled.Describe(ch)
+	m.handling.Describe(ch)
+	m.inFlight.Describe(ch)
+	m.received.Describe(ch)
+	m.sent.Describe(ch)
+}
+
+func (m *grpcMetrics) collect(ch chan<- prometheus.Metric) {
+	m.handled.Collect(ch)
+	m.handling.Collect(ch)
+	m.inFlight.Collect(ch)
+	m.received.Collect(ch)
+	m.sent.Collect(ch)
+}
+
+// ServerOptions returns the interceptors measuring the calls of a gRPC
+// server, to be added to core.Hooks.AdditionalGrpcServerOptions, which
+// sysl-go appends to its DefaultGrpcServerOptions. Interceptors added after
+// them, such as authorization, are measured too.
+func (g *GRPC) ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			call := g.server.start(grpcUnary, info.FullMethod)
+			call.received()
+			resp, err := handler(ctx, req)
+			if err == nil {
+				call.sent()
+			}
+			call.finish(err)
+			return resp, err
+		}),
+		grpc.ChainStreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			call := g.server.start(streamType(info.IsClientStream, info.IsServerStream), info.FullMethod)
+			err := handler(srv, &serverStream{ServerStream: ss, call: call})
+			call.finish(err)
+			return err
+		}),
+	}
+}
+
+// DialOptions returns the interceptors measuring the calls of gRPC clients,
+// to be added to core.Hooks.AdditionalGrpcDialOptions so that they apply to
+// the connections made by core.BuildDownstreamGRPCClient.
+func (g *GRPC) DialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			call := g.client.start(grpcUnary, method)
+			call.sent()
+			err := invoker(ctx, method, req, reply, cc, opts...)
+			if err == nil {
+				call.received()
+			}
+			call.finish(err)
+			return err
+		}),
+		grpc.WithChainStreamInterceptor(g.interceptClientStream),
+	}
+}
+
+// interceptClientStream measures a stream to a gRPC downstream. The call is
+// finished by the stream, or when ctx is done if the caller abandons the
+// stream without reading it until it ends.
+func (g *GRPC) interceptClientStream(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	call := g.client.start(streamType(desc.ClientStreams, desc.ServerStreams), method)
+	cs, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		call.finish(err)
+		return nil, err
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			call.finish(status.FromContextError(ctx.Err()).Err())
+		case <-call.done:
+		}
+	}()
+	return &clientStream{ClientStream: cs, call: call, serverStreams: desc.ServerStreams}, nil
+}
+
+func streamType(clientStreams, serverStreams bool) string {
+	switch {
+	case clientStreams && serverStreams:
+		return grpcBidiStream
+	case clientStreams:
+		return grpcClientStream
+	case serverStreams:
+		return grpcServerStream
+	}
+	return grpcUnary
+}
+
+// grpcCall measures a call in progress.
+type grpcCall struct {
+	metrics *grpcMetrics
+	labels  []string
+	start   time.Time
+	once    sync.Once
+	done    chan struct{} // closed by finish
+}
+
+// start counts a call to fullMethod, named as in /petdemo.PetService/GetPet,
+// as in flight until finish is called.
+func (m *grpcMetrics) start(callType, fullMethod string) *grpcCall {
+	service, method := "unknown", "unknown"
+	if i := strings.LastIndex(fullMethod, "/"); i >= 0 {
+		service, method = strings.TrimPrefix(fullMethod[:i], "/"), fullMethod[i+1:]
+	}
+	call := &grpcCall{metrics: m, labels: []string{callType, service, method}, start: time.Now(), done: make(chan struct{})}
+	m.inFlight.WithLabelValues(call.labels...).Inc()
+	return call
+}
+
+func (c *grpcCall) received() {
+	c.metrics.received.WithLabelValues(c.labels...).Inc()
+}
+
+func (c *grpcCall) sent() {
+	c.metrics.sent.WithLabelValues(c.labels...).Inc()
+}
+
+// finish counts the call as handled with the code of err. Only the first
+// call has an effect.
+func (c *grpcCall) finish(err error) {
+	c.once.Do(func() {
+		c.metrics.inFlight.WithLabelValues(c.labels...).Dec()
+		c.metrics.handling.WithLabelValues(c.labels...).Observe(time.Since(c.start).Seconds())
+		c.metrics.handled.WithLabelValues(append(c.labels, status.Code(err).String())...).Inc()
+		close(c.done)
+	})
+}
+
+// serverStream counts the messages of a server stream.
+type serverStream struct {
+	grpc.ServerStream
+	call *grpcCall
+}
+
+func (s *serverStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.call.sent()
+	}
+	return err
+}
+
+func (s *serverStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.call.received()
+	}
+	return err
+}
+
+// clientStream counts the messages of a client stream. The call is finished
+// when the last message is received, or the only one if the server does not
+// stream. Clients must read streams until they end or cancel their context,
+// which finishes the call too.
+type clientStream struct {
+	grpc.ClientStream
+	call          *grpcCall
+	serverStreams bool
+}
+
+func (s *clientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.call.sent()
+	}
+	return err
+}
+
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == nil:
+		s.call.received()
+		if !s.serverStreams {
+			s.call.finish(nil)
+		}
+	case errors.Is(err, io.EOF):
+		s.call.finish(nil)
+	default:
+		s.call.finish(err)
+	}
+	return err
+}
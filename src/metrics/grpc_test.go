@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeStream is a client stream whose messages are received from recv.
+type fakeStream struct {
+	grpc.ClientStream
+	recv func() error
+}
+
+func (s *fakeStream) RecvMsg(interface{}) error {
+	return s.recv()
+}
+
+func value(t *testing.T, m prometheus.Metric) float64 {
+	t.Helper()
+	var out dto.Metric
+	require.NoError(t, m.Write(&out))
+	if out.Gauge != nil {
+		return out.Gauge.GetValue()
+	}
+	return out.Counter.GetValue()
+}
+
+func TestClientStream(t *testing.T) {
+	t.Parallel()
+
+	const method = "/petdemo.PetService/WatchPets"
+	ended := func() error { return io.EOF }
+	tests := []struct {
+		name      string
+		desc      grpc.StreamDesc
+		streamErr error
+		recv      func() error  // nil if the stream is not read
+		timeout   time.Duration // of the context, cancelled after reading if 0
+		code      codes.Code
+	}{
+		{"read until end", grpc.StreamDesc{ServerStreams: true}, nil, ended, 0, codes.OK},
+		{"single message", grpc.StreamDesc{ClientStreams: true}, nil, func() error { return nil }, 0, codes.OK},
+		{"stream error", grpc.StreamDesc{ServerStreams: true}, nil, func() error { return status.Error(codes.NotFound, "no pet") }, 0, codes.NotFound},
+		{"not started", grpc.StreamDesc{ServerStreams: true}, status.Error(codes.Unavailable, "down"), nil, 0, codes.Unavailable},
+		{"cancelled", grpc.StreamDesc{ServerStreams: true}, nil, nil, 0, codes.Canceled},
+		{"deadline", grpc.StreamDesc{ServerStreams: true}, nil, nil, 50 * time.Millisecond, codes.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			g := NewGRPC()
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.timeout > 0 {
+				ctx, cancel = context.WithTimeout(context.Background(), tt.timeout)
+			}
+			defer cancel()
+			streamer := func(context.Context, *grpc.StreamDesc, *grpc.ClientConn, string, ...grpc.CallOption) (grpc.ClientStream, error) {
+				return &fakeStream{recv: tt.recv}, tt.streamErr
+			}
+			cs, err := g.interceptClientStream(ctx, &tt.desc, nil, method, streamer)
+			require.Equal(t, tt.streamErr, err)
+			if tt.recv != nil {
+				_ = cs.RecvMsg(nil)
+			}
+			if tt.timeout > 0 {
+				<-ctx.Done()
+			}
+			cancel()
+
+			labels := []string{streamType(tt.desc.ClientStreams, tt.desc.ServerStreams), "petdemo.PetService", "WatchPets"}
+			handled := g.client.handled.WithLabelValues(append(labels, tt.code.String())...)
+			require.Eventually(t, func() bool { return value(t, handled) == 1 }, time.Second, 5*time.Millisecond)
+			require.Zero(t, value(t, g.client.inFlight.WithLabelValues(labels...)))
+			// The call is finished once only, after the context is cancelled too.
+			time.Sleep(10 * time.Millisecond)
+			for _, code := range []codes.Code{codes.OK, codes.Canceled, codes.DeadlineExceeded, codes.NotFound, codes.Unavailable} {
+				if code != tt.code {
+					require.Zero(t, value(t, g.client.handled.WithLabelValues(append(labels, code.String())...)), code)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+// Package metrics serves application metrics alongside the sysl-go metrics
+// on the admin /-/metrics endpoint.
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// Registry holds the metrics of the application. sysl-go keeps its own
+// registry private, so the two are merged when /-/metrics is scraped.
+type Registry struct {
+	*prometheus.Registry
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{Registry: prometheus.NewRegistry()}
+}
+
+// Middleware adds the metrics in the registry to the response of the
+// sysl-go /-/metrics endpoint. It has the signature of
+// core.Hooks.AddAdminHTTPMiddleware.
+func (reg *Registry) Middleware(_ context.Context, r chi.Router) {
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			path := req.URL.Path
+			if rctx := chi.RouteContext(req.Context()); rctx != nil && rctx.RoutePath != "" {
+				path = rctx.RoutePath
+			}
+			if req.Method != http.MethodGet || strings.TrimSuffix(path, "/") != "/-/metrics" {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			// Ask sysl-go for the plain text format, which can be parsed back.
+			plain := req.Clone(req.Context())
+			plain.Header.Set("Accept", string(expfmt.FmtText))
+			plain.Header.Del("Accept-Encoding")
+			rec := &recorder{header: make(http.Header), status: http.StatusOK}
+			next.ServeHTTP(rec, plain)
+			if rec.status != http.StatusOK {
+				rec.writeTo(w)
+				return
+			}
+			var parser expfmt.TextParser
+			families, err := parser.TextToMetricFamilies(&rec.body)
+			if err != nil {
+				http.Error(w, "failed to merge metrics: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			syslgo := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+				result := make([]*dto.MetricFamily, 0, len(families))
+				for _, family := range families {
+					result = append(result, family)
+				}
+				return result, nil
+			})
+			promhttp.HandlerFor(prometheus.Gatherers{syslgo, reg}, promhttp.HandlerOpts{}).ServeHTTP(w, req)
+		})
+	})
+}
+
+// recorder captures the response of the sysl-go metrics handler.
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *recorder) Header() http.Header         { return r.header }
+func (r *recorder) Write(p []byte) (int, error) { return r.body.Write(p) }
+func (r *recorder) WriteHeader(status int)      { r.status = status }
+
+func (r *recorder) writeTo(w http.ResponseWriter) {
+	for name, values := range r.header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(r.status)
+	_, _ = r.body.WriteTo(w)
+}
@@ -0,0 +1,151 @@
+// Package mtls exposes the verified client certificate of a mutual TLS
+// connection as a peer identity in the request context.
+package mtls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// Identity describes the leaf certificate of a verified client chain.
+type Identity struct {
+	// Subject is the distinguished name, e.g. "CN=reconciler,O=ACME".
+	Subject    string
+	CommonName string
+
+	DNSNames       []string
+	URIs           []string
+	EmailAddresses []string
+	IPAddresses    []string
+
+	// SPIFFEID is the spiffe:// URI SAN of the certificate, if it has one.
+	SPIFFEID string
+}
+
+// Fields are the names by which identity values are selected, as used in
+// authorization expressions.
+const (
+	FieldSubject  = "subject"
+	FieldCN       = "cn"
+	FieldDNS      = "dns"
+	FieldURI      = "uri"
+	FieldEmail    = "email"
+	FieldIP       = "ip"
+	FieldSPIFFEID = "spiffe_id"
+)
+
+// ValidField reports whether field is one of the Field constants.
+func ValidField(field string) bool {
+	switch field {
+	case FieldSubject, FieldCN, FieldDNS, FieldURI, FieldEmail, FieldIP, FieldSPIFFEID:
+		return true
+	}
+	return false
+}
+
+// Values returns the values of the named field, or nil for an unknown field.
+func (id *Identity) Values(field string) []string {
+	if id == nil {
+		return nil
+	}
+	switch field {
+	case FieldSubject:
+		return []string{id.Subject}
+	case FieldCN:
+		return nonEmpty(id.CommonName)
+	case FieldDNS:
+		return id.DNSNames
+	case FieldURI:
+		return id.URIs
+	case FieldEmail:
+		return id.EmailAddresses
+	case FieldIP:
+		return id.IPAddresses
+	case FieldSPIFFEID:
+		return nonEmpty(id.SPIFFEID)
+	}
+	return nil
+}
+
+func nonEmpty(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return []string{s}
+}
+
+// FromConnectionState returns the identity of the peer of a TLS connection,
+// or nil unless the peer presented a certificate that was verified against
+// the trusted certificate pool.
+func FromConnectionState(state *tls.ConnectionState) *Identity {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return fromCertificate(state.VerifiedChains[0][0])
+}
+
+// FromPeer returns the identity of the peer of a gRPC call, as
+// FromConnectionState does for the TLS connection of the call.
+func FromPeer(ctx context.Context) *Identity {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return nil
+	}
+	return FromConnectionState(&info.State)
+}
+
+func fromCertificate(cert *x509.Certificate) *Identity {
+	id := &Identity{
+		Subject:        cert.Subject.String(),
+		CommonName:     cert.Subject.CommonName,
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+	}
+	for _, ip := range cert.IPAddresses {
+		id.IPAddresses = append(id.IPAddresses, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		id.URIs = append(id.URIs, uri.String())
+		if uri.Scheme == "spiffe" && id.SPIFFEID == "" {
+			id.SPIFFEID = uri.String()
+		}
+	}
+	return id
+}
+
+type identityKey struct{}
+
+// NewContext returns a copy of ctx carrying id.
+func NewContext(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext returns the peer identity carried by ctx, if any.
+func FromContext(ctx context.Context) (*Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(*Identity)
+	return id, ok && id != nil
+}
+
+// Middleware adds the identity of verified client certificates to the request
+// context. It has the signature of core.Hooks.AddHTTPMiddleware, and must be
+// installed before any middleware that authorizes on the identity.
+func Middleware(_ context.Context, r chi.Router) {
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if id := FromConnectionState(req.TLS); id != nil {
+				req = req.WithContext(NewContext(req.Context(), id))
+			}
+			next.ServeHTTP(w, req)
+		})
+	})
+}
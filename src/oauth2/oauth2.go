@@ -0,0 +1,218 @@
+// Package oauth2 obtains access tokens with the OAuth2 client credentials
+// grant (RFC 6749 section 4.4) and adds them to downstream requests.
+package oauth2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anz-bank/sysl-go/config"
+)
+
+const (
+	defaultRefreshBefore = time.Minute
+	defaultExpiresIn     = 5 * time.Minute
+
+	// AuthStyleBasic sends the client credentials in an Authorization header.
+	AuthStyleBasic = "basic"
+	// AuthStyleBody sends the client credentials as form parameters.
+	AuthStyleBody = "body"
+)
+
+// Config configures the client credentials grant of a downstream, found
+// under genCode.downstream.<name>.auth.oauth2.
+type Config struct {
+	TokenURL string `yaml:"tokenURL" mapstructure:"tokenURL"`
+	ClientID string `yaml:"clientID" mapstructure:"clientID"`
+	// ClientSecret may be a reference to an environment variable or file,
+	// see configext.ResolveSecret.
+	ClientSecret config.SensitiveString `yaml:"clientSecret" mapstructure:"clientSecret"`
+	Scopes       []string               `yaml:"scopes" mapstructure:"scopes"`
+
+	// AuthStyle is how the client credentials are sent, basic (the default) or body.
+	AuthStyle string `yaml:"authStyle" mapstructure:"authStyle"`
+	// RefreshBefore is how long before it expires a token is replaced, 1m by default.
+	RefreshBefore time.Duration `yaml:"refreshBefore" mapstructure:"refreshBefore"`
+}
+
+// Validate checks the config for missing or invalid values.
+func (c *Config) Validate() error {
+	switch {
+	case c.TokenURL == "":
+		return errors.New("tokenURL must be set")
+	case c.ClientID == "":
+		return errors.New("clientID must be set")
+	case c.ClientSecret.Value() == "":
+		return errors.New("clientSecret must be set")
+	}
+	if _, err := url.Parse(c.TokenURL); err != nil {
+		return fmt.Errorf("invalid tokenURL: %w", err)
+	}
+	switch c.AuthStyle {
+	case "", AuthStyleBasic, AuthStyleBody:
+	default:
+		return fmt.Errorf("invalid authStyle %q, expected %s or %s", c.AuthStyle, AuthStyleBasic, AuthStyleBody)
+	}
+	return nil
+}
+
+// TokenSource caches the access token of a client. Concurrent requests for a
+// token while it is being fetched share a single token request.
+type TokenSource struct {
+	cfg    Config
+	client *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expiry  time.Time
+	pending *fetch
+}
+
+// fetch is a token request shared by every caller waiting for its result.
+type fetch struct {
+	done   chan struct{}
+	token  string
+	expiry time.Time
+	err    error
+}
+
+// NewTokenSource creates a TokenSource requesting tokens with client.
+func NewTokenSource(cfg Config, client *http.Client) (*TokenSource, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.AuthStyle == "" {
+		cfg.AuthStyle = AuthStyleBasic
+	}
+	if cfg.RefreshBefore == 0 {
+		cfg.RefreshBefore = defaultRefreshBefore
+	}
+	return &TokenSource{cfg: cfg, client: client}, nil
+}
+
+// Token returns the cached token, or fetches a new one if there is no token
+// or it has expired. A token expiring within RefreshBefore is still returned,
+// while a new one is fetched in the background.
+func (s *TokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	now := time.Now()
+	if s.token != "" && now.Before(s.expiry) {
+		token := s.token
+		if !now.Add(s.cfg.RefreshBefore).Before(s.expiry) {
+			s.startFetch()
+		}
+		s.mu.Unlock()
+		return token, nil
+	}
+	f := s.startFetch()
+	s.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.token, f.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// Invalidate discards token if it is still the cached token, so that the
+// next call to Token fetches a new one.
+func (s *TokenSource) Invalidate(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token == token {
+		s.token, s.expiry = "", time.Time{}
+	}
+}
+
+// startFetch returns the pending token request, starting one if there is
+// none. s.mu must be held.
+func (s *TokenSource) startFetch() *fetch {
+	if s.pending == nil {
+		s.pending = &fetch{done: make(chan struct{})}
+		go s.fetch(s.pending)
+	}
+	return s.pending
+}
+
+func (s *TokenSource) fetch(f *fetch) {
+	// The request is shared, so it is not bound to the context of any one caller.
+	f.token, f.expiry, f.err = s.requestToken(context.Background())
+	s.mu.Lock()
+	if f.err == nil {
+		s.token, s.expiry = f.token, f.expiry
+	}
+	s.pending = nil
+	s.mu.Unlock()
+	close(f.done)
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func (s *TokenSource) requestToken(ctx context.Context) (string, time.Time, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(s.cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.cfg.Scopes, " "))
+	}
+	if s.cfg.AuthStyle == AuthStyleBody {
+		form.Set("client_id", s.cfg.ClientID)
+		form.Set("client_secret", s.cfg.ClientSecret.Value())
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if s.cfg.AuthStyle == AuthStyleBasic {
+		req.SetBasicAuth(url.QueryEscape(s.cfg.ClientID), url.QueryEscape(s.cfg.ClientSecret.Value()))
+	}
+
+	start := time.Now()
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("oauth2: token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("oauth2: reading token response: %w", err)
+	}
+
+	var tr tokenResponse
+	if err := json.Unmarshal(body, &tr); err != nil && resp.StatusCode == http.StatusOK {
+		return "", time.Time{}, fmt.Errorf("oauth2: invalid token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if tr.Error != "" {
+			return "", time.Time{}, fmt.Errorf("oauth2: token request failed with status %d: %s %s", resp.StatusCode, tr.Error, tr.ErrorDescription)
+		}
+		return "", time.Time{}, fmt.Errorf("oauth2: token request failed with status %d", resp.StatusCode)
+	}
+	if tr.AccessToken == "" {
+		return "", time.Time{}, errors.New("oauth2: token response has no access_token")
+	}
+	if tr.TokenType != "" && !strings.EqualFold(tr.TokenType, "bearer") {
+		return "", time.Time{}, fmt.Errorf("oauth2: unsupported token type %q", tr.TokenType)
+	}
+	expiresIn := time.Duration(tr.ExpiresIn) * time.Second
+	if expiresIn <= 0 {
+		expiresIn = defaultExpiresIn
+	}
+	return tr.AccessToken, start.Add(expiresIn), nil
+}
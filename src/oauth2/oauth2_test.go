@@ -0,0 +1,293 @@
+package oauth2
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anz-bank/sysl-go/config"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anz-bank/sysl-go-demo/src/oauth2/oauth2test"
+)
+
+func newSource(t *testing.T, srv *oauth2test.Server, cfg Config) *TokenSource {
+	t.Helper()
+	cfg.TokenURL = srv.TokenURL()
+	if cfg.ClientID == "" {
+		cfg.ClientID = srv.ClientID
+	}
+	if cfg.ClientSecret.Value() == "" {
+		cfg.ClientSecret = config.NewSensitiveString(srv.ClientSecret)
+	}
+	s, err := NewTokenSource(cfg, srv.Client())
+	require.NoError(t, err)
+	return s
+}
+
+func newServer(t *testing.T) *oauth2test.Server {
+	t.Helper()
+	srv := oauth2test.NewServer("client", "s3cret&+")
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	valid := func() Config {
+		return Config{TokenURL: "http://localhost/token", ClientID: "client", ClientSecret: config.NewSensitiveString("secret")}
+	}
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		err    string
+	}{
+		{"valid", func(*Config) {}, ""},
+		{"body auth style", func(c *Config) { c.AuthStyle = AuthStyleBody }, ""},
+		{"no token URL", func(c *Config) { c.TokenURL = "" }, "tokenURL must be set"},
+		{"no client ID", func(c *Config) { c.ClientID = "" }, "clientID must be set"},
+		{"no client secret", func(c *Config) { c.ClientSecret = config.NewSensitiveString("") }, "clientSecret must be set"},
+		{"invalid token URL", func(c *Config) { c.TokenURL = "http://[::1" }, "invalid tokenURL"},
+		{"invalid auth style", func(c *Config) { c.AuthStyle = "header" }, `invalid authStyle "header"`},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := valid()
+			tt.modify(&cfg)
+			err := cfg.Validate()
+			if tt.err == "" {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestTokenAuthStyles(t *testing.T) {
+	t.Parallel()
+
+	for _, style := range []string{"", AuthStyleBasic, AuthStyleBody} {
+		style := style
+		t.Run("style "+style, func(t *testing.T) {
+			t.Parallel()
+			srv := newServer(t)
+			s := newSource(t, srv, Config{AuthStyle: style, Scopes: []string{"pets.read", "pets.write"}})
+
+			token, err := s.Token(context.Background())
+			require.NoError(t, err)
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			require.True(t, srv.Valid(req, "pets.write"))
+		})
+	}
+}
+
+func TestTokenRejected(t *testing.T) {
+	t.Parallel()
+
+	srv := newServer(t)
+	s := newSource(t, srv, Config{ClientSecret: config.NewSensitiveString("wrong")})
+	_, err := s.Token(context.Background())
+	require.ErrorContains(t, err, "status 401: invalid_client")
+
+	// Failures are not cached.
+	_, err = s.Token(context.Background())
+	require.Error(t, err)
+	require.Equal(t, 2, srv.Requests())
+}
+
+func TestTokenCached(t *testing.T) {
+	t.Parallel()
+
+	srv := newServer(t)
+	s := newSource(t, srv, Config{})
+	first, err := s.Token(context.Background())
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		token, err := s.Token(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, first, token)
+	}
+	require.Equal(t, 1, srv.Requests())
+
+	s.Invalidate("another")
+	token, err := s.Token(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, first, token)
+
+	s.Invalidate(first)
+	token, err = s.Token(context.Background())
+	require.NoError(t, err)
+	require.NotEqual(t, first, token)
+	require.Equal(t, 2, srv.Requests())
+}
+
+func TestTokenRefreshedBeforeExpiry(t *testing.T) {
+	t.Parallel()
+
+	srv := newServer(t)
+	// Tokens expire within RefreshBefore as soon as they are issued.
+	srv.SetExpiresIn(time.Minute)
+	s := newSource(t, srv, Config{RefreshBefore: time.Hour})
+	first, err := s.Token(context.Background())
+	require.NoError(t, err)
+
+	// The token is still valid, so it is returned without waiting for the
+	// refresh, even if the token server is slow.
+	srv.SetDelay(time.Second)
+	start := time.Now()
+	token, err := s.Token(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, first, token)
+	require.Less(t, time.Since(start), 500*time.Millisecond)
+
+	require.Eventually(t, func() bool {
+		token, err := s.Token(context.Background())
+		return err == nil && token != first
+	}, 5*time.Second, 10*time.Millisecond)
+	// Calls made while the refresh was pending shared it, and each new
+	// token starts another refresh, but only one at a time.
+	require.LessOrEqual(t, srv.Requests(), 3)
+}
+
+func TestTokenExpired(t *testing.T) {
+	t.Parallel()
+
+	srv := newServer(t)
+	srv.SetExpiresIn(time.Second)
+	s := newSource(t, srv, Config{RefreshBefore: time.Millisecond})
+	first, err := s.Token(context.Background())
+	require.NoError(t, err)
+
+	time.Sleep(1100 * time.Millisecond)
+	token, err := s.Token(context.Background())
+	require.NoError(t, err)
+	require.NotEqual(t, first, token)
+	require.Equal(t, 2, srv.Requests())
+}
+
+func TestTokenCoalesced(t *testing.T) {
+	t.Parallel()
+
+	srv := newServer(t)
+	srv.SetDelay(100 * time.Millisecond)
+	s := newSource(t, srv, Config{})
+
+	const callers = 20
+	tokens := make([]string, callers)
+	errs := make([]error, callers)
+	var wg sync.WaitGroup
+	for i := range tokens {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tokens[i], errs[i] = s.Token(context.Background())
+		}(i)
+	}
+	wg.Wait()
+	for i, token := range tokens {
+		require.NoError(t, errs[i])
+		require.Equal(t, tokens[0], token)
+	}
+	require.Equal(t, 1, srv.Requests())
+}
+
+func TestTokenContextCancelled(t *testing.T) {
+	t.Parallel()
+
+	srv := newServer(t)
+	srv.SetDelay(200 * time.Millisecond)
+	s := newSource(t, srv, Config{})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := s.Token(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// The shared request was not cancelled with the caller.
+	token, err := s.Token(context.Background())
+	require.NoError(t, err)
+	require.NotEmpty(t, token)
+	require.Equal(t, 1, srv.Requests())
+}
+
+// newDownstream starts a server accepting only requests with a current token
+// of srv, echoing their bodies.
+func newDownstream(t *testing.T, srv *oauth2test.Server) *httptest.Server {
+	t.Helper()
+	ds := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !srv.Valid(r, "") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = io.Copy(w, r.Body)
+	}))
+	t.Cleanup(ds.Close)
+	return ds
+}
+
+func TestTransport(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		body     func() io.Reader
+		status   int
+		requests int
+	}{
+		{"no body", func() io.Reader { return nil }, http.StatusOK, 2},
+		{"replayable body", func() io.Reader { return strings.NewReader("pet") }, http.StatusOK, 2},
+		// A body that cannot be sent again is not retried.
+		{"one-off body", func() io.Reader { return io.MultiReader(strings.NewReader("pet")) }, http.StatusUnauthorized, 1},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			srv := newServer(t)
+			ds := newDownstream(t, srv)
+			client := &http.Client{Transport: NewTransport(newSource(t, srv, Config{}), nil)}
+
+			do := func() *http.Response {
+				req, err := http.NewRequest(http.MethodPost, ds.URL, tt.body())
+				require.NoError(t, err)
+				resp, err := client.Do(req)
+				require.NoError(t, err)
+				t.Cleanup(func() { resp.Body.Close() })
+				return resp
+			}
+			require.Equal(t, http.StatusOK, do().StatusCode)
+			require.Equal(t, 1, srv.Requests())
+
+			// The cached token is rejected, so it is replaced and the request retried.
+			srv.RevokeAll()
+			resp := do()
+			require.Equal(t, tt.status, resp.StatusCode)
+			require.Equal(t, tt.requests, srv.Requests())
+			if tt.status == http.StatusOK && tt.body() != nil {
+				body, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+				require.Equal(t, "pet", string(body))
+			}
+		})
+	}
+}
+
+func TestTransportTokenFailure(t *testing.T) {
+	t.Parallel()
+
+	srv := newServer(t)
+	ds := newDownstream(t, srv)
+	s := newSource(t, srv, Config{ClientSecret: config.NewSensitiveString("wrong")})
+	client := &http.Client{Transport: NewTransport(s, nil)}
+	_, err := client.Get(ds.URL)
+	require.ErrorContains(t, err, "invalid_client")
+}
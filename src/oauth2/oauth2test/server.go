@@ -0,0 +1,159 @@
+// Package oauth2test provides a fake OAuth2 token server, so that downstream
+// clients using client credentials can be tested without a real one.
+package oauth2test
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server issues opaque bearer tokens to a single client and records how many
+// tokens it issued. Use Valid in a fake downstream to check the tokens it receives.
+type Server struct {
+	*httptest.Server
+
+	ClientID     string
+	ClientSecret string
+
+	mu        sync.Mutex
+	expiresIn time.Duration
+	delay     time.Duration
+	tokens    map[string]token
+	requests  int
+}
+
+type token struct {
+	scope   string
+	expires time.Time
+}
+
+// NewServer starts a token server accepting the given client credentials,
+// sent either with basic authentication or in the form. Tokens are valid for
+// an hour unless changed with SetExpiresIn. Call Close when done.
+func NewServer(clientID, clientSecret string) *Server {
+	s := &Server{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		expiresIn:    time.Hour,
+		tokens:       make(map[string]token),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveToken))
+	return s
+}
+
+// TokenURL is the URL to configure as the tokenURL of the client.
+func (s *Server) TokenURL() string {
+	return s.URL + "/token"
+}
+
+// SetExpiresIn changes the lifetime of tokens issued from now on.
+func (s *Server) SetExpiresIn(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expiresIn = d
+}
+
+// SetDelay makes the server wait before answering each token request, to
+// exercise concurrent refreshes.
+func (s *Server) SetDelay(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delay = d
+}
+
+// Requests returns the number of token requests received.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// RevokeAll invalidates every token issued so far, as if the authorization
+// server had rotated its keys.
+func (s *Server) RevokeAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = make(map[string]token)
+}
+
+// Valid reports whether the Authorization header of req carries a current
+// token granted the given scope, or any token if scope is empty.
+func (s *Server) Valid(req *http.Request, scope string) bool {
+	bearer := req.Header.Get("Authorization")
+	if !strings.HasPrefix(bearer, "Bearer ") {
+		return false
+	}
+	s.mu.Lock()
+	t, ok := s.tokens[strings.TrimPrefix(bearer, "Bearer ")]
+	s.mu.Unlock()
+	if !ok || !time.Now().Before(t.expires) {
+		return false
+	}
+	return scope == "" || contains(strings.Fields(t.scope), scope)
+}
+
+func (s *Server) serveToken(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests++
+	delay, expiresIn := s.delay, s.expiresIn
+	s.mu.Unlock()
+	time.Sleep(delay)
+
+	if r.Method != http.MethodPost || r.URL.Path != "/token" {
+		http.NotFound(w, r)
+		return
+	}
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "client_credentials" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+	id, secret, ok := r.BasicAuth()
+	if ok {
+		// Basic credentials are form-encoded first (RFC 6749 section 2.3.1).
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		id, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if id != s.ClientID || secret != s.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	access := hex.EncodeToString(b)
+	scope := r.PostForm.Get("scope")
+	s.mu.Lock()
+	s.tokens[access] = token{scope: scope, expires: time.Now().Add(expiresIn)}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": access,
+		"token_type":   "Bearer",
+		"expires_in":   int64(expiresIn / time.Second),
+		"scope":        scope,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func contains(values []string, want string) bool {
+	for _, v := range values {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
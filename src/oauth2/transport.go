@@ -0,0 +1,70 @@
+package oauth2
+
+import (
+	"io"
+	"net/http"
+)
+
+// Transport adds a bearer token from Source to every request sent through
+// Base. A request rejected with 401 is retried once with a new token, if its
+// body can be sent again.
+type Transport struct {
+	Source *TokenSource
+	Base   http.RoundTripper
+}
+
+// NewTransport creates a Transport sending requests through base, or
+// http.DefaultTransport if base is nil.
+func NewTransport(source *TokenSource, base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{Source: source, Base: base}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.Source.Token(req.Context())
+	if err != nil {
+		closeBody(req)
+		return nil, err
+	}
+	resp, err := t.Base.RoundTrip(withToken(req, token))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return resp, nil
+	}
+
+	t.Source.Invalidate(token)
+	token, err = t.Source.Token(req.Context())
+	if err != nil {
+		// Report the downstream's 401 rather than the failed refresh.
+		return resp, nil
+	}
+	retry := withToken(req, token)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+		retry.Body = body
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	return t.Base.RoundTrip(retry)
+}
+
+// withToken returns a copy of req carrying token, as a RoundTripper must not
+// modify the request it is given.
+func withToken(req *http.Request, token string) *http.Request {
+	r := req.Clone(req.Context())
+	r.Header.Set("Authorization", "Bearer "+token)
+	return r
+}
+
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
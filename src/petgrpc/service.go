@@ -0,0 +1,157 @@
+// Package petgrpc serves the ServiceInterface of Petdemo over gRPC, calling
+// the same handlers as the generated REST ServiceHandler:
+//
+//	PetService.GetPet     ->  ServiceInterface.GetPetList  (GET /pet)
+//	PetService.WatchPets  ->  ServiceInterface.GetPetList  at each interval
+//
+// Each RPC runs like its REST endpoint: the incoming metadata is available
+// as the request headers, downstream calls are bounded by the downstream
+// contextTimeout, panics are recovered and the response is validated. Errors
+// are returned with the gRPC code matching their common.Kind, see Status.
+package petgrpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/anz-bank/sysl-go/common"
+	"github.com/anz-bank/sysl-go/config"
+	"github.com/anz-bank/sysl-go/core"
+	"github.com/anz-bank/sysl-go/validator"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	petdemopb "github.com/anz-bank/sysl-go-demo/internal/gen/pb/petdemo"
+	"github.com/anz-bank/sysl-go-demo/internal/gen/pkg/servers/Petdemo"
+	"github.com/anz-bank/sysl-go-demo/internal/gen/pkg/servers/Petdemo/petstore"
+)
+
+// defaultDownstreamTimeout is the downstream contextTimeout used by the
+// generated code when no downstream config is set.
+const defaultDownstreamTimeout = 30 * time.Second
+
+// Endpoints maps the full names of the RPCs to the REST endpoints they serve,
+// so that they are authorized by the same rules (see
+// auth.Authorizer.GRPCServerOptions).
+var Endpoints = map[string]string{
+	"/petdemo.PetService/GetPet":    "GET /pet",
+	"/petdemo.PetService/WatchPets": "GET /pet",
+}
+
+// Service implements petdemopb.PetServiceServer. It implements
+// handlerinitialiser.GrpcHandlerInitialiser to register itself.
+type Service struct {
+	petdemopb.UnimplementedPetServiceServer
+
+	serviceInterface  *petdemo.ServiceInterface
+	petstore          petstore.Service
+	downstreamTimeout time.Duration
+	streams           *streams
+}
+
+// New creates a Service calling the handlers of serviceInterface, with the
+// downstream clients built from the config of ctx as the generated code
+// builds them. Streams are limited by streamsConfig, see LoadStreams.
+func New(ctx context.Context, hooks *core.Hooks, serviceInterface *petdemo.ServiceInterface, streamsConfig StreamsConfig) (*Service, error) {
+	downstream, ok := config.GetDefaultConfig(ctx).GenCode.Downstream.(*petdemo.DownstreamConfig)
+	if !ok || downstream == nil {
+		downstream = &petdemo.DownstreamConfig{ContextTimeout: defaultDownstreamTimeout}
+	}
+	client, url, err := core.BuildDownstreamHTTPClient(ctx, "petstore", hooks, &downstream.Petstore)
+	if err != nil {
+		return nil, fmt.Errorf("petgrpc: %w", err)
+	}
+	return &Service{
+		serviceInterface:  serviceInterface,
+		petstore:          &petstore.Client{Client: client, URL: url, Headers: downstream.Petstore.Headers},
+		downstreamTimeout: downstream.ContextTimeout,
+		streams:           newStreams(streamsConfig),
+	}, nil
+}
+
+// RegisterServer registers the service with server.
+func (s *Service) RegisterServer(_ context.Context, server *grpc.Server) {
+	petdemopb.RegisterPetServiceServer(server, s)
+}
+
+// GetPet serves GET /pet.
+func (s *Service) GetPet(ctx context.Context, _ *petdemopb.GetPetRequest) (*petdemopb.Pet, error) {
+	ctx, cancel := s.requestContext(ctx)
+	defer cancel()
+	pet, err := s.getPet(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sendHeader(ctx)
+	return pet, nil
+}
+
+// getPet calls the GetPetList handler with a context prepared by requestContext.
+func (s *Service) getPet(ctx context.Context) (resp *petdemopb.Pet, err error) {
+	if s.serviceInterface.GetPetList == nil {
+		return nil, status.Error(codes.Unimplemented, "not implemented")
+	}
+	defer recoverPanic(ctx, &err)
+
+	client := petdemo.GetPetListClient{
+		PetstoreGetPetList: s.petstore.GetPetList,
+	}
+	pet, err := s.serviceInterface.GetPetList(ctx, &petdemo.GetPetListRequest{}, client)
+	if err != nil {
+		return nil, Status(ctx, common.InternalError, "Handler error", err)
+	}
+	if err := validator.Validate(pet); err != nil {
+		return nil, Status(ctx, common.InternalError, "Invalid response", err)
+	}
+	return &petdemopb.Pet{Breed: pet.Breed}, nil
+}
+
+// requestContext prepares the context of a call as the generated REST
+// handlers do, with the incoming metadata as the request headers.
+func (s *Service) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	header := http.Header{}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for key, values := range md {
+		// Skip pseudo-headers such as :authority.
+		if strings.HasPrefix(key, ":") {
+			continue
+		}
+		for _, value := range values {
+			header.Add(key, value)
+		}
+	}
+	ctx = common.RequestHeaderToContext(ctx, header)
+	ctx = common.RespHeaderAndStatusToContext(ctx, make(http.Header), 0)
+	return context.WithTimeout(ctx, s.downstreamTimeout)
+}
+
+// sendHeader sends the response headers set by the handler as metadata.
+func sendHeader(ctx context.Context) {
+	header, _ := common.RespHeaderAndStatusFromContext(ctx)
+	// The content type of gRPC responses is set by gRPC.
+	header.Del("Content-Type")
+	if len(header) == 0 {
+		return
+	}
+	md := metadata.MD{}
+	for key, values := range header {
+		md.Append(key, values...)
+	}
+	_ = grpc.SetHeader(ctx, md)
+}
+
+// recoverPanic turns a panic of a handler into an internal error.
+func recoverPanic(ctx context.Context, err *error) {
+	if rec := recover(); rec != nil {
+		cause, ok := rec.(error)
+		if !ok {
+			cause = fmt.Errorf("Unknown error: %v", rec)
+		}
+		*err = Status(ctx, common.InternalError, "Unexpected panic", cause)
+	}
+}
@@ -0,0 +1,187 @@
+package petgrpc
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/anz-bank/sysl-go/common"
+	"github.com/anz-bank/sysl-go/config"
+	"github.com/anz-bank/sysl-go/core"
+	"github.com/anz-bank/sysl-go/log"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	petdemopb "github.com/anz-bank/sysl-go-demo/internal/gen/pb/petdemo"
+	"github.com/anz-bank/sysl-go-demo/internal/gen/pkg/servers/Petdemo"
+	"github.com/anz-bank/sysl-go-demo/src/handlers"
+)
+
+// testStreams are the stream limits of the tests, short enough for streams
+// to send several pets within a test.
+var testStreams = StreamsConfig{MaxStreams: 2, MinInterval: 10 * time.Millisecond, MaxDuration: -1}
+
+// mockPetstore serves a mocked petstore downstream.
+func mockPetstore(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// respond returns a petstore handler responding with status and body.
+func respond(status int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}
+}
+
+// newService creates a Service serving the handlers of Petdemo, calling the
+// petstore downstream at petstoreURL within downstreamTimeout.
+func newService(t *testing.T, petstoreURL string, downstreamTimeout time.Duration, streams StreamsConfig) *Service {
+	t.Helper()
+	ctx := config.PutDefaultConfig(context.Background(), &config.DefaultConfig{GenCode: config.GenCodeConfig{
+		Downstream: &petdemo.DownstreamConfig{
+			ContextTimeout: downstreamTimeout,
+			Petstore:       config.CommonDownstreamData{ServiceURL: petstoreURL, ClientTimeout: time.Second},
+		},
+	}})
+	s, err := New(log.PutLogger(ctx, log.NewDefaultLogger()), &core.Hooks{}, &petdemo.ServiceInterface{
+		GetPetList: (&handlers.Pet{}).GetRandomPetPicListRead,
+	}, streams)
+	require.NoError(t, err)
+	return s
+}
+
+// dial serves s over an in-memory connection and returns a client of it.
+func dial(t *testing.T, s *Service) petdemopb.PetServiceClient {
+	t.Helper()
+	logger := log.NewDefaultLogger()
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			return handler(log.PutLogger(ctx, logger), req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			return handler(srv, &loggerStream{ServerStream: ss, ctx: log.PutLogger(ss.Context(), logger)})
+		}),
+	)
+	s.RegisterServer(context.Background(), server)
+	lis := bufconn.Listen(1 << 20)
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return petdemopb.NewPetServiceClient(conn)
+}
+
+type loggerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *loggerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestGetPet(t *testing.T) {
+	t.Parallel()
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	tests := []struct {
+		name     string
+		petstore string
+		code     codes.Code
+		breed    string
+	}{
+		{"ok", mockPetstore(t, respond(http.StatusOK, "husky")).URL, codes.OK, "husky"},
+		{"error response", mockPetstore(t, respond(http.StatusServiceUnavailable, `{"code": 503, "message": "down"}`)).URL, codes.Internal, ""},
+		{"unavailable", closed.URL, codes.Unavailable, ""},
+		{"timeout", mockPetstore(t, func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		}).URL, codes.DeadlineExceeded, ""},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			client := dial(t, newService(t, tt.petstore, 100*time.Millisecond, testStreams))
+			pet, err := client.GetPet(context.Background(), &petdemopb.GetPetRequest{})
+			require.Equal(t, tt.code, status.Code(err), err)
+			require.Equal(t, tt.breed, pet.GetBreed())
+		})
+	}
+}
+
+func TestGetPetHandler(t *testing.T) {
+	t.Parallel()
+
+	s := newService(t, "http://petstore", time.Second, testStreams)
+	client := dial(t, s)
+	ctx := context.Background()
+
+	s.serviceInterface = &petdemo.ServiceInterface{}
+	_, err := client.GetPet(ctx, &petdemopb.GetPetRequest{})
+	require.Equal(t, codes.Unimplemented, status.Code(err))
+
+	s.serviceInterface = &petdemo.ServiceInterface{GetPetList: func(context.Context, *petdemo.GetPetListRequest, petdemo.GetPetListClient) (*petdemo.Pet, error) {
+		panic("boom")
+	}}
+	_, err = client.GetPet(ctx, &petdemopb.GetPetRequest{})
+	require.Equal(t, codes.Internal, status.Code(err))
+
+	// The incoming metadata are the request headers of the handler, and its
+	// response headers are sent back as metadata.
+	var received http.Header
+	s.serviceInterface = &petdemo.ServiceInterface{GetPetList: func(ctx context.Context, _ *petdemo.GetPetListRequest, _ petdemo.GetPetListClient) (*petdemo.Pet, error) {
+		received = common.RequestHeaderFromContext(ctx)
+		_ = common.AppendToResponseHeader(ctx, "Content-Type", "application/json")
+		_ = common.AppendToResponseHeader(ctx, "X-Pet-Source", "petstore")
+		return &petdemo.Pet{Breed: "corgi"}, nil
+	}}
+	var header metadata.MD
+	ctx = metadata.AppendToOutgoingContext(ctx, "x-request-id", "42", "x-tag", "a", "x-tag", "b")
+	pet, err := client.GetPet(ctx, &petdemopb.GetPetRequest{}, grpc.Header(&header))
+	require.NoError(t, err)
+	require.Equal(t, "corgi", pet.GetBreed())
+	require.Equal(t, "42", received.Get("X-Request-Id"))
+	require.Equal(t, []string{"a", "b"}, received.Values("X-Tag"))
+	for key := range received {
+		require.NotEqual(t, ':', key[0], "pseudo-header %s", key)
+	}
+	require.Equal(t, []string{"petstore"}, header.Get("x-pet-source"))
+	require.Equal(t, []string{"application/grpc"}, header.Get("content-type"), "set by gRPC")
+}
+
+func TestRequestContext(t *testing.T) {
+	t.Parallel()
+
+	s := &Service{downstreamTimeout: time.Minute}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.MD{
+		":authority":    {"localhost"},
+		"authorization": {"Bearer token"},
+	})
+	ctx, cancel := s.requestContext(ctx)
+	defer cancel()
+	require.Equal(t, http.Header{"Authorization": {"Bearer token"}}, common.RequestHeaderFromContext(ctx))
+	header, _ := common.RespHeaderAndStatusFromContext(ctx)
+	require.NotNil(t, header)
+	deadline, ok := ctx.Deadline()
+	require.True(t, ok)
+	require.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+}
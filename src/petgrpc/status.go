@@ -0,0 +1,49 @@
+package petgrpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/anz-bank/sysl-go/common"
+	"github.com/anz-bank/sysl-go/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// kindCodes maps the kinds of errors to the gRPC codes matching the HTTP
+// statuses of common.MapError. Other kinds are returned as codes.Internal,
+// as they are by the REST endpoints.
+var kindCodes = map[common.Kind]codes.Code{
+	common.UnknownError:               codes.Unknown,
+	common.BadRequestError:            codes.InvalidArgument,
+	common.InternalError:              codes.Internal,
+	common.UnauthorizedError:          codes.Unauthenticated,
+	common.DownstreamUnavailableError: codes.Unavailable,
+	common.DownstreamTimeoutError:     codes.DeadlineExceeded,
+}
+
+// Status returns the gRPC status error for err, classified as the generated
+// REST handlers classify it: errors with a common.Kind keep it, other errors
+// are of the given kind unless the context has timed out. Like the REST
+// error responses, the status message is the description of the kind, not
+// the error itself, which is logged. gRPC status errors are returned as is.
+func Status(ctx context.Context, kind common.Kind, message string, err error) error {
+	if _, ok := err.(interface{ GRPCStatus() *status.Status }); ok {
+		return err
+	}
+	if errors.Is(err, context.Canceled) {
+		return status.Error(codes.Canceled, context.Canceled.Error())
+	}
+	err = common.CreateError(ctx, kind, message, err)
+	log.Error(ctx, err, "error handled")
+
+	kinder, ok := err.(common.ErrorKinder)
+	if !ok {
+		return status.Error(codes.Unknown, common.UnknownError.String())
+	}
+	code, ok := kindCodes[kinder.ErrorKind()]
+	if !ok {
+		code = codes.Internal
+	}
+	return status.Error(code, kinder.ErrorKind().String())
+}
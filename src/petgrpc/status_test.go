@@ -0,0 +1,82 @@
+package petgrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/anz-bank/sysl-go/common"
+	"github.com/anz-bank/sysl-go/log"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestStatus(t *testing.T) {
+	t.Parallel()
+
+	ctx := log.PutLogger(context.Background(), log.NewDefaultLogger())
+	timedOut, cancel := context.WithTimeout(ctx, 0)
+	defer cancel()
+	<-timedOut.Done()
+	cause := errors.New("petstore is down")
+	kindOf := func(kind common.Kind) error {
+		return &common.ServerError{Kind: kind, Message: "downstream", Cause: cause}
+	}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		kind common.Kind
+		err  error
+		code codes.Code
+	}{
+		{"unknown", ctx, common.InternalError, kindOf(common.UnknownError), codes.Unknown},
+		{"bad request", ctx, common.InternalError, kindOf(common.BadRequestError), codes.InvalidArgument},
+		{"internal", ctx, common.BadRequestError, kindOf(common.InternalError), codes.Internal},
+		{"unauthorized", ctx, common.InternalError, kindOf(common.UnauthorizedError), codes.Unauthenticated},
+		{"downstream unavailable", ctx, common.InternalError, kindOf(common.DownstreamUnavailableError), codes.Unavailable},
+		{"downstream timeout", ctx, common.InternalError, kindOf(common.DownstreamTimeoutError), codes.DeadlineExceeded},
+		{"other kinds", ctx, common.InternalError, kindOf(common.DownstreamResponseError), codes.Internal},
+		{"kind of the call", ctx, common.DownstreamUnavailableError, cause, codes.Unavailable},
+		{"timed out", timedOut, common.InternalError, cause, codes.DeadlineExceeded},
+		{"cancelled", ctx, common.InternalError, fmt.Errorf("calling petstore: %w", context.Canceled), codes.Canceled},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			s, ok := status.FromError(Status(tt.ctx, tt.kind, "Handler error", tt.err))
+			require.True(t, ok)
+			require.Equal(t, tt.code, s.Code())
+			require.NotContains(t, s.Message(), cause.Error(), "the cause is logged, not returned")
+		})
+	}
+
+	// gRPC status errors are returned as is.
+	notFound := status.Error(codes.NotFound, "no pet")
+	require.Equal(t, notFound, Status(ctx, common.InternalError, "Handler error", notFound))
+	require.Equal(t, common.DownstreamTimeoutError.String(), status.Convert(Status(ctx, common.InternalError, "Handler error", kindOf(common.DownstreamTimeoutError))).Message())
+}
+
+func TestRecoverPanic(t *testing.T) {
+	t.Parallel()
+
+	ctx := log.PutLogger(context.Background(), log.NewDefaultLogger())
+	call := func(rec interface{}) (err error) {
+		defer recoverPanic(ctx, &err)
+		panic(rec)
+	}
+	for _, rec := range []interface{}{"boom", errors.New("boom")} {
+		s := status.Convert(call(rec))
+		require.Equal(t, codes.Internal, s.Code(), rec)
+		require.Equal(t, common.InternalError.String(), s.Message(), rec)
+	}
+
+	err := func() (err error) {
+		defer recoverPanic(ctx, &err)
+		return nil
+	}()
+	require.NoError(t, err)
+}
@@ -0,0 +1,151 @@
+package petgrpc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/anz-bank/sysl-go/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	petdemopb "github.com/anz-bank/sysl-go-demo/internal/gen/pb/petdemo"
+	"github.com/anz-bank/sysl-go-demo/src/configext"
+)
+
+// StreamsExtension is the config extension holding the limits of the
+// streaming RPCs.
+const StreamsExtension = "genCode.upstream.grpc.streams"
+
+// StreamsConfig limits the streaming RPCs, found under
+// genCode.upstream.grpc.streams.
+type StreamsConfig struct {
+	// MaxStreams limits the streams open at once on the server, 100 by
+	// default. Further calls fail with RESOURCE_EXHAUSTED.
+	MaxStreams int `yaml:"maxStreams" mapstructure:"maxStreams"`
+	// MinInterval is the shortest interval clients can ask for between
+	// messages, and the default interval, 1s by default.
+	MinInterval time.Duration `yaml:"minInterval" mapstructure:"minInterval"`
+	// MaxDuration ends streams with OK once they have been open that long,
+	// so that clients reconnect and spread over the instances. 1h by
+	// default, -1 for no limit.
+	MaxDuration time.Duration `yaml:"maxDuration" mapstructure:"maxDuration"`
+}
+
+// LoadStreams decodes the stream limits found in ext, applying the defaults.
+func LoadStreams(ext *configext.Extensions) (StreamsConfig, error) {
+	cfg := StreamsConfig{MaxStreams: 100, MinInterval: time.Second, MaxDuration: time.Hour}
+	found := ext.Get(StreamsExtension)
+	if len(found) == 0 {
+		return cfg, nil
+	}
+	if err := found[0].Decode(&cfg); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxStreams <= 0 || cfg.MinInterval <= 0 || cfg.MaxDuration == 0 || cfg.MaxDuration < -1 {
+		return cfg, fmt.Errorf("%s: maxStreams, minInterval and maxDuration must be positive, or maxDuration -1 for no limit", found[0].Path)
+	}
+	return cfg, nil
+}
+
+// streams tracks the open streams, to limit them and end them when the
+// server shuts down.
+type streams struct {
+	cfg StreamsConfig
+
+	mu       sync.Mutex
+	open     int
+	draining chan struct{}
+	drained  bool
+}
+
+func newStreams(cfg StreamsConfig) *streams {
+	return &streams{cfg: cfg, draining: make(chan struct{})}
+}
+
+// acquire reserves a stream, returning false if the limit is reached or the
+// server is shutting down.
+func (s *streams) acquire() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.drained || s.open >= s.cfg.MaxStreams {
+		return false
+	}
+	s.open++
+	return true
+}
+
+func (s *streams) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.open--
+}
+
+// Drain ends the open streams and refuses new ones, so that a graceful stop
+// of the server does not wait for streams that never end. It implements
+// server.GRPCDrainer.
+func (s *Service) Drain() {
+	s.streams.mu.Lock()
+	defer s.streams.mu.Unlock()
+	if !s.streams.drained {
+		s.streams.drained = true
+		close(s.streams.draining)
+	}
+}
+
+// WatchPets sends a pet at each interval. The next pet is requested only once
+// the previous one has been sent, which blocks while the client is not
+// reading, and the intervals missed meanwhile are skipped rather than queued.
+func (s *Service) WatchPets(req *petdemopb.WatchPetsRequest, stream petdemopb.PetService_WatchPetsServer) error {
+	interval := s.streams.cfg.MinInterval
+	if req.GetInterval() != nil {
+		if err := req.GetInterval().CheckValid(); err != nil {
+			return status.Errorf(codes.InvalidArgument, "interval: %s", err)
+		}
+		interval = req.GetInterval().AsDuration()
+		if interval < s.streams.cfg.MinInterval {
+			return status.Errorf(codes.InvalidArgument, "interval must be at least %s", s.streams.cfg.MinInterval)
+		}
+	}
+	if !s.streams.acquire() {
+		return status.Error(codes.ResourceExhausted, "too many streams, retry later")
+	}
+	defer s.streams.release()
+
+	ctx := stream.Context()
+	var expired <-chan time.Time
+	if s.streams.cfg.MaxDuration > 0 {
+		timer := time.NewTimer(s.streams.cfg.MaxDuration)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		pet, err := s.watchedPet(ctx)
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(pet); err != nil {
+			return err
+		}
+		select {
+		case <-ticker.C:
+		case <-expired:
+			log.Infof(ctx, "ending stream after %s", s.streams.cfg.MaxDuration)
+			return nil
+		case <-s.streams.draining:
+			return status.Error(codes.Unavailable, "server is shutting down")
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+}
+
+// watchedPet gets the next pet of a stream, as a GetPet call would.
+func (s *Service) watchedPet(ctx context.Context) (*petdemopb.Pet, error) {
+	ctx, cancel := s.requestContext(ctx)
+	defer cancel()
+	return s.getPet(ctx)
+}
@@ -0,0 +1,143 @@
+package petgrpc
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	petdemopb "github.com/anz-bank/sysl-go-demo/internal/gen/pb/petdemo"
+	"github.com/anz-bank/sysl-go-demo/src/configext"
+)
+
+// watch opens a stream of pets and waits for the first one, so that the
+// stream holds its slot when watch returns.
+func watch(ctx context.Context, t *testing.T, client petdemopb.PetServiceClient, interval time.Duration) (petdemopb.PetService_WatchPetsClient, error) {
+	t.Helper()
+	stream, err := client.WatchPets(ctx, &petdemopb.WatchPetsRequest{Interval: durationpb.New(interval)})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	return stream, err
+}
+
+// openStreams returns the number of streams holding a slot.
+func openStreams(s *Service) int {
+	s.streams.mu.Lock()
+	defer s.streams.mu.Unlock()
+	return s.streams.open
+}
+
+func TestWatchPets(t *testing.T) {
+	t.Parallel()
+
+	petstore := mockPetstore(t, respond(http.StatusOK, "husky")).URL
+	s := newService(t, petstore, time.Second, testStreams)
+	client := dial(t, s)
+	ctx := context.Background()
+
+	_, err := watch(ctx, t, client, time.Millisecond)
+	require.Equal(t, codes.InvalidArgument, status.Code(err), err)
+	require.Contains(t, err.Error(), "interval must be at least 10ms")
+	_, err = watch(ctx, t, client, -time.Second)
+	require.Equal(t, codes.InvalidArgument, status.Code(err), err)
+
+	first, cancelFirst := context.WithCancel(ctx)
+	defer cancelFirst()
+	stream, err := watch(first, t, client, 10*time.Millisecond)
+	require.NoError(t, err)
+	pet, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, "husky", pet.GetBreed())
+
+	second, cancelSecond := context.WithCancel(ctx)
+	defer cancelSecond()
+	_, err = watch(second, t, client, 10*time.Millisecond)
+	require.NoError(t, err)
+
+	_, err = watch(ctx, t, client, 10*time.Millisecond)
+	require.Equal(t, codes.ResourceExhausted, status.Code(err), err)
+
+	// Ending a stream frees its slot.
+	cancelFirst()
+	require.Eventually(t, func() bool { return openStreams(s) == 1 }, 5*time.Second, 10*time.Millisecond)
+	third, cancelThird := context.WithCancel(ctx)
+	defer cancelThird()
+	_, err = watch(third, t, client, 10*time.Millisecond)
+	require.NoError(t, err)
+}
+
+func TestWatchPetsMaxDuration(t *testing.T) {
+	t.Parallel()
+
+	petstore := mockPetstore(t, respond(http.StatusOK, "husky")).URL
+	limits := testStreams
+	limits.MaxDuration = 50 * time.Millisecond
+	s := newService(t, petstore, time.Second, limits)
+	client := dial(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := watch(ctx, t, client, 10*time.Millisecond)
+	require.NoError(t, err)
+	for err == nil {
+		_, err = stream.Recv()
+	}
+	require.Equal(t, io.EOF, err, "ended with OK")
+	require.Eventually(t, func() bool { return openStreams(s) == 0 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestDrain(t *testing.T) {
+	t.Parallel()
+
+	petstore := mockPetstore(t, respond(http.StatusOK, "husky")).URL
+	s := newService(t, petstore, time.Second, testStreams)
+	client := dial(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := watch(ctx, t, client, time.Hour)
+	require.NoError(t, err)
+
+	s.Drain()
+	s.Drain()
+	_, err = stream.Recv()
+	require.Equal(t, codes.Unavailable, status.Code(err), err)
+	_, err = watch(ctx, t, client, 10*time.Millisecond)
+	require.Equal(t, codes.ResourceExhausted, status.Code(err), err)
+}
+
+func TestLoadStreams(t *testing.T) {
+	t.Parallel()
+
+	load := func(yaml string) (StreamsConfig, error) {
+		_, ext, err := configext.Split([]byte(yaml), StreamsExtension)
+		require.NoError(t, err)
+		return LoadStreams(ext)
+	}
+	const prefix = "genCode:\n  upstream:\n    grpc:\n      streams:\n"
+
+	cfg, err := load("genCode:\n  upstream:\n    grpc:\n      port: 6062\n")
+	require.NoError(t, err)
+	require.Equal(t, StreamsConfig{MaxStreams: 100, MinInterval: time.Second, MaxDuration: time.Hour}, cfg)
+
+	cfg, err = load(prefix + "        maxStreams: 5\n        maxDuration: -1\n")
+	require.NoError(t, err)
+	require.Equal(t, StreamsConfig{MaxStreams: 5, MinInterval: time.Second, MaxDuration: -1}, cfg)
+
+	for _, invalid := range []string{
+		"maxStreams: 0",
+		"minInterval: 0s",
+		"maxDuration: 0s",
+		"maxDuration: -2",
+		"maxDuration: -1s",
+	} {
+		_, err = load(prefix + "        " + invalid + "\n")
+		require.EqualError(t, err, "genCode.upstream.grpc.streams: maxStreams, minInterval and maxDuration must be positive, or maxDuration -1 for no limit", invalid)
+	}
+}
@@ -0,0 +1,219 @@
+package scenario
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/anz-bank/sysl-go-demo/src/configext"
+)
+
+// Keys of a Call that send the response and complete it.
+const (
+	callResponse = "response"
+	callTimeout  = "timeout"
+)
+
+// mockResponse is the response of a mock call.
+type mockResponse struct {
+	Code    int               `yaml:"code"`
+	Headers map[string]string `yaml:"headers"`
+	Body    interface{}       `yaml:"body"`
+}
+
+// expectCall registers call on the generated mock of operation of downstream,
+// found by name in the Mocks field of srv.
+func expectCall(srv TestServer, downstream, operation string, call Call, expand func(string) string) error {
+	mocks := reflect.Indirect(reflect.ValueOf(srv)).FieldByName("Mocks")
+	if !mocks.IsValid() {
+		return fmt.Errorf("%T has no mocks", srv)
+	}
+	ds, err := fieldFold(mocks, downstream)
+	if err != nil {
+		return fmt.Errorf("downstream %s: %w", downstream, err)
+	}
+	if ds.IsNil() {
+		return fmt.Errorf("downstream %s is not mocked by an integration test server", downstream)
+	}
+	mock, err := fieldFold(ds.Elem(), operation)
+	if err != nil {
+		return fmt.Errorf("operation %s: %w", operation, err)
+	}
+
+	for _, key := range sortedKeys(call) {
+		if key == callResponse || key == callTimeout {
+			continue
+		}
+		method, err := expectation(mock, key)
+		if err != nil {
+			return err
+		}
+		arg, err := decode(expandValue(call[key], expand), method.Type().In(0))
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		method.Call([]reflect.Value{arg})
+	}
+
+	_, hasResponse := call[callResponse]
+	timeout, _ := call[callTimeout].(bool)
+	switch {
+	case hasResponse && timeout:
+		return fmt.Errorf("a call has either a %s or %s: true, not both", callResponse, callTimeout)
+	case timeout:
+		mock.MethodByName("Timeout").Call(nil)
+	case hasResponse:
+		var resp mockResponse
+		if err := remarshal(expandValue(call[callResponse], expand), &resp); err != nil {
+			return fmt.Errorf("%s: %w", callResponse, err)
+		}
+		if resp.Code == 0 {
+			resp.Code = 200
+		}
+		body, err := bodyBytes(resp.Body)
+		if err != nil {
+			return fmt.Errorf("%s.body: %w", callResponse, err)
+		}
+		mock.MethodByName("MockResponsePlain").Call([]reflect.Value{
+			reflect.ValueOf(resp.Code), reflect.ValueOf(resp.Headers), reflect.ValueOf(body),
+		})
+	default:
+		return fmt.Errorf("a call ends with a %s or %s: true", callResponse, callTimeout)
+	}
+	return nil
+}
+
+// expectation returns the Expect method of mock named by key, such as
+// ExpectHeaders for expectHeaders, or ExpectBodyPlain for expectBody.
+func expectation(mock reflect.Value, key string) (reflect.Value, error) {
+	if strings.HasPrefix(key, "expect") {
+		name := strings.ToUpper(key[:1]) + key[1:]
+		for _, candidate := range []string{name, name + "Plain"} {
+			if m := mock.MethodByName(candidate); m.IsValid() && m.Type().NumIn() == 1 && candidate != "Expect" {
+				return m, nil
+			}
+		}
+	}
+	var keys []string
+	for i := 0; i < mock.Type().NumMethod(); i++ {
+		name := mock.Type().Method(i).Name
+		if strings.HasPrefix(name, "Expect") && name != "Expect" {
+			keys = append(keys, strings.ToLower(name[:1])+strings.TrimSuffix(name[1:], "Plain"))
+		}
+	}
+	keys = append(keys, callResponse, callTimeout)
+	return reflect.Value{}, fmt.Errorf("unknown key %s, expected one of: %s", key, strings.Join(keys, ", "))
+}
+
+// fieldFold returns the field of the struct v matching name case-insensitively.
+func fieldFold(v reflect.Value, name string) (reflect.Value, error) {
+	v = reflect.Indirect(v)
+	var names []string
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if strings.EqualFold(field.Name, name) {
+			return v.Field(i), nil
+		}
+		names = append(names, strings.ToLower(field.Name[:1])+field.Name[1:])
+	}
+	return reflect.Value{}, fmt.Errorf("not found, expected one of: %s", strings.Join(names, ", "))
+}
+
+// decode converts a YAML value to a value of type typ.
+func decode(value interface{}, typ reflect.Type) (reflect.Value, error) {
+	if typ == reflect.TypeOf([]byte(nil)) {
+		body, err := bodyBytes(value)
+		return reflect.ValueOf(body), err
+	}
+	ptr := reflect.New(typ)
+	if err := remarshal(value, ptr.Interface()); err != nil {
+		return reflect.Value{}, err
+	}
+	return ptr.Elem(), nil
+}
+
+func remarshal(value, out interface{}) error {
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return yaml.UnmarshalStrict(data, out)
+}
+
+// bodyBytes returns a string body as is, and any other body as JSON.
+func bodyBytes(body interface{}) ([]byte, error) {
+	switch b := body.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []byte(b), nil
+	default:
+		return json.Marshal(configext.Normalize(b))
+	}
+}
+
+// expander returns a function replacing ${NAME} by vars[NAME], or else by
+// the environment variable NAME.
+func expander(vars map[string]string) func(string) string {
+	return func(s string) string {
+		return os.Expand(s, func(name string) string {
+			if v, ok := vars[name]; ok {
+				return v
+			}
+			return os.Getenv(name)
+		})
+	}
+}
+
+// expandValue expands the strings in a YAML value.
+func expandValue(value interface{}, expand func(string) string) interface{} {
+	switch v := value.(type) {
+	case string:
+		return expand(v)
+	case map[interface{}]interface{}:
+		m := make(map[interface{}]interface{}, len(v))
+		for key, item := range v {
+			m[key] = expandValue(item, expand)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[key] = expandValue(item, expand)
+		}
+		return m
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = expandValue(item, expand)
+		}
+		return items
+	default:
+		return v
+	}
+}
+
+func expandMap(m map[string]string, expand func(string) string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = expand(v)
+	}
+	return out
+}
+
+func sortedKeys(call Call) []string {
+	keys := make([]string, 0, len(call))
+	for k := range call {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
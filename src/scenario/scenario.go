@@ -0,0 +1,182 @@
+// Package scenario runs end-to-end test cases described in YAML files
+// against the generated test servers, so that cases can be added without
+// writing Go. Each file describes one inbound request, the calls expected by
+// the mocked downstreams and the expected response:
+//
+//	name: returns a pet
+//	request:
+//	  method: GET
+//	  path: /pet
+//	  headers:
+//	    Authorization: Bearer ${TOKEN}
+//	mocks:
+//	  petstore:
+//	    getPetList:
+//	      - expectHeadersDoNotExist: [Authorization]
+//	        response:
+//	          code: 200
+//	          headers: {Content-Type: application/json}
+//	          body: husky
+//	response:
+//	  code: 200
+//	  body: {breed: husky}
+//
+// Mock calls use the vocabulary of the generated mocks: each key is a method
+// of the mock such as expectHeaders or expectQueryParams, and each call ends
+// with a response or with timeout: true. ${NAME} in strings is replaced by
+// the vars given to Run, or else by the environment variable NAME.
+package scenario
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anz-bank/sysl-go/core"
+	"github.com/anz-bank/sysl-go/testutil/e2e"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+
+	"github.com/anz-bank/sysl-go-demo/src/golden"
+)
+
+// Scenario is a test case.
+type Scenario struct {
+	// Name names the subtest, by default the file name without extension.
+	Name        string  `yaml:"name"`
+	Description string  `yaml:"description"`
+	Request     Request `yaml:"request"`
+	// Mocks maps downstreams to operations to the calls they expect, in order.
+	Mocks    map[string]map[string][]Call `yaml:"mocks"`
+	Response Response                     `yaml:"response"`
+}
+
+// Request is the inbound request of a scenario.
+type Request struct {
+	Method  string            `yaml:"method"`
+	Path    string            `yaml:"path"`
+	Headers map[string]string `yaml:"headers"`
+	// Body is sent as is if it is a string, and as JSON otherwise.
+	Body interface{} `yaml:"body"`
+}
+
+// Response is the expected response of a scenario.
+type Response struct {
+	Code              int               `yaml:"code"`
+	Headers           map[string]string `yaml:"headers"`
+	HeadersExist      []string          `yaml:"headersExist"`
+	HeadersDoNotExist []string          `yaml:"headersDoNotExist"`
+	// Body is compared as JSON if the response is JSON, and as a string otherwise.
+	Body interface{} `yaml:"body"`
+	// Golden compares the body with a golden file instead (see golden).
+	Golden *Golden `yaml:"golden"`
+}
+
+// Golden compares a body with a golden file.
+type Golden struct {
+	Name   string   `yaml:"name"`
+	Ignore []string `yaml:"ignore"`
+}
+
+// Call is one expected call to a mocked downstream operation: a map of
+// methods of the generated mock to their arguments.
+type Call map[string]interface{}
+
+// TestServer is a server created by a generated NewTestServer.
+type TestServer interface {
+	GetE2eTester() *e2e.Tester
+	Close()
+}
+
+// Load reads the scenario at path.
+func Load(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s Scenario
+	if err := yaml.UnmarshalStrict(data, &s); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if s.Name == "" {
+		s.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if s.Request.Method == "" {
+		s.Request.Method = "GET"
+	}
+	return &s, nil
+}
+
+// Run runs every scenario file matching pattern, such as
+// testdata/scenarios/*.yaml, as a subtest of t against a server created by
+// newServer.
+func Run(t *testing.T, pattern string, newServer func(t *testing.T) TestServer, vars map[string]string) {
+	paths, err := filepath.Glob(pattern)
+	require.NoError(t, err)
+	require.NotEmpty(t, paths, "no scenarios match %s", pattern)
+	for _, path := range paths {
+		s, err := Load(path)
+		require.NoError(t, err)
+		t.Run(s.Name, func(t *testing.T) {
+			srv := newServer(t)
+			defer srv.Close()
+			s.Run(t, srv, vars)
+		})
+	}
+}
+
+// Run registers the expected mock calls of the scenario on srv, sends its
+// request and checks the response.
+func (s *Scenario) Run(t *testing.T, srv TestServer, vars map[string]string) {
+	expand := expander(vars)
+	for downstream, operations := range s.Mocks {
+		for operation, calls := range operations {
+			for i, call := range calls {
+				loc := fmt.Sprintf("mocks.%s.%s[%d]", downstream, operation, i)
+				require.NoError(t, expectCall(srv, downstream, operation, call, expand), loc)
+			}
+		}
+	}
+
+	tester := srv.GetE2eTester()
+	basePath := core.SelectBasePath("", tester.CfgBasePath())
+	if basePath == "/" {
+		basePath = ""
+	}
+	tc := e2e.TestCall2{
+		Method:  s.Request.Method,
+		URL:     basePath + expand(s.Request.Path),
+		Headers: expandMap(s.Request.Headers, expand),
+	}
+	if s.Request.Body != nil {
+		body, err := bodyBytes(expandValue(s.Request.Body, expand))
+		require.NoError(t, err, "request.body")
+		tc.Body = body
+	}
+
+	resp := s.Response
+	if resp.Code != 0 {
+		code := resp.Code
+		tc.ExpectedCode = &code
+	}
+	if resp.Headers != nil {
+		tc.TestRespFns = append(tc.TestRespFns, e2e.ExpectResponseHeaders(expandMap(resp.Headers, expand)))
+	}
+	if resp.HeadersExist != nil {
+		tc.TestRespFns = append(tc.TestRespFns, e2e.ExpectResponseHeadersExist(resp.HeadersExist))
+	}
+	if resp.HeadersDoNotExist != nil {
+		tc.TestRespFns = append(tc.TestRespFns, e2e.ExpectResponseHeadersDoNotExist(resp.HeadersDoNotExist))
+	}
+	if resp.Body != nil {
+		body, err := bodyBytes(expandValue(resp.Body, expand))
+		require.NoError(t, err, "response.body")
+		tc.ExpectedBody = body
+	}
+	if resp.Golden != nil {
+		tc.TestBodyFn = golden.ResponseBody(resp.Golden.Name, golden.Ignore(resp.Golden.Ignore...))
+	}
+	tester.Do2(tc)
+}
@@ -0,0 +1,255 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/anz-bank/sysl-go/config"
+	"github.com/anz-bank/sysl-go/core"
+	"github.com/anz-bank/sysl-go/handlerinitialiser"
+	"github.com/anz-bank/sysl-go/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/reflection"
+
+	"github.com/anz-bank/sysl-go-demo/src/configext"
+)
+
+// GRPCKeepaliveExtension is the config extension holding the keepalive
+// settings of the public gRPC server, which sysl-go's GRPCServerConfig has
+// no settings for.
+const GRPCKeepaliveExtension = "genCode.upstream.grpc.keepalive"
+
+// GRPCKeepalive configures the HTTP/2 pings of the public gRPC server, found
+// under genCode.upstream.grpc.keepalive. Settings left empty keep the gRPC
+// defaults.
+type GRPCKeepalive struct {
+	// Time is how long a connection can be idle before the server pings the
+	// client, 2h by default. Streams that are quiet for longer than the idle
+	// timeout of proxies on the way need a shorter time.
+	Time time.Duration `yaml:"time" mapstructure:"time"`
+	// Timeout is how long the server waits for the response to a ping before
+	// closing the connection, 20s by default.
+	Timeout time.Duration `yaml:"timeout" mapstructure:"timeout"`
+	// MinTime is the shortest interval at which clients may ping the server,
+	// 5m by default. Clients pinging more often are disconnected.
+	MinTime time.Duration `yaml:"minTime" mapstructure:"minTime"`
+	// PermitWithoutStream lets clients ping when they have no calls in progress.
+	PermitWithoutStream bool `yaml:"permitWithoutStream" mapstructure:"permitWithoutStream"`
+}
+
+// LoadGRPCKeepalive decodes the keepalive settings found in ext. The zero
+// value is returned if there are none, keeping the gRPC defaults.
+func LoadGRPCKeepalive(ext *configext.Extensions) (GRPCKeepalive, error) {
+	var cfg GRPCKeepalive
+	found := ext.Get(GRPCKeepaliveExtension)
+	if len(found) == 0 {
+		return cfg, nil
+	}
+	if err := found[0].Decode(&cfg); err != nil {
+		return cfg, err
+	}
+	if cfg.Time < 0 || cfg.Timeout < 0 || cfg.MinTime < 0 {
+		return cfg, fmt.Errorf("%s: time, timeout and minTime must not be negative", found[0].Path)
+	}
+	return cfg, nil
+}
+
+func (k GRPCKeepalive) serverOptions() []grpc.ServerOption {
+	var opts []grpc.ServerOption
+	if k.Time > 0 || k.Timeout > 0 {
+		opts = append(opts, grpc.KeepaliveParams(keepalive.ServerParameters{Time: k.Time, Timeout: k.Timeout}))
+	}
+	if k.MinTime > 0 || k.PermitWithoutStream {
+		opts = append(opts, grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{MinTime: k.MinTime, PermitWithoutStream: k.PermitWithoutStream}))
+	}
+	return opts
+}
+
+// GRPCHandler creates a handler of the public gRPC server. It is called
+// when the server is built, once the hooks are final and ctx carries the
+// config and logger of the service.
+type GRPCHandler func(ctx context.Context, hooks *core.Hooks) (handlerinitialiser.GrpcHandlerInitialiser, error)
+
+// GRPCDrainer is implemented by gRPC handlers with calls that do not end by
+// themselves, such as streams. Drain is called when the server is stopped
+// gracefully, before waiting for the calls in progress, and must make them
+// end.
+type GRPCDrainer interface {
+	Drain()
+}
+
+// GRPCBuilder creates the public gRPC server of the service. sysl-go only
+// serves the gRPC endpoints described in the sysl spec, of which Petdemo has
+// none, so the server is started next to the public HTTP server instead (see
+// Builder.GRPC). It is configured by genCode.upstream.grpc like the sysl-go
+// one, and is only started if a port is set.
+//
+// sysl-go still starts its own server on genCode.upstream.grpc when it has
+// handlers to serve, which is the case with library.health, as it serves
+// the gRPC health service. Both servers cannot listen on the same port, so
+// Build rejects configs enabling both.
+type GRPCBuilder struct {
+	// Keepalive configures the HTTP/2 pings of the server.
+	Keepalive GRPCKeepalive
+	// ConfigureTLS, if set, replaces the TLS config built by sysl-go from
+	// genCode.upstream.grpc.tls, as Builder.ConfigureTLS does for the HTTP server.
+	ConfigureTLS func(*tls.Config) *tls.Config
+
+	hooks    *core.Hooks
+	handlers []GRPCHandler
+}
+
+// NewGRPCBuilder creates a GRPCBuilder serving the given handlers. The
+// server options are resolved from hooks as sysl-go does, see
+// core.ResolveGrpcServerOptions.
+func NewGRPCBuilder(hooks *core.Hooks, handlers ...GRPCHandler) *GRPCBuilder {
+	return &GRPCBuilder{hooks: hooks, handlers: handlers}
+}
+
+// Build creates the server, or returns nil if no port is configured.
+func (b *GRPCBuilder) Build(ctx context.Context) (core.StoppableServer, error) {
+	cfg := &config.GetDefaultConfig(ctx).GenCode.Upstream.GRPC
+	if cfg.Port == 0 {
+		return nil, nil
+	}
+	if config.GetDefaultConfig(ctx).Library.Health {
+		return nil, fmt.Errorf("server: library.health starts the sysl-go gRPC server on genCode.upstream.grpc, which cannot be combined with the gRPC server of the service")
+	}
+	opts, err := core.ResolveGrpcServerOptions(ctx, b.hooks, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("server: gRPC options: %w", err)
+	}
+	if cfg.TLS != nil && b.ConfigureTLS != nil {
+		tlsConfig, err := config.MakeTLSConfig(ctx, cfg.TLS)
+		if err != nil {
+			return nil, fmt.Errorf("server: gRPC TLS: %w", err)
+		}
+		// Replaces the credentials of the sysl-go options, as the last
+		// grpc.Creds option applies.
+		opts = append(opts, grpc.Creds(credentials.NewTLS(b.ConfigureTLS(tlsConfig))))
+	}
+	// sysl-go only puts its logger in the context of unary calls.
+	opts = append([]grpc.ServerOption{grpc.ChainStreamInterceptor(streamLogger(log.GetLogger(ctx)))}, opts...)
+	server := grpc.NewServer(append(opts, b.Keepalive.serverOptions()...)...)
+	if cfg.EnableReflection {
+		reflection.Register(server)
+	}
+	var drainers []GRPCDrainer
+	for _, newHandler := range b.handlers {
+		h, err := newHandler(ctx, b.hooks)
+		if err != nil {
+			return nil, err
+		}
+		h.RegisterServer(ctx, server)
+		if d, ok := h.(GRPCDrainer); ok {
+			drainers = append(drainers, d)
+		}
+	}
+
+	const name = "gRPC Public server"
+	var srv core.StoppableServer
+	if b.hooks.StoppableGrpcServerBuilder != nil {
+		srv = b.hooks.StoppableGrpcServerBuilder(ctx, server, *cfg, name)
+	} else {
+		log.Infof(ctx, "configured gRPC listener for address: %s:%d", cfg.HostName, cfg.Port)
+		srv = &grpcServer{ctx: ctx, cfg: *cfg, server: server, name: name}
+	}
+	if len(drainers) > 0 {
+		srv = &drainingServer{StoppableServer: srv, drainers: drainers}
+	}
+	return srv, nil
+}
+
+// streamLogger puts logger in the context of streaming calls, as sysl-go
+// does for unary calls.
+func streamLogger(logger log.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &contextStream{ServerStream: ss, ctx: log.PutLogger(ss.Context(), logger)})
+	}
+}
+
+// contextStream replaces the context of a stream.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// grpcServer implements core.StoppableServer as sysl-go does for its own servers.
+type grpcServer struct {
+	ctx    context.Context
+	cfg    config.GRPCServerConfig
+	server *grpc.Server
+	name   string
+}
+
+func (s *grpcServer) Start() error {
+	if s.cfg.TLS != nil {
+		log.Infof(s.ctx, "TLS configuration present. Preparing to serve gRPC/HTTPS for address: %s:%d", s.cfg.HostName, s.cfg.Port)
+	} else {
+		log.Infof(s.ctx, "no TLS configuration present. Preparing to serve gRPC/HTTP for address: %s:%d", s.cfg.HostName, s.cfg.Port)
+	}
+	lis, err := net.Listen("tcp", fmt.Sprintf("%s:%d", s.cfg.HostName, s.cfg.Port))
+	if err != nil {
+		return err
+	}
+	return s.server.Serve(lis)
+}
+
+func (s *grpcServer) GracefulStop() error {
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(defaultGracefulStopTimeout):
+		log.Infof(s.ctx, "warning: GracefulStop timed out for gRPC server, hard-stopping gRPC server")
+		s.server.Stop()
+	}
+	return nil
+}
+
+func (s *grpcServer) Stop() error {
+	s.server.Stop()
+	return nil
+}
+
+func (s *grpcServer) GetName() string {
+	return s.name
+}
+
+// drainingServer drains the handlers of a gRPC server before stopping it
+// gracefully.
+type drainingServer struct {
+	core.StoppableServer
+	drainers []GRPCDrainer
+}
+
+func (s *drainingServer) GracefulStop() error {
+	for _, d := range s.drainers {
+		d.Drain()
+	}
+	return s.StoppableServer.GracefulStop()
+}
+
+// failedServer reports an error that occurred while building a server when
+// it is started, as core.Hooks.StoppableServerBuilder cannot return errors.
+type failedServer struct {
+	err  error
+	name string
+}
+
+func (s failedServer) Start() error        { return s.err }
+func (s failedServer) GracefulStop() error { return nil }
+func (s failedServer) Stop() error         { return nil }
+func (s failedServer) GetName() string     { return s.name }
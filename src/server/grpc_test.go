@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/anz-bank/sysl-go/config"
+	"github.com/anz-bank/sysl-go/core"
+	"github.com/anz-bank/sysl-go/handlerinitialiser"
+	"github.com/anz-bank/sysl-go/log"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+
+	"github.com/anz-bank/sysl-go-demo/src/configext"
+)
+
+// grpcConfig returns a context with the config of a gRPC server on port.
+func grpcConfig(port int, tlsConfig *config.TLSConfig, health bool) context.Context {
+	cfg := &config.DefaultConfig{Library: config.LibraryConfig{Health: health}}
+	cfg.GenCode.Upstream.GRPC.Port = port
+	cfg.GenCode.Upstream.GRPC.TLS = tlsConfig
+	return config.PutDefaultConfig(log.PutLogger(context.Background(), log.NewDefaultLogger()), cfg)
+}
+
+// testHandler is a gRPC handler that registers no services and counts the
+// calls to Drain.
+type testHandler struct {
+	drained int32
+}
+
+func (h *testHandler) RegisterServer(context.Context, *grpc.Server) {}
+
+func (h *testHandler) Drain() {
+	atomic.AddInt32(&h.drained, 1)
+}
+
+// capture returns hooks that store the gRPC server built in server.
+func capture(server **grpc.Server) *core.Hooks {
+	return &core.Hooks{StoppableGrpcServerBuilder: func(_ context.Context, s *grpc.Server, _ config.GRPCServerConfig, name string) core.StoppableServer {
+		*server = s
+		return failedServer{name: name}
+	}}
+}
+
+func TestGRPCBuilder(t *testing.T) {
+	t.Parallel()
+
+	handler := &testHandler{}
+	var captured *grpc.Server
+	b := NewGRPCBuilder(capture(&captured), func(context.Context, *core.Hooks) (handlerinitialiser.GrpcHandlerInitialiser, error) {
+		return handler, nil
+	})
+
+	srv, err := b.Build(grpcConfig(0, nil, false))
+	require.NoError(t, err)
+	require.Nil(t, srv, "no port")
+
+	_, err = b.Build(grpcConfig(6062, nil, true))
+	require.ErrorContains(t, err, "library.health starts the sysl-go gRPC server")
+
+	srv, err = b.Build(grpcConfig(6062, nil, false))
+	require.NoError(t, err)
+	require.NotNil(t, captured)
+	require.Equal(t, "gRPC Public server", srv.GetName())
+	require.NoError(t, srv.GracefulStop())
+	require.Equal(t, int32(1), atomic.LoadInt32(&handler.drained), "handlers drained before stopping")
+}
+
+func TestGRPCBuilderTLS(t *testing.T) {
+	t.Parallel()
+
+	// The test server of httptest has a certificate for 127.0.0.1.
+	https := httptest.NewTLSServer(http.NotFoundHandler())
+	https.Close()
+	cert := https.TLS.Certificates[0]
+
+	var served int32
+	var captured *grpc.Server
+	b := NewGRPCBuilder(capture(&captured))
+	b.ConfigureTLS = func(cfg *tls.Config) *tls.Config {
+		cfg = cfg.Clone()
+		cfg.Certificates = nil
+		cfg.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			atomic.AddInt32(&served, 1)
+			return &cert, nil
+		}
+		return cfg
+	}
+	noClientCert, tls12, tls13, never := "NoClientCert", "1.2", "1.3", "RenegotiateNever"
+	_, err := b.Build(grpcConfig(6062, &config.TLSConfig{ClientAuth: &noClientCert, MinVersion: &tls12, MaxVersion: &tls13, Renegotiation: &never}, false))
+	require.NoError(t, err)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = captured.Serve(lis) }()
+	defer captured.Stop()
+	creds := credentials.NewTLS(&tls.Config{InsecureSkipVerify: true}) //nolint:gosec // test certificate
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(creds))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = conn.Invoke(ctx, "/petdemo.PetService/GetPet", &emptyMessage{}, &emptyMessage{})
+	require.Equal(t, codes.Unimplemented, status.Code(err), err)
+	require.Equal(t, int32(1), atomic.LoadInt32(&served), "certificate of ConfigureTLS")
+}
+
+func TestLoadGRPCKeepalive(t *testing.T) {
+	t.Parallel()
+
+	load := func(yaml string) (GRPCKeepalive, error) {
+		_, ext, err := configext.Split([]byte(yaml), GRPCKeepaliveExtension)
+		require.NoError(t, err)
+		return LoadGRPCKeepalive(ext)
+	}
+
+	cfg, err := load("genCode:\n  upstream:\n    grpc:\n      port: 1\n")
+	require.NoError(t, err)
+	require.Equal(t, GRPCKeepalive{}, cfg)
+	require.Empty(t, cfg.serverOptions())
+
+	cfg, err = load("genCode:\n  upstream:\n    grpc:\n      keepalive:\n        time: 30s\n        minTime: 10s\n")
+	require.NoError(t, err)
+	require.Equal(t, GRPCKeepalive{Time: 30 * time.Second, MinTime: 10 * time.Second}, cfg)
+	require.Len(t, cfg.serverOptions(), 2)
+	require.Len(t, GRPCKeepalive{PermitWithoutStream: true}.serverOptions(), 1)
+
+	_, err = load("genCode:\n  upstream:\n    grpc:\n      keepalive:\n        timeout: -1s\n")
+	require.ErrorContains(t, err, "genCode.upstream.grpc.keepalive: time, timeout and minTime must not be negative")
+}
+
+func TestStreamLogger(t *testing.T) {
+	t.Parallel()
+
+	logger := log.NewDefaultLogger()
+	var got log.Logger
+	err := streamLogger(logger)(nil, &contextStream{ctx: context.Background()}, &grpc.StreamServerInfo{}, func(_ interface{}, ss grpc.ServerStream) error {
+		got = log.GetLogger(ss.Context())
+		return nil
+	})
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	require.Nil(t, log.GetLogger(context.Background()))
+}
+
+// emptyMessage is an empty protobuf message, as sent to RPCs whose requests
+// have no fields.
+type emptyMessage struct{}
+
+func (*emptyMessage) Reset()         {}
+func (*emptyMessage) String() string { return "" }
+func (*emptyMessage) ProtoMessage()  {}
@@ -0,0 +1,210 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anz-bank/sysl-go-demo/src/configext"
+	"github.com/anz-bank/sysl-go/common"
+	"github.com/anz-bank/sysl-go/log"
+)
+
+// HardeningExtension is the config extension holding the protections of the
+// public server, which sysl-go's CommonHTTPServerConfig has no settings for.
+const HardeningExtension = "genCode.upstream.http.hardening"
+
+// HardeningConfig configures the protections of the public server, found
+// under genCode.upstream.http.hardening.
+type HardeningConfig struct {
+	// ReadHeaderTimeout is how long clients have to send the request headers, 10s by default.
+	ReadHeaderTimeout time.Duration `yaml:"readHeaderTimeout" mapstructure:"readHeaderTimeout"`
+	// MaxHeaderBytes limits the size of the request headers, 1MiB by default.
+	// net/http allows another 4KiB on top of it.
+	MaxHeaderBytes int `yaml:"maxHeaderBytes" mapstructure:"maxHeaderBytes"`
+	// MaxBodyBytes limits the size of request bodies on routes without their
+	// own limit. There is no limit by default.
+	MaxBodyBytes int64 `yaml:"maxBodyBytes" mapstructure:"maxBodyBytes"`
+	// Routes override MaxBodyBytes. The first route matching a request applies.
+	Routes []RouteLimit `yaml:"routes" mapstructure:"routes"`
+	// ContentTypes are the media types accepted for request bodies, such as
+	// application/json or text/*. Any type is accepted if empty.
+	ContentTypes    []string        `yaml:"contentTypes" mapstructure:"contentTypes"`
+	SecurityHeaders SecurityHeaders `yaml:"securityHeaders" mapstructure:"securityHeaders"`
+}
+
+// RouteLimit limits the size of the request bodies of a route.
+type RouteLimit struct {
+	// Method matches any method if empty.
+	Method string `yaml:"method" mapstructure:"method"`
+	// Path is a route pattern as used by chi, such as /pet/{id} or /upload/*.
+	Path string `yaml:"path" mapstructure:"path"`
+	// MaxBodyBytes is the limit, or -1 for no limit.
+	MaxBodyBytes int64 `yaml:"maxBodyBytes" mapstructure:"maxBodyBytes"`
+}
+
+// SecurityHeaders are added to every response. Headers left empty are not sent.
+type SecurityHeaders struct {
+	// HSTS is the max-age of Strict-Transport-Security.
+	HSTS                  time.Duration `yaml:"hsts" mapstructure:"hsts"`
+	HSTSIncludeSubdomains bool          `yaml:"hstsIncludeSubdomains" mapstructure:"hstsIncludeSubdomains"`
+	// NoSniff sends X-Content-Type-Options: nosniff.
+	NoSniff               bool   `yaml:"noSniff" mapstructure:"noSniff"`
+	FrameOptions          string `yaml:"frameOptions" mapstructure:"frameOptions"`
+	ContentSecurityPolicy string `yaml:"contentSecurityPolicy" mapstructure:"contentSecurityPolicy"`
+	ReferrerPolicy        string `yaml:"referrerPolicy" mapstructure:"referrerPolicy"`
+}
+
+// LoadHardening decodes the hardening settings found in ext. The zero value
+// is returned if there are none, keeping the sysl-go defaults.
+func LoadHardening(ext *configext.Extensions) (HardeningConfig, error) {
+	var cfg HardeningConfig
+	found := ext.Get(HardeningExtension)
+	if len(found) == 0 {
+		return cfg, nil
+	}
+	if err := found[0].Decode(&cfg); err != nil {
+		return cfg, err
+	}
+	if err := cfg.validate(); err != nil {
+		return cfg, fmt.Errorf("%s: %w", found[0].Path, err)
+	}
+	return cfg, nil
+}
+
+func (c HardeningConfig) validate() error {
+	if c.ReadHeaderTimeout < 0 || c.MaxHeaderBytes < 0 || c.MaxBodyBytes < 0 {
+		return errors.New("readHeaderTimeout, maxHeaderBytes and maxBodyBytes must not be negative")
+	}
+	for i, route := range c.Routes {
+		if !strings.HasPrefix(route.Path, "/") {
+			return fmt.Errorf("routes[%d]: path must start with /", i)
+		}
+		if route.MaxBodyBytes == 0 || route.MaxBodyBytes < -1 {
+			return fmt.Errorf("routes[%d]: maxBodyBytes must be positive, or -1 for no limit", i)
+		}
+	}
+	for _, contentType := range c.ContentTypes {
+		if _, _, err := mime.ParseMediaType(contentType); err != nil {
+			return fmt.Errorf("invalid content type %q: %w", contentType, err)
+		}
+	}
+	return nil
+}
+
+// bodyLimit returns the body size limit of req, or -1 if there is none.
+func (c HardeningConfig) bodyLimit(req *http.Request) int64 {
+	for _, route := range c.Routes {
+		if (route.Method == "" || strings.EqualFold(route.Method, req.Method)) && matchPath(route.Path, req.URL.Path) {
+			return route.MaxBodyBytes
+		}
+	}
+	if c.MaxBodyBytes == 0 {
+		return -1
+	}
+	return c.MaxBodyBytes
+}
+
+// matchPath reports whether path matches a chi route pattern, in which
+// {param} matches a single segment and a trailing * matches the rest.
+func matchPath(pattern, path string) bool {
+	patternSegments := strings.Split(strings.TrimPrefix(pattern, "/"), "/")
+	pathSegments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	for i, segment := range patternSegments {
+		if segment == "*" && i == len(patternSegments)-1 {
+			return true
+		}
+		if i >= len(pathSegments) {
+			return false
+		}
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			if pathSegments[i] == "" {
+				return false
+			}
+			continue
+		}
+		if segment != pathSegments[i] {
+			return false
+		}
+	}
+	return len(patternSegments) == len(pathSegments)
+}
+
+// contentTypeAllowed reports whether the media type of a request body is accepted.
+func (c HardeningConfig) contentTypeAllowed(contentType string) bool {
+	if len(c.ContentTypes) == 0 {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range c.ContentTypes {
+		allowed, _, _ = mime.ParseMediaType(allowed)
+		if allowed == mediaType || (strings.HasSuffix(allowed, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(allowed, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handler adds the security headers to every response and rejects requests
+// with a body that is too large or of an unexpected content type. Bodies
+// sent without a Content-Length are cut off at the limit, failing the
+// request once the handler reads past it.
+func (c HardeningConfig) Handler(next http.Handler) http.Handler {
+	headers := c.SecurityHeaders.values()
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		for name, value := range headers {
+			w.Header().Set(name, value)
+		}
+		if req.ContentLength != 0 {
+			if !c.contentTypeAllowed(req.Header.Get("Content-Type")) {
+				reject(w, req, http.StatusUnsupportedMediaType, "Unsupported content type")
+				return
+			}
+			if limit := c.bodyLimit(req); limit >= 0 {
+				if req.ContentLength > limit {
+					reject(w, req, http.StatusRequestEntityTooLarge, "Request body too large")
+					return
+				}
+				req.Body = http.MaxBytesReader(w, req.Body, limit)
+			}
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+func reject(w http.ResponseWriter, req *http.Request, status int, description string) {
+	log.Debugf(req.Context(), "hardening: %s %s rejected: %s", req.Method, req.URL.Path, description)
+	httpError := common.HTTPError{HTTPCode: status, Code: "1001", Description: description}
+	httpError.WriteError(req.Context(), w)
+}
+
+func (s SecurityHeaders) values() map[string]string {
+	headers := make(map[string]string)
+	if s.HSTS > 0 {
+		value := "max-age=" + strconv.Itoa(int(s.HSTS/time.Second))
+		if s.HSTSIncludeSubdomains {
+			value += "; includeSubDomains"
+		}
+		headers["Strict-Transport-Security"] = value
+	}
+	if s.NoSniff {
+		headers["X-Content-Type-Options"] = "nosniff"
+	}
+	if s.FrameOptions != "" {
+		headers["X-Frame-Options"] = s.FrameOptions
+	}
+	if s.ContentSecurityPolicy != "" {
+		headers["Content-Security-Policy"] = s.ContentSecurityPolicy
+	}
+	if s.ReferrerPolicy != "" {
+		headers["Referrer-Policy"] = s.ReferrerPolicy
+	}
+	return headers
+}
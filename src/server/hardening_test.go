@@ -0,0 +1,191 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anz-bank/sysl-go/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchPath(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"/pet", "/pet", true},
+		{"/pet", "/pets", false},
+		{"/pet", "/pet/1", false},
+		{"/pet/{id}", "/pet/1", true},
+		{"/pet/{id}", "/pet/", false},
+		{"/pet/{id}", "/pet", false},
+		{"/pet/{id}", "/pet/1/photo", false},
+		{"/pet/{id}/photo", "/pet/1/photo", true},
+		{"/pet/{id}/photo", "/pet/1/name", false},
+		{"/upload/*", "/upload/a/b", true},
+		{"/upload/*", "/upload/", true},
+		{"/upload/*", "/other/a", false},
+		{"/*", "/anything", true},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, matchPath(tt.pattern, tt.path), "%s %s", tt.pattern, tt.path)
+	}
+}
+
+func TestBodyLimit(t *testing.T) {
+	t.Parallel()
+
+	cfg := HardeningConfig{
+		MaxBodyBytes: 100,
+		Routes: []RouteLimit{
+			{Method: "post", Path: "/pet/{id}", MaxBodyBytes: 1000},
+			{Path: "/upload/*", MaxBodyBytes: -1},
+			{Path: "/pet/{id}", MaxBodyBytes: 10},
+		},
+	}
+	tests := []struct {
+		method string
+		path   string
+		want   int64
+	}{
+		{http.MethodPost, "/pet/1", 1000},
+		// The first matching route applies.
+		{http.MethodPut, "/pet/1", 10},
+		{http.MethodPost, "/upload/a", -1},
+		{http.MethodPost, "/pet", 100},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, cfg.bodyLimit(httptest.NewRequest(tt.method, tt.path, nil)), "%s %s", tt.method, tt.path)
+	}
+	require.Equal(t, int64(-1), HardeningConfig{}.bodyLimit(httptest.NewRequest(http.MethodPost, "/pet", nil)))
+}
+
+func TestContentTypeAllowed(t *testing.T) {
+	t.Parallel()
+
+	cfg := HardeningConfig{ContentTypes: []string{"application/json", "text/*"}}
+	tests := []struct {
+		contentType string
+		want        bool
+	}{
+		{"application/json", true},
+		{"Application/JSON; charset=utf-8", true},
+		{"text/plain", true},
+		{"text/csv; header=present", true},
+		{"application/xml", false},
+		{"application/json-patch+json", false},
+		{"", false},
+		{"not a type", false},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, cfg.contentTypeAllowed(tt.contentType), tt.contentType)
+	}
+	require.True(t, HardeningConfig{}.contentTypeAllowed("application/xml"))
+}
+
+func TestHardeningHandler(t *testing.T) {
+	t.Parallel()
+
+	cfg := HardeningConfig{
+		MaxBodyBytes: 10,
+		Routes:       []RouteLimit{{Method: http.MethodPost, Path: "/pet/{id}", MaxBodyBytes: 20}},
+		ContentTypes: []string{"application/json"},
+		SecurityHeaders: SecurityHeaders{
+			HSTS:                  time.Hour,
+			HSTSIncludeSubdomains: true,
+			NoSniff:               true,
+			FrameOptions:          "DENY",
+			ContentSecurityPolicy: "default-src 'none'",
+			ReferrerPolicy:        "no-referrer",
+		},
+	}
+	handler := cfg.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+		}
+	}))
+
+	body := func(n int) string { return strings.Repeat("1", n) }
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+		chunked     bool
+		want        int
+	}{
+		{"no body", http.MethodGet, "/pet", "", "", false, http.StatusOK},
+		{"no body, unknown type", http.MethodGet, "/pet", "application/xml", "", false, http.StatusOK},
+		{"within limit", http.MethodPost, "/pet", "application/json", body(10), false, http.StatusOK},
+		{"over limit", http.MethodPost, "/pet", "application/json", body(11), false, http.StatusRequestEntityTooLarge},
+		{"route limit", http.MethodPost, "/pet/1", "application/json", body(20), false, http.StatusOK},
+		{"over route limit", http.MethodPost, "/pet/1", "application/json", body(21), false, http.StatusRequestEntityTooLarge},
+		{"global limit on other method", http.MethodPut, "/pet/1", "application/json", body(20), false, http.StatusRequestEntityTooLarge},
+		{"chunked over limit", http.MethodPost, "/pet", "application/json", body(11), true, http.StatusRequestEntityTooLarge},
+		{"unsupported type", http.MethodPost, "/pet", "application/xml", body(2), false, http.StatusUnsupportedMediaType},
+		{"missing type", http.MethodPost, "/pet", "", body(2), false, http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req = req.WithContext(log.PutLogger(context.Background(), log.NewDefaultLogger()))
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			require.Equal(t, tt.want, w.Code, w.Body.String())
+
+			// Security headers are added to rejected requests too.
+			h := w.Header()
+			require.Equal(t, "max-age=3600; includeSubDomains", h.Get("Strict-Transport-Security"))
+			require.Equal(t, "nosniff", h.Get("X-Content-Type-Options"))
+			require.Equal(t, "DENY", h.Get("X-Frame-Options"))
+			require.Equal(t, "default-src 'none'", h.Get("Content-Security-Policy"))
+			require.Equal(t, "no-referrer", h.Get("Referrer-Policy"))
+		})
+	}
+
+	w := httptest.NewRecorder()
+	HardeningConfig{}.Handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/pet", nil))
+	require.Empty(t, w.Header().Get("Strict-Transport-Security"))
+	require.Empty(t, w.Header().Get("X-Content-Type-Options"))
+}
+
+func TestHardeningValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		cfg HardeningConfig
+		err string
+	}{
+		{HardeningConfig{MaxBodyBytes: -1}, "must not be negative"},
+		{HardeningConfig{Routes: []RouteLimit{{Path: "pet", MaxBodyBytes: 1}}}, "routes[0]: path must start with /"},
+		{HardeningConfig{Routes: []RouteLimit{{Path: "/pet"}}}, "routes[0]: maxBodyBytes must be positive"},
+		{HardeningConfig{Routes: []RouteLimit{{Path: "/pet", MaxBodyBytes: -2}}}, "routes[0]: maxBodyBytes must be positive"},
+		{HardeningConfig{ContentTypes: []string{"not a type"}}, `invalid content type "not a type"`},
+		{HardeningConfig{Routes: []RouteLimit{{Path: "/pet", MaxBodyBytes: -1}}, ContentTypes: []string{"text/*"}}, ""},
+	}
+	for _, tt := range tests {
+		err := tt.cfg.validate()
+		if tt.err == "" {
+			require.NoError(t, err)
+			continue
+		}
+		require.ErrorContains(t, err, tt.err)
+	}
+}
@@ -0,0 +1,156 @@
+// Package server builds the public HTTP server of the service in place of
+// the sysl-go default, so that handlers can run ahead of the sysl-go
+// middleware (timeout, logging, authorization, ...) on the root router.
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	stdlog "log"
+	"net"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/anz-bank/sysl-go/config"
+	"github.com/anz-bank/sysl-go/core"
+	"github.com/anz-bank/sysl-go/log"
+)
+
+const (
+	defaultGracefulStopTimeout = 3 * time.Minute
+	defaultReadHeaderTimeout   = 10 * time.Second
+	defaultIdleTimeout         = 5 * time.Second
+)
+
+// Builder creates the public server, wrapping the root router in middleware.
+type Builder struct {
+	// ConfigureTLS, if set, replaces the TLS config built by sysl-go.
+	ConfigureTLS func(*tls.Config) *tls.Config
+	// GRPC, if set, builds the public gRPC server, which is started and
+	// stopped with the public HTTP server.
+	GRPC *GRPCBuilder
+
+	hardening  HardeningConfig
+	middleware []func(http.Handler) http.Handler
+}
+
+// NewBuilder creates a Builder. Requests pass through the hardening checks
+// and then middleware in the given order before reaching the sysl-go root
+// router. Nil entries are skipped.
+func NewBuilder(hardening HardeningConfig, middleware ...func(http.Handler) http.Handler) *Builder {
+	b := &Builder{hardening: hardening}
+	for _, mw := range middleware {
+		if mw != nil {
+			b.middleware = append(b.middleware, mw)
+		}
+	}
+	return b
+}
+
+// Build creates the server. It has the signature of core.Hooks.StoppableServerBuilder.
+func (b *Builder) Build(ctx context.Context, rootRouter http.Handler, tlsConfig *tls.Config, httpConfig config.CommonHTTPServerConfig, name string) core.StoppableServer {
+	handler := rootRouter
+	for i := len(b.middleware) - 1; i >= 0; i-- {
+		handler = b.middleware[i](handler)
+	}
+	handler = b.hardening.Handler(handler)
+	if b.ConfigureTLS != nil {
+		tlsConfig = b.ConfigureTLS(tlsConfig)
+	}
+
+	readHeaderTimeout := defaultReadHeaderTimeout
+	if b.hardening.ReadHeaderTimeout > 0 {
+		readHeaderTimeout = b.hardening.ReadHeaderTimeout
+	}
+	maxHeaderBytes := http.DefaultMaxHeaderBytes
+	if b.hardening.MaxHeaderBytes > 0 {
+		maxHeaderBytes = b.hardening.MaxHeaderBytes
+	}
+
+	server := &http.Server{
+		Addr:              fmt.Sprintf("%s:%d", httpConfig.Common.HostName, httpConfig.Common.Port),
+		Handler:           handler,
+		TLSConfig:         tlsConfig,
+		ReadTimeout:       httpConfig.ReadTimeout,
+		WriteTimeout:      httpConfig.WriteTimeout,
+		ReadHeaderTimeout: readHeaderTimeout,
+		IdleTimeout:       defaultIdleTimeout,
+		MaxHeaderBytes:    maxHeaderBytes,
+		ErrorLog:          stdlog.New(&logFilter{ctx: ctx}, "HTTPServer ", stdlog.LstdFlags|stdlog.Llongfile),
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	log.Infof(ctx, "configured listener for address: %s:%d%s", httpConfig.Common.HostName, httpConfig.Common.Port, httpConfig.BasePath)
+	httpSrv := &httpServer{ctx: ctx, cfg: httpConfig, server: server, name: name}
+	if b.GRPC == nil {
+		return httpSrv
+	}
+	grpcSrv, err := b.GRPC.Build(ctx)
+	if err != nil {
+		return failedServer{err: err, name: name}
+	}
+	if grpcSrv == nil {
+		return httpSrv
+	}
+	return core.NewMultiStoppableServer(ctx, []core.StoppableServer{httpSrv, grpcSrv})
+}
+
+// tlsHandshakeEOF matches the spurious TLS errors caused by load balancer health checks.
+var tlsHandshakeEOF = regexp.MustCompile(`TLS handshake error from .* EOF`)
+
+// logFilter writes the errors of the http.Server to the sysl-go logger.
+type logFilter struct {
+	ctx context.Context
+}
+
+func (f *logFilter) Write(p []byte) (int, error) {
+	if tlsHandshakeEOF.Match(p) {
+		log.Debug(f.ctx, string(p))
+	} else {
+		log.Info(f.ctx, string(p))
+	}
+	return len(p), nil
+}
+
+// httpServer implements core.StoppableServer as sysl-go does for its own servers.
+type httpServer struct {
+	ctx    context.Context
+	cfg    config.CommonHTTPServerConfig
+	server *http.Server
+	name   string
+}
+
+func (s *httpServer) Start() error {
+	var err error
+	if s.cfg.Common.TLS != nil {
+		log.Infof(s.ctx, "TLS configuration present. Preparing to serve HTTPS for address: %s:%d%s", s.cfg.Common.HostName, s.cfg.Common.Port, s.cfg.BasePath)
+		err = s.server.ListenAndServeTLS("", "")
+	} else {
+		log.Infof(s.ctx, "no TLS configuration present. Preparing to serve HTTP for address: %s:%d%s", s.cfg.Common.HostName, s.cfg.Common.Port, s.cfg.BasePath)
+		err = s.server.ListenAndServe()
+	}
+	if err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+func (s *httpServer) GracefulStop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultGracefulStopTimeout)
+	defer cancel()
+	err := s.server.Shutdown(ctx)
+	if err == context.DeadlineExceeded {
+		log.Infof(s.ctx, "warning: GracefulStop timed out for HTTP server, hard-stopping HTTP server")
+		return s.server.Close()
+	}
+	return err
+}
+
+func (s *httpServer) Stop() error {
+	return s.server.Close()
+}
+
+func (s *httpServer) GetName() string {
+	return s.name
+}
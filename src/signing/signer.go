@@ -0,0 +1,109 @@
+package signing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/anz-bank/sysl-go/config"
+)
+
+const defaultMaxBodyBytes = 10 << 20
+
+// SignerConfig configures the signing of requests to a downstream, found
+// under genCode.downstream.<name>.signing.
+type SignerConfig struct {
+	KeyID string `yaml:"keyID" mapstructure:"keyID"`
+	// Secret may be a reference to an environment variable or file, see
+	// configext.ResolveSecret.
+	Secret config.SensitiveString `yaml:"secret" mapstructure:"secret"`
+	// Algorithm is hmac-sha256 (the default) or hmac-sha512.
+	Algorithm string `yaml:"algorithm" mapstructure:"algorithm"`
+	// Headers are the request headers covered by the signature, in addition
+	// to the method, path, timestamp, nonce and body digest. "host" selects
+	// the host the request is sent to.
+	Headers []string `yaml:"headers" mapstructure:"headers"`
+	// MaxBodyBytes limits the size of the bodies that can be signed, 10MiB by default.
+	MaxBodyBytes int64 `yaml:"maxBodyBytes" mapstructure:"maxBodyBytes"`
+}
+
+// Signer signs outgoing requests.
+type Signer struct {
+	cfg     SignerConfig
+	newHash func() hash.Hash
+}
+
+// NewSigner creates a Signer from config.
+func NewSigner(cfg SignerConfig) (*Signer, error) {
+	if cfg.KeyID == "" || cfg.Secret.Value() == "" {
+		return nil, errors.New("keyID and secret must be set")
+	}
+	if cfg.Algorithm == "" {
+		cfg.Algorithm = AlgorithmHMACSHA256
+	}
+	h, err := newHash(cfg.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MaxBodyBytes == 0 {
+		cfg.MaxBodyBytes = defaultMaxBodyBytes
+	}
+	cfg.Headers = normalizeHeaders(cfg.Headers)
+	return &Signer{cfg: cfg, newHash: h}, nil
+}
+
+// Sign adds the signature headers to req. The body of req is read and
+// replaced, so that it can still be sent.
+func (s *Signer) Sign(req *http.Request) error {
+	body, err := readBody(req, s.cfg.MaxBodyBytes)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	d := digest(body)
+
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderNonce, hex.EncodeToString(nonce))
+	req.Header.Set(HeaderDigest, d)
+	sig := signature{
+		keyID:     s.cfg.KeyID,
+		algorithm: s.cfg.Algorithm,
+		headers:   s.cfg.Headers,
+		value: mac(s.newHash, []byte(s.cfg.Secret.Value()),
+			canonical(req, timestamp, req.Header.Get(HeaderNonce), d, s.cfg.Headers)),
+	}
+	req.Header.Set(HeaderSignature, sig.String())
+	return nil
+}
+
+// Transport signs every request sent through Base.
+type Transport struct {
+	Signer *Signer
+	Base   http.RoundTripper
+}
+
+// NewTransport creates a Transport sending requests through base, or
+// http.DefaultTransport if base is nil.
+func NewTransport(signer *Signer, base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{Signer: signer, Base: base}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the request it is given.
+	signed := req.Clone(req.Context())
+	if err := t.Signer.Sign(signed); err != nil {
+		return nil, err
+	}
+	return t.Base.RoundTrip(signed)
+}
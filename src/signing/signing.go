@@ -0,0 +1,164 @@
+// Package signing signs HTTP requests with a shared HMAC key, and verifies
+// the signatures of incoming requests.
+//
+// A signed request carries these headers:
+//
+//	X-Signature-Timestamp: 1760000000
+//	X-Signature-Nonce:     5f0c3e...
+//	X-Content-Digest:      sha-256=<base64 SHA-256 of the body>
+//	X-Signature:           keyId="partner-1",algorithm="hmac-sha256",headers="host content-type",signature="<base64>"
+//
+// The signature is the HMAC of the method, the escaped path and query, the
+// timestamp, the nonce, the body digest and the listed headers, one per line.
+package signing
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	HeaderSignature = "X-Signature"
+	HeaderTimestamp = "X-Signature-Timestamp"
+	HeaderNonce     = "X-Signature-Nonce"
+	HeaderDigest    = "X-Content-Digest"
+
+	AlgorithmHMACSHA256 = "hmac-sha256"
+	AlgorithmHMACSHA512 = "hmac-sha512"
+
+	digestPrefix = "sha-256="
+)
+
+func newHash(algorithm string) (func() hash.Hash, error) {
+	switch algorithm {
+	case AlgorithmHMACSHA256:
+		return sha256.New, nil
+	case AlgorithmHMACSHA512:
+		return sha512.New, nil
+	}
+	return nil, fmt.Errorf("unsupported signing algorithm %q, expected %s or %s", algorithm, AlgorithmHMACSHA256, AlgorithmHMACSHA512)
+}
+
+// signature is the parsed X-Signature header.
+type signature struct {
+	keyID     string
+	algorithm string
+	headers   []string
+	value     []byte
+}
+
+func (s signature) String() string {
+	return fmt.Sprintf(`keyId=%q,algorithm=%q,headers=%q,signature=%q`,
+		s.keyID, s.algorithm, strings.Join(s.headers, " "), base64.StdEncoding.EncodeToString(s.value))
+}
+
+func parseSignature(header string) (signature, error) {
+	var s signature
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 || len(kv[1]) < 2 || !strings.HasPrefix(kv[1], `"`) || !strings.HasSuffix(kv[1], `"`) {
+			return s, fmt.Errorf("malformed %s header", HeaderSignature)
+		}
+		value := kv[1][1 : len(kv[1])-1]
+		switch kv[0] {
+		case "keyId":
+			s.keyID = value
+		case "algorithm":
+			s.algorithm = value
+		case "headers":
+			s.headers = strings.Fields(value)
+		case "signature":
+			b, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				return s, fmt.Errorf("malformed %s header: %w", HeaderSignature, err)
+			}
+			s.value = b
+		}
+	}
+	if s.keyID == "" || s.algorithm == "" || s.value == nil {
+		return s, fmt.Errorf("malformed %s header: keyId, algorithm and signature are required", HeaderSignature)
+	}
+	return s, nil
+}
+
+// canonical returns the string that is signed for req.
+func canonical(req *http.Request, timestamp, nonce, digest string, headers []string) string {
+	var b strings.Builder
+	b.WriteString(req.Method)
+	b.WriteByte('\n')
+	b.WriteString(req.URL.EscapedPath())
+	if req.URL.RawQuery != "" {
+		b.WriteByte('?')
+		b.WriteString(req.URL.RawQuery)
+	}
+	for _, line := range []string{timestamp, nonce, digest} {
+		b.WriteByte('\n')
+		b.WriteString(line)
+	}
+	for _, name := range headers {
+		b.WriteByte('\n')
+		b.WriteString(name)
+		b.WriteByte(':')
+		if name == "host" {
+			b.WriteString(host(req))
+		} else {
+			b.WriteString(strings.Join(req.Header.Values(name), ","))
+		}
+	}
+	return b.String()
+}
+
+// host returns the host the request is addressed to, on the client and on the server.
+func host(req *http.Request) string {
+	if req.Host != "" {
+		return req.Host
+	}
+	return req.URL.Host
+}
+
+func mac(newHash func() hash.Hash, key []byte, data string) []byte {
+	m := hmac.New(newHash, key)
+	_, _ = m.Write([]byte(data))
+	return m.Sum(nil)
+}
+
+// readBody returns the body of req and replaces it so it can be read again.
+func readBody(req *http.Request, limit int64) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(req.Body, limit+1))
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > limit {
+		return nil, errBodyTooLarge
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+var errBodyTooLarge = errors.New("request body too large to sign")
+
+func digest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return digestPrefix + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func normalizeHeaders(headers []string) []string {
+	names := make([]string, 0, len(headers))
+	for _, h := range headers {
+		names = append(names, strings.ToLower(strings.TrimSpace(h)))
+	}
+	return names
+}
@@ -0,0 +1,280 @@
+package signing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anz-bank/sysl-go/config"
+	"github.com/anz-bank/sysl-go/log"
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/require"
+)
+
+func newSigner(t *testing.T, keyID, secret, algorithm string) *Signer {
+	t.Helper()
+	s, err := NewSigner(SignerConfig{
+		KeyID:     keyID,
+		Secret:    config.NewSensitiveString(secret),
+		Algorithm: algorithm,
+		Headers:   []string{"Host", " Content-Type "},
+	})
+	require.NoError(t, err)
+	return s
+}
+
+func newVerifier(t *testing.T, cfg VerifierConfig) *Verifier {
+	t.Helper()
+	if cfg.Keys == nil {
+		cfg.Keys = []VerifierKey{
+			{ID: "partner-1", Secret: config.NewSensitiveString("secret-1")},
+			{ID: "partner-2", Secret: config.NewSensitiveString("secret-2")},
+		}
+	}
+	v, err := NewVerifier(cfg)
+	require.NoError(t, err)
+	return v
+}
+
+func signedRequest(t *testing.T, s *Signer, body string) *http.Request {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "http://petdemo.example.com/pet/1?verbose=true", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	require.NoError(t, s.Sign(req))
+	return req
+}
+
+// resign replaces the timestamp of req and signs it again with the same nonce.
+func resign(t *testing.T, s *Signer, req *http.Request, at time.Time) {
+	t.Helper()
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	sig := signature{
+		keyID:     s.cfg.KeyID,
+		algorithm: s.cfg.Algorithm,
+		headers:   s.cfg.Headers,
+		value: mac(s.newHash, []byte(s.cfg.Secret.Value()),
+			canonical(req, timestamp, req.Header.Get(HeaderNonce), req.Header.Get(HeaderDigest), s.cfg.Headers)),
+	}
+	req.Header.Set(HeaderSignature, sig.String())
+}
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	signer := newSigner(t, "partner-1", "secret-1", "")
+	tests := []struct {
+		name   string
+		signer *Signer
+		modify func(*http.Request)
+		err    string
+	}{
+		{"valid", signer, func(*http.Request) {}, ""},
+		{"sha-512", newSigner(t, "partner-2", "secret-2", AlgorithmHMACSHA512), func(*http.Request) {}, ""},
+		{"unsigned header changed", signer, func(r *http.Request) { r.Header.Set("Accept", "text/plain") }, ""},
+		{"within skew", signer, func(r *http.Request) { resign(t, signer, r, time.Now().Add(-4*time.Minute)) }, ""},
+		{"not signed", signer, func(r *http.Request) { r.Header.Del(HeaderSignature) }, "request is not signed"},
+		{"malformed", signer, func(r *http.Request) { r.Header.Set(HeaderSignature, `keyId=partner-1`) }, "malformed X-Signature header"},
+		{"unknown key", newSigner(t, "partner-3", "secret-1", ""), func(*http.Request) {}, `unknown signing key "partner-3"`},
+		{"wrong secret", newSigner(t, "partner-1", "secret-2", ""), func(*http.Request) {}, "signature does not match"},
+		{"body changed", signer, func(r *http.Request) {
+			r.Body = http.NoBody
+		}, "body digest does not match"},
+		{"body and digest changed", signer, func(r *http.Request) {
+			r.Body = http.NoBody
+			r.Header.Set(HeaderDigest, digest(nil))
+		}, "signature does not match"},
+		{"path changed", signer, func(r *http.Request) { r.URL.Path = "/pet/2" }, "signature does not match"},
+		{"query changed", signer, func(r *http.Request) { r.URL.RawQuery = "verbose=false" }, "signature does not match"},
+		{"host changed", signer, func(r *http.Request) { r.Host = "evil.example.com" }, "signature does not match"},
+		{"signed header changed", signer, func(r *http.Request) { r.Header.Set("Content-Type", "text/plain") }, "signature does not match"},
+		{"method changed", signer, func(r *http.Request) { r.Method = http.MethodPut }, "signature does not match"},
+		{"nonce changed", signer, func(r *http.Request) { r.Header.Set(HeaderNonce, "0000") }, "signature does not match"},
+		{"missing nonce", signer, func(r *http.Request) { r.Header.Del(HeaderNonce) }, "missing X-Signature-Nonce header"},
+		{"invalid timestamp", signer, func(r *http.Request) { r.Header.Set(HeaderTimestamp, "yesterday") }, "invalid X-Signature-Timestamp header"},
+		{"too old", signer, func(r *http.Request) { resign(t, signer, r, time.Now().Add(-6*time.Minute)) }, "outside the allowed skew of 5m0s"},
+		{"too far ahead", signer, func(r *http.Request) { resign(t, signer, r, time.Now().Add(6*time.Minute)) }, "outside the allowed skew"},
+		{"timestamp changed", signer, func(r *http.Request) {
+			r.Header.Set(HeaderTimestamp, strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10))
+		}, "signature does not match"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			v := newVerifier(t, VerifierConfig{})
+			req := signedRequest(t, tt.signer, `{"name":"rex"}`)
+			tt.modify(req)
+			keyID, err := v.Verify(req)
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.signer.cfg.KeyID, keyID)
+		})
+	}
+}
+
+func TestVerifyReplay(t *testing.T) {
+	t.Parallel()
+
+	v := newVerifier(t, VerifierConfig{})
+	// Without a body, so that copies of the request can be sent again.
+	req := signedRequest(t, newSigner(t, "partner-1", "secret-1", ""), "")
+	replay := req.Clone(context.Background())
+	_, err := v.Verify(req)
+	require.NoError(t, err)
+	_, err = v.Verify(replay)
+	require.ErrorContains(t, err, "nonce has already been used")
+
+	// A request with a new nonce is accepted.
+	_, err = v.Verify(signedRequest(t, newSigner(t, "partner-1", "secret-1", ""), ""))
+	require.NoError(t, err)
+
+	// Nonces are remembered per key.
+	other := req.Clone(context.Background())
+	resign(t, newSigner(t, "partner-2", "secret-2", ""), other, time.Now())
+	_, err = v.Verify(other)
+	require.NoError(t, err)
+}
+
+func TestNonceCache(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	c := newNonceCache(2, time.Minute)
+	require.NoError(t, c.add("a", now))
+	require.ErrorContains(t, c.add("a", now.Add(59*time.Second)), "already been used")
+	require.NoError(t, c.add("b", now))
+	// The cache is full of unexpired nonces, so new ones are rejected.
+	require.ErrorContains(t, c.add("c", now), "nonce cache is full")
+	// Expired nonces are evicted to make room, and may be used again.
+	require.NoError(t, c.add("c", now.Add(time.Minute)))
+	require.NoError(t, c.add("a", now.Add(time.Minute)))
+}
+
+func TestNewVerifier(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		cfg  VerifierConfig
+		err  string
+	}{
+		{"no keys", VerifierConfig{}, ""},
+		{"required without keys", VerifierConfig{Required: true}, "required is set, but there are no keys"},
+		{"key without secret", VerifierConfig{Keys: []VerifierKey{{ID: "partner-1"}}}, "every key must have an id and a secret"},
+		{"duplicate key", VerifierConfig{Keys: []VerifierKey{
+			{ID: "partner-1", Secret: config.NewSensitiveString("a")},
+			{ID: "partner-1", Secret: config.NewSensitiveString("b")},
+		}}, "duplicate key partner-1"},
+		{"unset secret reference", VerifierConfig{Keys: []VerifierKey{
+			{ID: "partner-1", Secret: config.NewSensitiveString("env:SIGNING_TEST_UNSET")},
+		}}, "key partner-1: environment variable SIGNING_TEST_UNSET is not set"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			v, err := NewVerifier(tt.cfg)
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Nil(t, v)
+		})
+	}
+}
+
+func TestNewSigner(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewSigner(SignerConfig{KeyID: "partner-1"})
+	require.ErrorContains(t, err, "keyID and secret must be set")
+	_, err = NewSigner(SignerConfig{KeyID: "partner-1", Secret: config.NewSensitiveString("s"), Algorithm: "md5"})
+	require.ErrorContains(t, err, `unsupported signing algorithm "md5"`)
+}
+
+func TestMiddleware(t *testing.T) {
+	t.Parallel()
+
+	signer := newSigner(t, "partner-1", "secret-1", "")
+	tests := []struct {
+		name     string
+		required bool
+		signed   bool
+		tamper   bool
+		status   int
+	}{
+		{"signed", false, true, false, http.StatusOK},
+		{"unsigned and optional", false, false, false, http.StatusOK},
+		{"unsigned and required", true, false, false, http.StatusUnauthorized},
+		{"tampered and optional", false, true, true, http.StatusUnauthorized},
+		{"signed and required", true, true, false, http.StatusOK},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			v := newVerifier(t, VerifierConfig{Required: tt.required})
+			r := chi.NewRouter()
+			r.Use(func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+					next.ServeHTTP(w, req.WithContext(log.PutLogger(req.Context(), log.NewDefaultLogger())))
+				})
+			})
+			v.Middleware(context.Background(), r)
+			r.Post("/pet/{id}", func(w http.ResponseWriter, req *http.Request) {
+				keyID, ok := KeyIDFromContext(req.Context())
+				require.Equal(t, tt.signed, ok)
+				_, _ = w.Write([]byte(keyID))
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "http://petdemo.example.com/pet/1", strings.NewReader("{}"))
+			if tt.signed {
+				req = signedRequest(t, signer, "{}")
+			}
+			if tt.tamper {
+				req.Header.Set("Content-Type", "text/plain")
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			require.Equal(t, tt.status, w.Code)
+			if tt.status == http.StatusOK && tt.signed {
+				require.Equal(t, "partner-1", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestTransport(t *testing.T) {
+	t.Parallel()
+
+	v := newVerifier(t, VerifierConfig{Required: true})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		keyID, err := v.Verify(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(keyID))
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: NewTransport(newSigner(t, "partner-2", "secret-2", AlgorithmHMACSHA512), nil)}
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/pet?name=rex", strings.NewReader(`{"name":"rex"}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	// The request given to the transport is not modified.
+	require.Empty(t, req.Header.Get(HeaderSignature))
+}
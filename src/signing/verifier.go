@@ -0,0 +1,210 @@
+package signing
+
+import (
+	"context"
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/anz-bank/sysl-go/common"
+	"github.com/anz-bank/sysl-go/config"
+	"github.com/anz-bank/sysl-go/log"
+	"github.com/go-chi/chi"
+
+	"github.com/anz-bank/sysl-go-demo/src/configext"
+)
+
+const (
+	defaultMaxSkew        = 5 * time.Minute
+	defaultNonceCacheSize = 100000
+)
+
+// VerifierConfig configures the verification of signed requests to Petdemo,
+// found under app.requestSigning.
+type VerifierConfig struct {
+	Keys []VerifierKey `yaml:"keys" mapstructure:"keys"`
+	// Required rejects requests without a signature. Otherwise only the
+	// signatures that are present are verified.
+	Required bool `yaml:"required" mapstructure:"required"`
+	// MaxSkew is how far the timestamp of a request may be from the server
+	// clock, 5m by default. Nonces are remembered for twice as long.
+	MaxSkew time.Duration `yaml:"maxSkew" mapstructure:"maxSkew"`
+	// NonceCacheSize limits the number of nonces remembered, 100000 by default.
+	NonceCacheSize int `yaml:"nonceCacheSize" mapstructure:"nonceCacheSize"`
+	// MaxBodyBytes limits the size of the bodies that can be verified, 10MiB by default.
+	MaxBodyBytes int64 `yaml:"maxBodyBytes" mapstructure:"maxBodyBytes"`
+}
+
+// VerifierKey is a key that callers may sign requests with. Secret may be a
+// reference to an environment variable or file, see configext.ResolveSecret.
+type VerifierKey struct {
+	ID     string                 `yaml:"id" mapstructure:"id"`
+	Secret config.SensitiveString `yaml:"secret" mapstructure:"secret"`
+}
+
+// Verifier verifies the signatures of incoming requests.
+type Verifier struct {
+	cfg    VerifierConfig
+	keys   map[string][]byte
+	nonces *nonceCache
+}
+
+// NewVerifier creates a Verifier from config. It returns nil if no keys are configured.
+func NewVerifier(cfg VerifierConfig) (*Verifier, error) {
+	if len(cfg.Keys) == 0 {
+		if cfg.Required {
+			return nil, errors.New("app.requestSigning: required is set, but there are no keys")
+		}
+		return nil, nil
+	}
+	if cfg.MaxSkew == 0 {
+		cfg.MaxSkew = defaultMaxSkew
+	}
+	if cfg.NonceCacheSize == 0 {
+		cfg.NonceCacheSize = defaultNonceCacheSize
+	}
+	if cfg.MaxBodyBytes == 0 {
+		cfg.MaxBodyBytes = defaultMaxBodyBytes
+	}
+	v := &Verifier{
+		cfg:    cfg,
+		keys:   make(map[string][]byte, len(cfg.Keys)),
+		nonces: newNonceCache(cfg.NonceCacheSize, 2*cfg.MaxSkew),
+	}
+	for _, k := range cfg.Keys {
+		if k.ID == "" || k.Secret.Value() == "" {
+			return nil, errors.New("app.requestSigning: every key must have an id and a secret")
+		}
+		if _, exists := v.keys[k.ID]; exists {
+			return nil, fmt.Errorf("app.requestSigning: duplicate key %s", k.ID)
+		}
+		// Keys are a list, which environment variables cannot override.
+		secret, err := configext.ResolveSecret(k.Secret.Value())
+		if err != nil {
+			return nil, fmt.Errorf("app.requestSigning: key %s: %w", k.ID, err)
+		}
+		v.keys[k.ID] = []byte(secret)
+	}
+	return v, nil
+}
+
+type keyIDKey struct{}
+
+// KeyIDFromContext returns the id of the key that the request was signed
+// with, if it carried a valid signature.
+func KeyIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(keyIDKey{}).(string)
+	return id, ok
+}
+
+// Verify checks the signature of req, returning the id of the key it was
+// signed with. The body of req is read and replaced.
+func (v *Verifier) Verify(req *http.Request) (string, error) {
+	header := req.Header.Get(HeaderSignature)
+	if header == "" {
+		return "", errors.New("request is not signed")
+	}
+	sig, err := parseSignature(header)
+	if err != nil {
+		return "", err
+	}
+	key, ok := v.keys[sig.keyID]
+	if !ok {
+		return "", fmt.Errorf("unknown signing key %q", sig.keyID)
+	}
+	h, err := newHash(sig.algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	timestamp, nonce := req.Header.Get(HeaderTimestamp), req.Header.Get(HeaderNonce)
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s header", HeaderTimestamp)
+	}
+	if skew := time.Since(time.Unix(unix, 0)); skew > v.cfg.MaxSkew || skew < -v.cfg.MaxSkew {
+		return "", fmt.Errorf("request timestamp is outside the allowed skew of %s", v.cfg.MaxSkew)
+	}
+	if nonce == "" {
+		return "", fmt.Errorf("missing %s header", HeaderNonce)
+	}
+
+	body, err := readBody(req, v.cfg.MaxBodyBytes)
+	if err != nil {
+		return "", err
+	}
+	d := digest(body)
+	if req.Header.Get(HeaderDigest) != d {
+		return "", errors.New("body digest does not match")
+	}
+	expected := mac(h, key, canonical(req, timestamp, nonce, d, normalizeHeaders(sig.headers)))
+	if !hmac.Equal(expected, sig.value) {
+		return "", errors.New("signature does not match")
+	}
+
+	// Nonces are only recorded once the signature is known to be valid, so
+	// that unsigned requests cannot fill the cache.
+	if err := v.nonces.add(sig.keyID+":"+nonce, time.Now()); err != nil {
+		return "", err
+	}
+	return sig.keyID, nil
+}
+
+// Middleware rejects requests with an invalid signature, or without one if
+// signatures are required, and adds the key id of signed requests to the
+// request context. It has the signature of core.Hooks.AddHTTPMiddleware.
+func (v *Verifier) Middleware(_ context.Context, r chi.Router) {
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if v == nil || (!v.cfg.Required && req.Header.Get(HeaderSignature) == "") {
+				next.ServeHTTP(w, req)
+				return
+			}
+			keyID, err := v.Verify(req)
+			if err != nil {
+				log.Debugf(req.Context(), "signing: %s %s rejected: %v", req.Method, req.URL.Path, err)
+				httpError := common.HTTPError{HTTPCode: http.StatusUnauthorized, Code: "1003", Description: "Invalid request signature"}
+				httpError.WriteError(req.Context(), w)
+				return
+			}
+			next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), keyIDKey{}, keyID)))
+		})
+	})
+}
+
+// nonceCache remembers recently seen nonces to reject replayed requests.
+type nonceCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	expires map[string]time.Time
+}
+
+func newNonceCache(size int, ttl time.Duration) *nonceCache {
+	return &nonceCache{size: size, ttl: ttl, expires: make(map[string]time.Time)}
+}
+
+func (c *nonceCache) add(nonce string, now time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if exp, seen := c.expires[nonce]; seen && now.Before(exp) {
+		return errors.New("nonce has already been used")
+	}
+	if len(c.expires) >= c.size {
+		for n, exp := range c.expires {
+			if !now.Before(exp) {
+				delete(c.expires, n)
+			}
+		}
+		if len(c.expires) >= c.size {
+			// Replays can no longer be detected, so fail closed.
+			return errors.New("too many signed requests, nonce cache is full")
+		}
+	}
+	c.expires[nonce] = now.Add(c.ttl)
+	return nil
+}
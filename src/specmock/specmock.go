@@ -0,0 +1,195 @@
+// Package specmock serves every operation of an OpenAPI spec with responses
+// taken from its examples or generated from its schemas, so that a service
+// can run locally without its downstream.
+//
+// The response is chosen by the ScenarioHeader of the request, or
+// Config.Scenario for requests without one, such as those of a service that
+// does not forward the header to its downstreams:
+//   - "": the first documented 2xx response, or an error response with
+//     probability Config.ErrorRate;
+//   - "error": the default response, or the first documented error response;
+//   - a status code such as "404": the response documented for that status;
+//   - any other value: the response with a named example of that name.
+package specmock
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"github.com/anz-bank/sysl-go-demo/src/contract"
+	"github.com/anz-bank/sysl-go-demo/src/faults"
+)
+
+// ScenarioHeader selects the response to a request.
+const ScenarioHeader = "X-Mock-Scenario"
+
+// ScenarioError selects an error response.
+const ScenarioError = "error"
+
+// Config configures a mock.
+type Config struct {
+	// BasePath is prepended to the paths of the spec, e.g. /pet-demo.
+	BasePath string
+	// Scenario is the scenario of requests without a ScenarioHeader.
+	Scenario string
+	// Latency delays every response, by an extra random duration up to Jitter.
+	Latency time.Duration
+	Jitter  time.Duration
+	// ErrorRate is the probability of an error response for requests
+	// without a scenario.
+	ErrorRate float64
+	// Seed seeds the generated data, latencies and errors.
+	Seed int64
+}
+
+// Mock serves the operations of a spec.
+type Mock struct {
+	spec   *contract.Spec
+	config Config
+	router chi.Router
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// New creates a Mock serving the operations of spec.
+func New(spec *contract.Spec, config Config) (*Mock, error) {
+	if config.ErrorRate < 0 || config.ErrorRate > 1 {
+		return nil, fmt.Errorf("specmock: error rate %v is not between 0 and 1", config.ErrorRate)
+	}
+	if config.Latency < 0 || config.Jitter < 0 {
+		return nil, fmt.Errorf("specmock: latency and jitter must not be negative")
+	}
+	m := &Mock{
+		spec:   spec,
+		config: config,
+		router: chi.NewRouter(),
+		rnd:    rand.New(rand.NewSource(config.Seed)), //nolint:gosec // fake data, not security sensitive
+	}
+	basePath := strings.TrimSuffix(config.BasePath, "/")
+	for _, op := range spec.Operations() {
+		m.router.Method(op.Method, basePath+op.Path, m.handler(op))
+	}
+	return m, nil
+}
+
+// ServeHTTP serves a request to one of the operations of the spec.
+func (m *Mock) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.router.ServeHTTP(w, r)
+}
+
+func (m *Mock) handler(op contract.Operation) http.HandlerFunc {
+	responses := m.spec.Responses(op.Method, op.Path)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !faults.Sleep(r.Context(), m.latency()) {
+			return
+		}
+		scenario := r.Header.Get(ScenarioHeader)
+		if scenario == "" {
+			scenario = m.config.Scenario
+		}
+		if scenario == "" && m.config.ErrorRate > 0 && m.float64() < m.config.ErrorRate {
+			scenario = ScenarioError
+		}
+		resp, example, code, err := selectResponse(responses, scenario)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body, err := m.example(resp, example)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if resp.ContentType != "" {
+			w.Header().Set("Content-Type", resp.ContentType)
+		}
+		w.WriteHeader(code)
+		_, _ = w.Write(body)
+	}
+}
+
+// selectResponse returns the response for scenario, the name of its example
+// and the status code to send.
+func selectResponse(responses []contract.Response, scenario string) (contract.Response, string, int, error) {
+	switch code, err := strconv.Atoi(scenario); {
+	case scenario == "":
+		for _, r := range responses {
+			if r.Success() {
+				return r, "", r.Code(), nil
+			}
+		}
+		if len(responses) > 0 {
+			return responses[0], "", responses[0].Code(), nil
+		}
+	case scenario == ScenarioError:
+		found := -1
+		for i, r := range responses {
+			if !r.Success() && (found < 0 || r.Status == "default") {
+				found = i
+			}
+		}
+		if found >= 0 {
+			return responses[found], "", responses[found].Code(), nil
+		}
+	case err == nil:
+		status := strconv.Itoa(code)
+		for _, key := range []string{status, status[:1] + "XX", "default"} {
+			for _, r := range responses {
+				if strings.EqualFold(r.Status, key) {
+					return r, "", code, nil
+				}
+			}
+		}
+	default:
+		for _, r := range responses {
+			for _, name := range r.ExampleNames() {
+				if name == scenario {
+					return r, name, r.Code(), nil
+				}
+			}
+		}
+	}
+	return contract.Response{}, "", 0, fmt.Errorf("unknown scenario %q, expected one of: %s", scenario, strings.Join(scenarios(responses), ", "))
+}
+
+// scenarios lists the scenarios available for an operation.
+func scenarios(responses []contract.Response) []string {
+	list := []string{ScenarioError}
+	for _, r := range responses {
+		list = append(list, r.Status)
+		list = append(list, r.ExampleNames()...)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func (m *Mock) example(resp contract.Response, name string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.spec.Example(resp, name, m.rnd)
+}
+
+func (m *Mock) latency() time.Duration {
+	d := m.config.Latency
+	if m.config.Jitter > 0 {
+		m.mu.Lock()
+		d += time.Duration(m.rnd.Int63n(int64(m.config.Jitter)))
+		m.mu.Unlock()
+	}
+	return d
+}
+
+func (m *Mock) float64() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.rnd.Float64()
+}
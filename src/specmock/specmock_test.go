@@ -0,0 +1,156 @@
+package specmock
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/anz-bank/sysl-go-demo/src/contract"
+)
+
+// scenarioSpec documents named examples and a status range.
+const scenarioSpec = `
+openapi: 3.0.0
+paths:
+  /pet/{id}:
+    get:
+      responses:
+        "200":
+          content:
+            application/json:
+              examples:
+                husky: {value: {name: husky}}
+                corgi: {value: {name: corgi}}
+        "404":
+          description: not found
+        5XX:
+          content:
+            text/plain:
+              example: unavailable
+`
+
+// serve serves spec with config, returning the URL of the mock.
+func serve(t *testing.T, spec *contract.Spec, config Config) string {
+	t.Helper()
+	m, err := New(spec, config)
+	require.NoError(t, err)
+	srv := httptest.NewServer(m)
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func send(t *testing.T, method, url, scenario string) (int, string, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	require.NoError(t, err)
+	if scenario != "" {
+		req.Header.Set(ScenarioHeader, scenario)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, resp.Header.Get("Content-Type"), string(body)
+}
+
+func TestPetstore(t *testing.T) {
+	t.Parallel()
+
+	spec, err := contract.Load("../../specs/petstore.yaml")
+	require.NoError(t, err)
+	url := serve(t, spec, Config{BasePath: "/pet-demo/"})
+
+	const petErr = `{"code":500,"message":"petstore is unavailable"}`
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		scenario string
+		code     int
+		body     string
+	}{
+		{"example", http.MethodGet, "/pet-demo/pet", "", http.StatusOK, `"https://images.dog.ceo/breeds/husky/n02110185_1469.jpg"`},
+		{"error", http.MethodGet, "/pet-demo/pet", ScenarioError, http.StatusInternalServerError, petErr},
+		{"status", http.MethodGet, "/pet-demo/pet", "503", http.StatusServiceUnavailable, petErr},
+		{"unknown scenario", http.MethodGet, "/pet-demo/pet", "missing", http.StatusBadRequest, `unknown scenario "missing", expected one of: 200, default, error`},
+		{"unknown route", http.MethodGet, "/pet-demo/pets", "", http.StatusNotFound, ""},
+		{"no base path", http.MethodGet, "/pet", "", http.StatusNotFound, ""},
+		{"unknown method", http.MethodPost, "/pet-demo/pet", "", http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			code, contentType, body := send(t, tt.method, url+tt.path, tt.scenario)
+			require.Equal(t, tt.code, code, body)
+			if tt.body != "" {
+				require.Contains(t, body, tt.body)
+			}
+			if code < http.StatusBadRequest || code >= http.StatusInternalServerError {
+				require.Equal(t, "application/json", contentType)
+			}
+		})
+	}
+}
+
+func TestScenarios(t *testing.T) {
+	t.Parallel()
+
+	spec, err := contract.Parse([]byte(scenarioSpec))
+	require.NoError(t, err)
+	url := serve(t, spec, Config{})
+
+	tests := []struct {
+		scenario    string
+		code        int
+		contentType string
+		body        string
+	}{
+		{"", http.StatusOK, "application/json", `{"name":"corgi"}`},
+		{"husky", http.StatusOK, "application/json", `{"name":"husky"}`},
+		{"404", http.StatusNotFound, "", ""},
+		{"502", http.StatusBadGateway, "text/plain", "unavailable"},
+		// Without a default response, the first error response.
+		{ScenarioError, http.StatusNotFound, "", ""},
+	}
+	for _, tt := range tests {
+		code, contentType, body := send(t, http.MethodGet, url+"/pet/1", tt.scenario)
+		require.Equal(t, tt.code, code, tt.scenario)
+		require.Equal(t, tt.contentType, contentType, tt.scenario)
+		require.Equal(t, tt.body, body, tt.scenario)
+	}
+
+	code, _, _ := send(t, http.MethodGet, serve(t, spec, Config{Scenario: "husky"})+"/pet/1", "")
+	require.Equal(t, http.StatusOK, code)
+	code, _, _ = send(t, http.MethodGet, serve(t, spec, Config{ErrorRate: 1})+"/pet/1", "")
+	require.Equal(t, http.StatusNotFound, code, "error rate")
+	code, _, _ = send(t, http.MethodGet, serve(t, spec, Config{ErrorRate: 1})+"/pet/1", "husky")
+	require.Equal(t, http.StatusOK, code, "error rate ignores requests with a scenario")
+}
+
+func TestLatency(t *testing.T) {
+	t.Parallel()
+
+	spec, err := contract.Parse([]byte(scenarioSpec))
+	require.NoError(t, err)
+	m, err := New(spec, Config{Latency: time.Hour})
+	require.NoError(t, err)
+
+	// A request that is cancelled during the latency gets no response.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/pet/1", nil).WithContext(ctx))
+	require.Empty(t, w.Body.String())
+
+	_, err = New(spec, Config{ErrorRate: 1.5})
+	require.ErrorContains(t, err, "error rate 1.5 is not between 0 and 1")
+	_, err = New(spec, Config{Jitter: -time.Second})
+	require.ErrorContains(t, err, "must not be negative")
+}
@@ -0,0 +1,224 @@
+// Package strictconfig detects configuration keys that have no corresponding
+// field in the configuration structure they are decoded into.
+package strictconfig
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// UnknownKeyError reports a single configuration key that does not map onto
+// any configuration field.
+type UnknownKeyError struct {
+	// Path is the full dotted path of the key, as written in the config file.
+	Path string
+	// Suggestion is the closest known key at the same level, if any.
+	Suggestion string
+}
+
+func (e UnknownKeyError) Error() string {
+	if e.Suggestion != "" {
+		return fmt.Sprintf("unknown config key %q (did you mean %q?)", e.Path, e.Suggestion)
+	}
+	return fmt.Sprintf("unknown config key %q", e.Path)
+}
+
+// Errors collects every unknown key found in a config file.
+type Errors []UnknownKeyError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return "Misconfiguration error: " + strings.Join(msgs, "; ")
+}
+
+// Check decodes the YAML config data and compares its keys against the fields
+// of configType, following the same mapstructure tags used to decode config.
+// Keys are matched case-insensitively. Top-level keys listed in ignoredKeys
+// are skipped. A non-nil error is always of type Errors.
+func Check(data []byte, configType reflect.Type, ignoredKeys ...string) error {
+	var root interface{}
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return err
+	}
+
+	ignored := make(map[string]bool, len(ignoredKeys))
+	for _, k := range ignoredKeys {
+		ignored[strings.ToLower(k)] = true
+	}
+
+	var errs Errors
+	walk(root, configType, "", ignored, &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func walk(node interface{}, t reflect.Type, path string, ignored map[string]bool, errs *Errors) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := node.(map[interface{}]interface{})
+		if !ok {
+			// Scalars decoded into structs (durations, sensitive strings, ...)
+			// are handled by decode hooks and cannot hold unknown keys.
+			return
+		}
+		fields := structFields(t)
+		for _, k := range sortedKeys(m) {
+			key := fmt.Sprint(k)
+			childPath := joinPath(path, key)
+			field, has := fields[strings.ToLower(key)]
+			if !has {
+				if path == "" && ignored[strings.ToLower(key)] {
+					continue
+				}
+				*errs = append(*errs, UnknownKeyError{Path: childPath, Suggestion: suggest(key, fields)})
+				continue
+			}
+			walk(m[k], field.typ, childPath, ignored, errs)
+		}
+	case reflect.Map:
+		m, ok := node.(map[interface{}]interface{})
+		if !ok {
+			return
+		}
+		for _, k := range sortedKeys(m) {
+			walk(m[k], t.Elem(), joinPath(path, fmt.Sprint(k)), ignored, errs)
+		}
+	case reflect.Slice, reflect.Array:
+		items, ok := node.([]interface{})
+		if !ok {
+			return
+		}
+		for i, item := range items {
+			walk(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), ignored, errs)
+		}
+	}
+}
+
+type field struct {
+	name string
+	typ  reflect.Type
+}
+
+// structFields returns the decodable fields of t keyed by their lower-cased
+// config key, flattening fields tagged with mapstructure ",squash".
+func structFields(t reflect.Type) map[string]field {
+	fields := make(map[string]field)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		tag := f.Tag.Get("mapstructure")
+		parts := strings.Split(tag, ",")
+		squash := false
+		for _, opt := range parts[1:] {
+			if opt == "squash" {
+				squash = true
+			}
+		}
+		if squash {
+			ft := f.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			for k, v := range structFields(ft) {
+				fields[k] = v
+			}
+			continue
+		}
+		name := parts[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[strings.ToLower(name)] = field{name: name, typ: f.Type}
+	}
+	return fields
+}
+
+// suggest returns the known field name closest to key, or "" if none is
+// close enough to plausibly be a typo.
+func suggest(key string, fields map[string]field) string {
+	lowerKey := strings.ToLower(key)
+	best, bestDist := "", -1
+	names := make([]string, 0, len(fields))
+	for k := range fields {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		d := levenshtein(lowerKey, k)
+		if bestDist == -1 || d < bestDist {
+			best, bestDist = fields[k].name, d
+		}
+	}
+	maxDist := len(lowerKey) / 3
+	if maxDist < 2 {
+		maxDist = 2
+	}
+	if bestDist == -1 || bestDist > maxDist {
+		return ""
+	}
+	return best
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+func minInt(a int, rest ...int) int {
+	for _, v := range rest {
+		if v < a {
+			a = v
+		}
+	}
+	return a
+}
+
+func sortedKeys(m map[interface{}]interface{}) []interface{} {
+	keys := make([]interface{}, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+	return keys
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
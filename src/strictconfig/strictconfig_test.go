@@ -0,0 +1,143 @@
+package strictconfig
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type commonConfig struct {
+	HostName string `mapstructure:"hostName"`
+	Port     int    `mapstructure:"port"`
+}
+
+type serverConfig struct {
+	Common        commonConfig `mapstructure:",squash"`
+	ClientTimeout string       `mapstructure:"clientTimeout"`
+	Internal      string       `mapstructure:"-"`
+}
+
+type testConfig struct {
+	Server  serverConfig            `mapstructure:"server"`
+	Servers map[string]serverConfig `mapstructure:"servers"`
+	List    []commonConfig          `mapstructure:"list"`
+}
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		data string
+		want Errors
+	}{
+		{
+			name: "known keys",
+			data: "server: {hostName: localhost, port: 80, clientTimeout: 1s}",
+		},
+		{
+			name: "keys are case-insensitive",
+			data: "Server: {HOSTNAME: localhost, clienttimeout: 1s}",
+		},
+		{
+			name: "misspelt key",
+			data: "server: {clientTimout: 1s}",
+			want: Errors{{Path: "server.clientTimout", Suggestion: "clientTimeout"}},
+		},
+		{
+			name: "misspelt squashed key",
+			data: "server: {hostNme: localhost}",
+			want: Errors{{Path: "server.hostNme", Suggestion: "hostName"}},
+		},
+		{
+			name: "squashed struct is not a key",
+			data: "server: {common: {port: 80}}",
+			want: Errors{{Path: "server.common"}},
+		},
+		{
+			name: "ignored field is not a key",
+			data: "server: {internal: x}",
+			want: Errors{{Path: "server.internal"}},
+		},
+		{
+			name: "map values and list items",
+			data: "servers: {a: {prt: 80}}\nlist: [{port: 1}, {hostname: h, extra: x}]",
+			want: Errors{
+				{Path: "list[1].extra"},
+				{Path: "servers.a.prt", Suggestion: "port"},
+			},
+		},
+		{
+			name: "every unknown key is reported",
+			data: "unknown: 1\nserver: {clientTimout: 1s, prot: 80}",
+			want: Errors{
+				{Path: "server.clientTimout", Suggestion: "clientTimeout"},
+				{Path: "server.prot", Suggestion: "port"},
+				{Path: "unknown"},
+			},
+		},
+		{
+			name: "ignored top-level key",
+			data: "envPrefix: PETDEMO\nserver: {port: 80}",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := Check([]byte(tt.data), reflect.TypeOf(testConfig{}), "envPrefix")
+			if tt.want == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.Equal(t, tt.want, err)
+		})
+	}
+}
+
+func TestCheckInvalidYAML(t *testing.T) {
+	t.Parallel()
+
+	err := Check([]byte("server: ["), reflect.TypeOf(testConfig{}))
+	require.Error(t, err)
+	_, isUnknownKeys := err.(Errors)
+	require.False(t, isUnknownKeys)
+}
+
+func TestSuggest(t *testing.T) {
+	t.Parallel()
+
+	fields := map[string]field{
+		"port":          {name: "port"},
+		"clienttimeout": {name: "clientTimeout"},
+		"abcdefxyz":     {name: "abcdefXyz"},
+	}
+	tests := []struct {
+		key  string
+		want string
+	}{
+		// Keys of up to 8 characters may be 2 edits away.
+		{"prt", "port"},
+		{"prot", "port"},
+		{"xy", ""},
+		{"pq", ""},
+		// Longer keys may be a third of their length away.
+		{"ClientTimout", "clientTimeout"},
+		{"abcdefghi", "abcdefXyz"},
+		{"abcdeuvwx", ""},
+		{"unrelated", ""},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, suggest(tt.key, fields), tt.key)
+	}
+}
+
+func TestUnknownKeyError(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, `unknown config key "a.b"`, UnknownKeyError{Path: "a.b"}.Error())
+	require.Equal(t,
+		`Misconfiguration error: unknown config key "a.prt" (did you mean "port"?); unknown config key "b"`,
+		Errors{{Path: "a.prt", Suggestion: "port"}, {Path: "b"}}.Error())
+}
@@ -0,0 +1,33 @@
+package testhooks
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Failures is a fake of the testing.T methods used by the test helpers in
+// this repository. It records the failures reported to it instead of failing
+// the test, so that tests can check the failures of a helper that is
+// expected to fail.
+type Failures struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (f *Failures) Errorf(format string, args ...interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.messages = append(f.messages, fmt.Sprintf(format, args...))
+}
+
+func (f *Failures) FailNow()                  {}
+func (f *Failures) Fatal(args ...interface{}) { f.Errorf("%s", fmt.Sprint(args...)) }
+func (f *Failures) Log(...interface{})        {}
+
+// String returns the recorded failures, one per line.
+func (f *Failures) String() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return strings.Join(f.messages, "\n")
+}
@@ -0,0 +1,46 @@
+// Package testhooks patches the hooks returned by a createService function,
+// so that test helpers can observe or change the behaviour of a service
+// started with the generated NewTestServer or NewIntegrationTestServer.
+package testhooks
+
+import (
+	"net/http"
+	"reflect"
+
+	"github.com/anz-bank/sysl-go/core"
+)
+
+// Patch returns createService with patch applied to the hooks it returns.
+// createService must have the signature expected by the generated Serve
+// and NewTestServer functions.
+func Patch(createService interface{}, patch func(*core.Hooks)) interface{} {
+	return reflect.MakeFunc(reflect.TypeOf(createService), func(args []reflect.Value) []reflect.Value {
+		results := reflect.ValueOf(createService).Call(args)
+		if err := results[2].Interface(); err != nil {
+			return results
+		}
+		hooks, _ := results[1].Interface().(*core.Hooks)
+		if hooks == nil {
+			hooks = &core.Hooks{}
+		}
+		patch(hooks)
+		results[1] = reflect.ValueOf(hooks)
+		return results
+	}).Interface()
+}
+
+// WrapDownstreams returns createService with wrap applied to the transport
+// of every downstream HTTP client. wrap sees requests as they are sent on
+// the wire, after the RoundTrippers added by the service itself.
+func WrapDownstreams(createService interface{}, wrap func(serviceName, serviceURL string, rt http.RoundTripper) http.RoundTripper) interface{} {
+	return Patch(createService, func(hooks *core.Hooks) {
+		next := hooks.DownstreamRoundTripper
+		hooks.DownstreamRoundTripper = func(serviceName, serviceURL string, original http.RoundTripper) http.RoundTripper {
+			rt := wrap(serviceName, serviceURL, original)
+			if next != nil {
+				rt = next(serviceName, serviceURL, rt)
+			}
+			return rt
+		}
+	})
+}
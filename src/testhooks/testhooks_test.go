@@ -0,0 +1,101 @@
+package testhooks
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/anz-bank/sysl-go/core"
+	"github.com/stretchr/testify/require"
+)
+
+type appConfig struct{}
+
+type service struct{}
+
+// roundTripper records its name in the order requests pass through it.
+type roundTripper struct {
+	name  string
+	order *[]string
+	next  http.RoundTripper
+}
+
+func (rt *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	*rt.order = append(*rt.order, rt.name)
+	if rt.next == nil {
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+	}
+	return rt.next.RoundTrip(req)
+}
+
+func TestPatch(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		hooks *core.Hooks
+		err   error
+	}{
+		{"hooks", &core.Hooks{ShouldSetGrpcGlobalLogger: func() bool { return true }}, nil},
+		{"no hooks", nil, nil},
+		{"error", nil, errors.New("invalid config")},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			createService := func(ctx context.Context, cfg appConfig) (*service, *core.Hooks, error) {
+				return &service{}, tt.hooks, tt.err
+			}
+			patched := Patch(createService, func(hooks *core.Hooks) {
+				hooks.HTTPClientBuilder = func(string) (*http.Client, string, error) { return http.DefaultClient, "", nil }
+			}).(func(context.Context, appConfig) (*service, *core.Hooks, error))
+
+			svc, hooks, err := patched(context.Background(), appConfig{})
+			require.NotNil(t, svc)
+			if tt.err != nil {
+				// Hooks are not patched if the service could not be created.
+				require.Equal(t, tt.err, err)
+				require.Nil(t, hooks)
+				return
+			}
+			require.NoError(t, err)
+			require.NotNil(t, hooks.HTTPClientBuilder)
+			if tt.hooks != nil {
+				require.Same(t, tt.hooks, hooks)
+				require.True(t, hooks.ShouldSetGrpcGlobalLogger())
+			}
+		})
+	}
+}
+
+func TestWrapDownstreams(t *testing.T) {
+	t.Parallel()
+
+	var order []string
+	createService := func(ctx context.Context, cfg appConfig) (*service, *core.Hooks, error) {
+		return &service{}, &core.Hooks{
+			DownstreamRoundTripper: func(serviceName, serviceURL string, original http.RoundTripper) http.RoundTripper {
+				return &roundTripper{name: "service", order: &order, next: original}
+			},
+		}, nil
+	}
+	var wrapped []string
+	patched := WrapDownstreams(createService, func(serviceName, serviceURL string, rt http.RoundTripper) http.RoundTripper {
+		wrapped = append(wrapped, serviceName+" "+serviceURL)
+		return &roundTripper{name: "test", order: &order, next: rt}
+	}).(func(context.Context, appConfig) (*service, *core.Hooks, error))
+
+	_, hooks, err := patched(context.Background(), appConfig{})
+	require.NoError(t, err)
+	rt := hooks.DownstreamRoundTripper("petstore", "http://petstore", &roundTripper{name: "wire", order: &order})
+	req, err := http.NewRequest(http.MethodGet, "http://petstore/pet", nil)
+	require.NoError(t, err)
+	_, err = rt.RoundTrip(req)
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"petstore http://petstore"}, wrapped)
+	// The test's RoundTripper sees requests after the service's own.
+	require.Equal(t, []string{"service", "test", "wire"}, order)
+}
@@ -0,0 +1,249 @@
+package tlsreload
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anz-bank/sysl-go-demo/src/admin"
+	"github.com/anz-bank/sysl-go/config"
+	"github.com/anz-bank/sysl-go/log"
+	"github.com/go-chi/chi"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	defaultReloadInterval = 10 * time.Second
+	defaultExpiryWarning  = time.Hour
+)
+
+// Config configures certificate reloading, found under app.tlsReload.
+type Config struct {
+	ReloadInterval time.Duration `yaml:"reloadInterval" mapstructure:"reloadInterval"`
+	// ExpiryWarning fails the health check when a certificate expires within
+	// this duration, 1h by default.
+	ExpiryWarning time.Duration `yaml:"expiryWarning" mapstructure:"expiryWarning"`
+}
+
+func (c *Config) setDefaults() {
+	if c.ReloadInterval == 0 {
+		c.ReloadInterval = defaultReloadInterval
+	}
+	if c.ExpiryWarning == 0 {
+		c.ExpiryWarning = defaultExpiryWarning
+	}
+}
+
+// Manager reloads the certificates of every TLSConfig added to it and
+// reports their expiry. It implements prometheus.Collector.
+type Manager struct {
+	cfg Config
+
+	mu        sync.Mutex
+	reloaders []*Reloader
+
+	expiry   *prometheus.Desc
+	failures *prometheus.Desc
+}
+
+// NewManager creates a Manager from config.
+func NewManager(cfg Config) *Manager {
+	cfg.setDefaults()
+	return &Manager{
+		cfg: cfg,
+		expiry: prometheus.NewDesc("tls_certificate_expiry_timestamp_seconds",
+			"Time at which a served certificate expires, in seconds since the epoch.",
+			[]string{"tls", "subject", "serial"}, nil),
+		failures: prometheus.NewDesc("tls_certificate_reload_failures_total",
+			"Number of failed attempts to reload certificates.",
+			[]string{"tls"}, nil),
+	}
+}
+
+// Add loads the certificates of cfg under the given name. It returns nil if
+// cfg holds no certificates.
+func (m *Manager) Add(name string, cfg *config.TLSConfig) (*Reloader, error) {
+	r, err := newReloader(name, cfg)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	m.reloaders = append(m.reloaders, r)
+	m.mu.Unlock()
+	return r, nil
+}
+
+// Downstreams returns the HTTP downstreams of the generated downstream config,
+// such as GenCode.Downstream of the default config, keyed by service name.
+func Downstreams(downstreamConfig interface{}) map[string]*config.CommonDownstreamData {
+	downstreams := make(map[string]*config.CommonDownstreamData)
+	v := reflect.ValueOf(downstreamConfig)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return downstreams
+	}
+	v = v.Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		cfg, ok := v.Field(i).Addr().Interface().(*config.CommonDownstreamData)
+		if !ok {
+			continue
+		}
+		// Service names are the config keys of the downstreams.
+		name := strings.Split(field.Tag.Get("mapstructure"), ",")[0]
+		if name == "" {
+			name = field.Name
+		}
+		downstreams[name] = cfg
+	}
+	return downstreams
+}
+
+// HTTPClientBuilder creates the clients of the given downstreams, keyed by
+// service name, presenting reloaded client certificates. It has the
+// signature of core.Hooks.HTTPClientBuilder. Use Downstreams to pass every
+// downstream of the service, as clients can only be built for those given.
+func (m *Manager) HTTPClientBuilder(ctx context.Context, downstreams map[string]*config.CommonDownstreamData) (func(serviceName string) (*http.Client, string, error), error) {
+	reloaders := make(map[string]*Reloader, len(downstreams))
+	for name, cfg := range downstreams {
+		r, err := m.Add("downstream."+name, cfg.ClientTransport.ClientTLS)
+		if err != nil {
+			return nil, err
+		}
+		reloaders[name] = r
+	}
+	return func(serviceName string) (*http.Client, string, error) {
+		cfg, ok := downstreams[serviceName]
+		if !ok {
+			return nil, "", fmt.Errorf("no config for downstream %s", serviceName)
+		}
+		client, err := reloaders[serviceName].HTTPClient(ctx, cfg)
+		if err != nil {
+			return nil, "", err
+		}
+		return client, cfg.ServiceURL, nil
+	}, nil
+}
+
+// Start polls the certificate files for changes until ctx is done.
+func (m *Manager) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(m.cfg.ReloadInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				for _, r := range m.all() {
+					if err := r.reload(); err != nil {
+						log.Error(ctx, err, fmt.Sprintf("tls: reload of %s failed, keeping previous certificates", r.name))
+					}
+				}
+			}
+		}
+	}()
+}
+
+func (m *Manager) all() []*Reloader {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*Reloader(nil), m.reloaders...)
+}
+
+// Describe implements prometheus.Collector.
+func (m *Manager) Describe(ch chan<- *prometheus.Desc) {
+	ch <- m.expiry
+	ch <- m.failures
+}
+
+// Collect implements prometheus.Collector.
+func (m *Manager) Collect(ch chan<- prometheus.Metric) {
+	for _, r := range m.all() {
+		for _, cert := range r.certificates() {
+			ch <- prometheus.MustNewConstMetric(m.expiry, prometheus.GaugeValue,
+				float64(cert.Leaf.NotAfter.Unix()), r.name, cert.Leaf.Subject.String(), cert.Leaf.SerialNumber.Text(16))
+		}
+		r.mu.Lock()
+		failures := r.failures
+		r.mu.Unlock()
+		ch <- prometheus.MustNewConstMetric(m.failures, prometheus.CounterValue, float64(failures), r.name)
+	}
+}
+
+// State describes the certificates of a TLSConfig, as reported on the admin server.
+type State struct {
+	Name         string        `json:"name"`
+	Files        []string      `json:"files"`
+	Certificates []Certificate `json:"certificates"`
+	LoadedAt     time.Time     `json:"loadedAt"`
+	LastError    string        `json:"lastError,omitempty"`
+	Healthy      bool          `json:"healthy"`
+}
+
+// Certificate describes a served certificate.
+type Certificate struct {
+	Subject  string    `json:"subject"`
+	Serial   string    `json:"serial"`
+	DNSNames []string  `json:"dnsNames,omitempty"`
+	NotAfter time.Time `json:"notAfter"`
+}
+
+// States reports the certificates currently served, sorted by name. A
+// TLSConfig is unhealthy when one of its certificates expires within
+// Config.ExpiryWarning.
+func (m *Manager) States() []State {
+	deadline := time.Now().Add(m.cfg.ExpiryWarning)
+	states := []State{}
+	for _, r := range m.all() {
+		s := State{Name: r.name, Files: r.files, Healthy: true}
+		for _, cert := range r.certificates() {
+			s.Certificates = append(s.Certificates, Certificate{
+				Subject:  cert.Leaf.Subject.String(),
+				Serial:   cert.Leaf.SerialNumber.Text(16),
+				DNSNames: cert.Leaf.DNSNames,
+				NotAfter: cert.Leaf.NotAfter,
+			})
+			if cert.Leaf.NotAfter.Before(deadline) {
+				s.Healthy = false
+			}
+		}
+		r.mu.Lock()
+		s.LoadedAt = r.loadedAt
+		if r.lastErr != nil {
+			s.LastError = r.lastErr.Error()
+		}
+		r.mu.Unlock()
+		states = append(states, s)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+	return states
+}
+
+// WireAdminRoutes installs the certificate endpoints: GET / lists the served
+// certificates and GET /health fails with 503 when a certificate is about to expire.
+func (m *Manager) WireAdminRoutes(r chi.Router) {
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		admin.WriteJSON(w, http.StatusOK, m.States())
+	})
+	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
+		var expiring []string
+		for _, s := range m.States() {
+			if !s.Healthy {
+				expiring = append(expiring, s.Name)
+			}
+		}
+		if len(expiring) > 0 {
+			admin.WriteJSON(w, http.StatusServiceUnavailable, map[string]interface{}{"status": "expiring", "tls": expiring})
+			return
+		}
+		admin.WriteJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+}
@@ -0,0 +1,93 @@
+package tlsreload
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/anz-bank/sysl-go/config"
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/require"
+)
+
+type downstreamConfig struct {
+	Petstore  config.CommonDownstreamData     `mapstructure:"petstore"`
+	Inventory config.CommonDownstreamData     `mapstructure:"inventory,omitempty"`
+	Untagged  config.CommonDownstreamData     // keyed by field name
+	Payments  config.CommonGRPCDownstreamData `mapstructure:"payments"`
+	internal  config.CommonDownstreamData     //nolint:unused // skipped, as it is unexported
+}
+
+func TestDownstreams(t *testing.T) {
+	t.Parallel()
+
+	cfg := &downstreamConfig{
+		Petstore:  config.CommonDownstreamData{ServiceURL: "http://petstore"},
+		Inventory: config.CommonDownstreamData{ServiceURL: "http://inventory"},
+	}
+	downstreams := Downstreams(cfg)
+	require.Len(t, downstreams, 3)
+	require.Same(t, &cfg.Petstore, downstreams["petstore"])
+	require.Same(t, &cfg.Inventory, downstreams["inventory"])
+	require.Same(t, &cfg.Untagged, downstreams["Untagged"])
+
+	require.Empty(t, Downstreams(nil))
+	require.Empty(t, Downstreams((*downstreamConfig)(nil)))
+	require.Empty(t, Downstreams(*cfg))
+}
+
+func TestHTTPClientBuilder(t *testing.T) {
+	t.Parallel()
+
+	cfg := &downstreamConfig{
+		Petstore:  config.CommonDownstreamData{ServiceURL: "http://petstore"},
+		Inventory: config.CommonDownstreamData{ServiceURL: "http://inventory"},
+	}
+	build, err := NewManager(Config{}).HTTPClientBuilder(context.Background(), Downstreams(cfg))
+	require.NoError(t, err)
+	for name, url := range map[string]string{"petstore": "http://petstore", "inventory": "http://inventory"} {
+		client, serviceURL, err := build(name)
+		require.NoError(t, err)
+		require.NotNil(t, client)
+		require.Equal(t, url, serviceURL)
+	}
+	_, _, err = build("payments")
+	require.ErrorContains(t, err, "no config for downstream payments")
+}
+
+func TestExpiryWarning(t *testing.T) {
+	t.Parallel()
+
+	expiring, valid := newIdentity(t), newIdentity(t)
+	expiring.write(t, "expiring", time.Now().Add(30*time.Minute))
+	valid.write(t, "valid", time.Now().Add(48*time.Hour))
+	m := NewManager(Config{})
+	_, err := m.Add("upstream", valid.config())
+	require.NoError(t, err)
+	r := chi.NewRouter()
+	m.WireAdminRoutes(r)
+	health := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+		return w
+	}
+	require.Equal(t, http.StatusOK, health().Code)
+
+	_, err = m.Add("grpc", expiring.config())
+	require.NoError(t, err)
+	states := m.States()
+	require.Equal(t, "grpc", states[0].Name)
+	require.False(t, states[0].Healthy)
+	require.True(t, states[1].Healthy)
+	w := health()
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.JSONEq(t, `{"status": "expiring", "tls": ["grpc"]}`, w.Body.String())
+
+	// The warning applies within the configured duration only.
+	m = NewManager(Config{ExpiryWarning: 10 * time.Minute})
+	_, err = m.Add("grpc", expiring.config())
+	require.NoError(t, err)
+	require.True(t, m.States()[0].Healthy)
+}
@@ -0,0 +1,191 @@
+// Package tlsreload serves the certificates named in a sysl-go TLSConfig
+// through GetCertificate and GetClientCertificate, reloading them when the
+// files change, so that rotated certificates are picked up without a restart.
+package tlsreload
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/anz-bank/sysl-go/config"
+)
+
+// Reloader holds the certificates of a single TLSConfig. A nil *Reloader
+// leaves TLS configs untouched.
+type Reloader struct {
+	name  string
+	cfg   *config.TLSConfig
+	files []string
+
+	current atomic.Value // []tls.Certificate
+
+	mu       sync.Mutex
+	stamps   map[string]fileStamp
+	loadedAt time.Time
+	lastErr  error
+	failures uint64
+}
+
+// fileStamp identifies the version of a file.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// newReloader loads the certificates of cfg. It returns nil if cfg holds none.
+func newReloader(name string, cfg *config.TLSConfig) (*Reloader, error) {
+	if cfg == nil || cfg.InsecureSkipVerify {
+		return nil, nil
+	}
+	r := &Reloader{name: name, cfg: cfg}
+	for _, identity := range cfg.ServerIdentities {
+		switch {
+		case identity == nil:
+		case identity.CertKeyPair != nil:
+			if identity.CertKeyPair.CertPath != nil && identity.CertKeyPair.KeyPath != nil {
+				r.files = append(r.files, *identity.CertKeyPair.CertPath, *identity.CertKeyPair.KeyPath)
+			}
+		case identity.PKCS12Store != nil:
+			if identity.PKCS12Store.Path != nil {
+				r.files = append(r.files, *identity.PKCS12Store.Path)
+			}
+		}
+	}
+	if len(r.files) == 0 {
+		return nil, nil
+	}
+	if err := r.reload(); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return r, nil
+}
+
+// reload re-reads the certificates if any of the files changed since they
+// were last read. On failure the previous certificates are kept.
+func (r *Reloader) reload() error {
+	stamps := make(map[string]fileStamp, len(r.files))
+	for _, file := range r.files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return r.failed(err)
+		}
+		stamps[file] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+	}
+	r.mu.Lock()
+	unchanged := len(r.stamps) == len(stamps)
+	for file, stamp := range stamps {
+		if r.stamps[file] != stamp {
+			unchanged = false
+		}
+	}
+	r.mu.Unlock()
+	if unchanged {
+		return nil
+	}
+
+	// The files are not read atomically, so a certificate and key that are
+	// being replaced may not match. The reload is retried on the next poll.
+	certs, err := config.OurIdentityCertificates(r.cfg)
+	if err != nil {
+		return r.failed(err)
+	}
+	if len(certs) == 0 {
+		return r.failed(errors.New("no certificates found"))
+	}
+	for i := range certs {
+		if certs[i].Leaf == nil {
+			leaf, err := x509.ParseCertificate(certs[i].Certificate[0])
+			if err != nil {
+				return r.failed(err)
+			}
+			certs[i].Leaf = leaf
+		}
+	}
+
+	r.current.Store(certs)
+	r.mu.Lock()
+	r.stamps = stamps
+	r.loadedAt = time.Now()
+	r.lastErr = nil
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *Reloader) failed(err error) error {
+	r.mu.Lock()
+	r.lastErr = err
+	r.failures++
+	r.mu.Unlock()
+	return err
+}
+
+func (r *Reloader) certificates() []tls.Certificate {
+	return r.current.Load().([]tls.Certificate)
+}
+
+// GetCertificate returns the first certificate supported by the client, for
+// use as tls.Config.GetCertificate.
+func (r *Reloader) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	certs := r.certificates()
+	for i := range certs {
+		if hello.SupportsCertificate(&certs[i]) == nil {
+			return &certs[i], nil
+		}
+	}
+	return &certs[0], nil
+}
+
+// GetClientCertificate returns the first certificate accepted by the server,
+// for use as tls.Config.GetClientCertificate.
+func (r *Reloader) GetClientCertificate(info *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	certs := r.certificates()
+	for i := range certs {
+		if info.SupportsCertificate(&certs[i]) == nil {
+			return &certs[i], nil
+		}
+	}
+	return &certs[0], nil
+}
+
+// ServerTLS returns a copy of base serving the certificates of r.
+func (r *Reloader) ServerTLS(base *tls.Config) *tls.Config {
+	if r == nil || base == nil {
+		return base
+	}
+	c := base.Clone()
+	c.Certificates = nil
+	c.GetCertificate = r.GetCertificate
+	return c
+}
+
+// ClientTLS returns a copy of base presenting the certificates of r.
+func (r *Reloader) ClientTLS(base *tls.Config) *tls.Config {
+	if r == nil || base == nil {
+		return base
+	}
+	c := base.Clone()
+	c.Certificates = nil
+	c.GetClientCertificate = r.GetClientCertificate
+	return c
+}
+
+// HTTPClient creates a downstream client in the same way as sysl-go, which
+// presents the certificates of r.
+func (r *Reloader) HTTPClient(ctx context.Context, cfg *config.CommonDownstreamData) (*http.Client, error) {
+	client, err := config.DefaultHTTPClient(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if transport, ok := client.Transport.(*http.Transport); ok {
+		transport.TLSClientConfig = r.ClientTLS(transport.TLSClientConfig)
+	}
+	return client, nil
+}
@@ -0,0 +1,172 @@
+package tlsreload
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/anz-bank/sysl-go/config"
+	"github.com/anz-bank/sysl-go/log"
+	"github.com/stretchr/testify/require"
+)
+
+// identity is a certificate and key file pair.
+type identity struct {
+	cert, key string
+}
+
+func newIdentity(t *testing.T) identity {
+	t.Helper()
+	dir := t.TempDir()
+	return identity{cert: filepath.Join(dir, "tls.crt"), key: filepath.Join(dir, "tls.key")}
+}
+
+// write replaces the files with a self-signed certificate for cn, moving
+// their modification time forward so that the next reload reads them even
+// within the timestamp resolution.
+func (id identity) write(t *testing.T, cn string, notAfter time.Time) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	id.replace(t, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+}
+
+func (id identity) replace(t *testing.T, cert, key []byte) {
+	t.Helper()
+	modTime := time.Now()
+	if info, err := os.Stat(id.cert); err == nil {
+		modTime = info.ModTime().Add(time.Second)
+	}
+	for path, data := range map[string][]byte{id.cert: cert, id.key: key} {
+		require.NoError(t, os.WriteFile(path, data, 0o600))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+}
+
+func (id identity) config() *config.TLSConfig {
+	cert, key := id.cert, id.key
+	return &config.TLSConfig{ServerIdentities: []*config.ServerIdentityConfig{{CertKeyPair: &config.CertKeyPair{CertPath: &cert, KeyPath: &key}}}}
+}
+
+// serve serves HTTPS with cfg, returning the URL of the server.
+func serve(t *testing.T, cfg *tls.Config) string {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", cfg)
+	require.NoError(t, err)
+	srv := &http.Server{Handler: http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), ReadHeaderTimeout: time.Second}
+	go func() { _ = srv.Serve(ln) }()
+	t.Cleanup(func() { _ = srv.Close() })
+	return "https://" + ln.Addr().String()
+}
+
+// servedCN returns the common name of the certificate served on a new
+// connection to url.
+func servedCN(t *testing.T, url string) string {
+	t.Helper()
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true}, //nolint:gosec // self-signed test certificates
+		DisableKeepAlives: true,
+	}}
+	resp, err := client.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	return resp.TLS.PeerCertificates[0].Subject.CommonName
+}
+
+func TestReload(t *testing.T) {
+	t.Parallel()
+
+	id := newIdentity(t)
+	id.write(t, "v1", time.Now().Add(48*time.Hour))
+	m := NewManager(Config{})
+	r, err := m.Add("upstream", id.config())
+	require.NoError(t, err)
+
+	srv := serve(t, r.ServerTLS(&tls.Config{MinVersion: tls.VersionTLS12}))
+	require.Equal(t, "v1", servedCN(t, srv))
+
+	id.write(t, "v2", time.Now().Add(48*time.Hour))
+	require.NoError(t, r.reload())
+	require.Equal(t, "v2", servedCN(t, srv))
+	// Unchanged files are not read again.
+	require.NoError(t, r.reload())
+
+	// Invalid files keep the previous certificates.
+	id.replace(t, []byte("not a certificate"), []byte("not a key"))
+	require.Error(t, r.reload())
+	require.Equal(t, "v2", servedCN(t, srv))
+	states := m.States()
+	require.Len(t, states, 1)
+	require.NotEmpty(t, states[0].LastError)
+	require.Equal(t, "CN=v2", states[0].Certificates[0].Subject)
+	require.NoError(t, os.Remove(id.key))
+	require.Error(t, r.reload())
+	require.Equal(t, uint64(2), r.failures)
+
+	id.write(t, "v3", time.Now().Add(48*time.Hour))
+	require.NoError(t, r.reload())
+	require.Equal(t, "v3", servedCN(t, srv))
+	require.Empty(t, m.States()[0].LastError)
+}
+
+func TestStart(t *testing.T) {
+	t.Parallel()
+
+	id := newIdentity(t)
+	id.write(t, "v1", time.Now().Add(48*time.Hour))
+	m := NewManager(Config{ReloadInterval: 10 * time.Millisecond})
+	r, err := m.Add("upstream", id.config())
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(log.PutLogger(context.Background(), log.NewDefaultLogger()))
+	defer cancel()
+	m.Start(ctx)
+
+	// The files are replaced while the certificates are being served.
+	id.write(t, "v2", time.Now().Add(48*time.Hour))
+	require.Eventually(t, func() bool {
+		cert, err := r.GetCertificate(&tls.ClientHelloInfo{})
+		return err == nil && cert.Leaf.Subject.CommonName == "v2"
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestNewReloader(t *testing.T) {
+	t.Parallel()
+
+	r, err := newReloader("none", nil)
+	require.NoError(t, err)
+	require.Nil(t, r)
+	r, err = newReloader("insecure", &config.TLSConfig{InsecureSkipVerify: true})
+	require.NoError(t, err)
+	require.Nil(t, r)
+	r, err = newReloader("no identities", &config.TLSConfig{})
+	require.NoError(t, err)
+	require.Nil(t, r)
+	base := &tls.Config{MinVersion: tls.VersionTLS12}
+	require.Same(t, base, r.ServerTLS(base))
+	require.Same(t, base, r.ClientTLS(base))
+
+	_, err = newReloader("missing", newIdentity(t).config())
+	require.ErrorContains(t, err, "missing: ")
+}